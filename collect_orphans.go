@@ -0,0 +1,57 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"strings"
+	"time"
+
+	p "github.com/daytonaio/daytona-provider-gcp/pkg/provider"
+	gcputil "github.com/daytonaio/daytona-provider-gcp/pkg/provider/util"
+)
+
+// collectOrphansCommand runs the orphan collector outside of the Daytona server, which only talks to the provider
+// through the plugin interface. The known workspace IDs have to be passed to delete the orphans, so that the
+// resources of every workspace aren't deleted by accident.
+const collectOrphansCommand = "collect-orphans"
+
+func collectOrphans(args []string) error {
+	flags := flag.NewFlagSet(collectOrphansCommand, flag.ContinueOnError)
+	targetOptions := flags.String("target-options", "{}", "The target options JSON of the target whose project is searched")
+	knownWorkspaceIds := flags.String("known-workspaces", "", "Comma separated IDs of the workspaces the Daytona server knows about")
+	minAge := flags.Duration("min-age", time.Hour, "The minimum age of the collected resources")
+	deleteOrphans := flags.Bool("delete", false, "Delete the orphans instead of only reporting them")
+	deleteRetainedDataDisks := flags.Bool("delete-retained-data-disks", false, "Also delete the data disks kept by Retain Data Disk")
+
+	err := flags.Parse(args)
+	if errors.Is(err, flag.ErrHelp) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	knownWorkspacesSet := false
+	flags.Visit(func(f *flag.Flag) {
+		knownWorkspacesSet = knownWorkspacesSet || f.Name == "known-workspaces"
+	})
+	if *deleteOrphans && !knownWorkspacesSet {
+		return errors.New("-known-workspaces is required with -delete")
+	}
+
+	orphanOpts := gcputil.OrphanOptions{
+		MinAge:                  *minAge,
+		DryRun:                  !*deleteOrphans,
+		DeleteRetainedDataDisks: *deleteRetainedDataDisks,
+	}
+	for _, workspaceId := range strings.Split(*knownWorkspaceIds, ",") {
+		if workspaceId = strings.TrimSpace(workspaceId); workspaceId != "" {
+			orphanOpts.KnownWorkspaceIds = append(orphanOpts.KnownWorkspaceIds, workspaceId)
+		}
+	}
+
+	return withProvider(func(gcpProvider *p.GCPProvider) error {
+		_, err := gcpProvider.CollectOrphans(*targetOptions, orphanOpts)
+		return err
+	})
+}
@@ -0,0 +1,1111 @@
+package fakecompute
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"cloud.google.com/go/compute/apiv1/computepb"
+	iam "google.golang.org/api/iam/v1"
+	"google.golang.org/api/option"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+// Server is an in-memory fake of the Compute Engine REST endpoints used by the provider, served with httptest.
+// Point the GCP clients to it with ClientOptions to test the provider without a GCP project.
+// Operations complete immediately, so every operation returned by the server is DONE.
+type Server struct {
+	server *httptest.Server
+
+	mu             sync.Mutex
+	instances      map[string]*computepb.Instance
+	statusQueues   map[string][]string
+	operations     map[string]*computepb.Operation
+	operationCount int
+	instanceCount  uint64
+	requests       []string
+	// onInsertInstance runs before an instance is inserted, e.g. to change other resources concurrently.
+	onInsertInstance func()
+
+	zones        []*computepb.Zone
+	machineTypes map[string][]*computepb.MachineType
+	diskTypes    map[string][]*computepb.DiskType
+	images       map[string][]*computepb.Image
+	resources    map[string]proto.Message
+
+	serviceAccounts map[string]*iam.ServiceAccount
+	// guestAttributes are keyed by instance key and <namespace>/<key>.
+	guestAttributes map[string]map[string]string
+}
+
+func NewServer() *Server {
+	s := &Server{
+		instances:    map[string]*computepb.Instance{},
+		statusQueues: map[string][]string{},
+		operations:   map[string]*computepb.Operation{},
+		machineTypes: map[string][]*computepb.MachineType{},
+		diskTypes:    map[string][]*computepb.DiskType{},
+		images:       map[string][]*computepb.Image{},
+		resources:    map[string]proto.Message{},
+
+		serviceAccounts: map[string]*iam.ServiceAccount{},
+		guestAttributes: map[string]map[string]string{},
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /compute/v1/projects/{project}/zones/{zone}/instances", s.listInstances)
+	mux.HandleFunc("POST /compute/v1/projects/{project}/zones/{zone}/instances", s.insertInstance)
+	mux.HandleFunc("GET /compute/v1/projects/{project}/zones/{zone}/instances/{instance}", s.getInstance)
+	mux.HandleFunc("DELETE /compute/v1/projects/{project}/zones/{zone}/instances/{instance}", s.deleteInstance)
+	mux.HandleFunc("POST /compute/v1/projects/{project}/zones/{zone}/instances/{instance}/start", s.setInstanceStatus("start", computepb.Instance_RUNNING))
+	mux.HandleFunc("POST /compute/v1/projects/{project}/zones/{zone}/instances/{instance}/stop", s.setInstanceStatus("stop", computepb.Instance_TERMINATED))
+	mux.HandleFunc("POST /compute/v1/projects/{project}/zones/{zone}/instances/{instance}/resume", s.setInstanceStatus("resume", computepb.Instance_RUNNING))
+	mux.HandleFunc("POST /compute/v1/projects/{project}/zones/{zone}/instances/{instance}/setMetadata", s.setInstanceMetadata)
+	mux.HandleFunc("GET /compute/v1/projects/{project}/zones/{zone}/instances/{instance}/getGuestAttributes", s.getGuestAttributes)
+	mux.HandleFunc("GET /compute/v1/projects/{project}/zones/{zone}/operations", s.listOperations)
+	mux.HandleFunc("GET /compute/v1/projects/{project}/zones/{zone}/operations/{operation}", s.getOperation)
+	mux.HandleFunc("GET /compute/v1/projects/{project}/zones", s.listZones)
+	mux.HandleFunc("GET /compute/v1/projects/{project}/aggregated/machineTypes", s.listMachineTypes)
+	mux.HandleFunc("GET /compute/v1/projects/{project}/aggregated/diskTypes", s.listDiskTypes)
+	mux.HandleFunc("GET /compute/v1/projects/{project}/global/images", s.listImages)
+	mux.HandleFunc("GET /compute/v1/projects/{project}/zones/{zone}", s.getZone)
+	mux.HandleFunc("GET /compute/v1/projects/{project}/zones/{zone}/machineTypes/{machineType}", s.getMachineType)
+	mux.HandleFunc("GET /compute/v1/projects/{project}/zones/{zone}/diskTypes/{diskType}", s.getDiskType)
+	mux.HandleFunc("GET /compute/v1/projects/{project}/global/images/{image}", s.getImage)
+	mux.HandleFunc("GET /compute/v1/projects/{project}/global/images/family/{family}", s.getImageFromFamily)
+	mux.HandleFunc("GET /compute/v1/projects/{project}/zones/{zone}/acceleratorTypes/{acceleratorType}", s.getResource)
+	mux.HandleFunc("GET /compute/v1/projects/{project}/zones/{zone}/disks/{disk}", s.getResource)
+	mux.HandleFunc("DELETE /compute/v1/projects/{project}/zones/{zone}/disks/{disk}", s.deleteDisk)
+	mux.HandleFunc("GET /compute/v1/projects/{project}/aggregated/disks", s.listAllDisks)
+	mux.HandleFunc("GET /compute/v1/projects/{project}/global/networks/{network}", s.getResource)
+	mux.HandleFunc("GET /compute/v1/projects/{project}/regions/{region}/subnetworks/{subnetwork}", s.getResource)
+	mux.HandleFunc("GET /compute/v1/projects/{project}/regions/{region}/addresses/{address}", s.getResource)
+	mux.HandleFunc("GET /compute/v1/projects/{project}/regions/{region}/addresses", s.listAddresses)
+	mux.HandleFunc("GET /compute/v1/projects/{project}/aggregated/instances", s.listAllInstances)
+	mux.HandleFunc("GET /compute/v1/projects/{project}/regions/{region}/resourcePolicies/{resourcePolicy}", s.getResource)
+	mux.HandleFunc("POST /compute/v1/projects/{project}/regions/{region}/resourcePolicies", s.insertResourcePolicy)
+	mux.HandleFunc("DELETE /compute/v1/projects/{project}/regions/{region}/resourcePolicies/{resourcePolicy}", s.deleteResourcePolicy)
+	mux.HandleFunc("GET /compute/v1/projects/{project}/regions/{region}/operations/{operation}", s.getOperation)
+	mux.HandleFunc("GET /compute/v1/projects/{project}/global/firewalls/{firewall}", s.getResource)
+	mux.HandleFunc("POST /compute/v1/projects/{project}/global/firewalls", s.insertFirewall)
+	mux.HandleFunc("DELETE /compute/v1/projects/{project}/global/firewalls/{firewall}", s.deleteGlobalResource("firewalls"))
+	mux.HandleFunc("GET /compute/v1/projects/{project}/global/operations/{operation}", s.getOperation)
+	mux.HandleFunc("POST /compute/v1/projects/{project}/global/images", s.insertImage)
+	mux.HandleFunc("GET /compute/v1/projects/{project}/global/snapshots/{snapshot}", s.getResource)
+	mux.HandleFunc("GET /compute/v1/projects/{project}/global/snapshots", s.listSnapshots)
+	mux.HandleFunc("POST /compute/v1/projects/{project}/global/snapshots", s.insertSnapshot)
+	mux.HandleFunc("DELETE /compute/v1/projects/{project}/global/snapshots/{snapshot}", s.deleteGlobalResource("snapshots"))
+	mux.HandleFunc("GET /v1/projects/{project}/serviceAccounts/{serviceAccount}", s.getServiceAccount)
+	s.server = httptest.NewServer(mux)
+
+	return s
+}
+
+// URL returns the base URL of the server.
+func (s *Server) URL() string {
+	return s.server.URL
+}
+
+// ClientOptions returns the options that point GCP clients to the server.
+func (s *Server) ClientOptions() []option.ClientOption {
+	return []option.ClientOption{
+		option.WithEndpoint(s.server.URL),
+		option.WithoutAuthentication(),
+	}
+}
+
+func (s *Server) Close() {
+	s.server.Close()
+}
+
+// SetInstance adds or replaces an instance.
+func (s *Server) SetInstance(project, zone string, instance *computepb.Instance) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	instance = proto.Clone(instance).(*computepb.Instance)
+	instance.Zone = proto.String(zone)
+	s.instances[instanceKey(project, zone, instance.GetName())] = instance
+}
+
+// GetInstance returns a copy of the instance or nil if it doesn't exist.
+func (s *Server) GetInstance(project, zone, name string) *computepb.Instance {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	instance, ok := s.instances[instanceKey(project, zone, name)]
+	if !ok {
+		return nil
+	}
+
+	return proto.Clone(instance).(*computepb.Instance)
+}
+
+// DeleteInstance deletes the instance if it exists, e.g. to simulate another client deleting it.
+func (s *Server) DeleteInstance(project, zone, name string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := instanceKey(project, zone, name)
+	delete(s.instances, key)
+	delete(s.statusQueues, key)
+}
+
+// QueueStatuses sets the statuses the instance goes through. Every get request
+// moves the instance to the next queued status, which simulates transitions
+// like STOPPING -> TERMINATED that GCP performs on its own.
+func (s *Server) QueueStatuses(project, zone, name string, statuses ...computepb.Instance_Status) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := instanceKey(project, zone, name)
+	for _, status := range statuses {
+		s.statusQueues[key] = append(s.statusQueues[key], status.String())
+	}
+}
+
+// Preempt simulates GCP preempting a spot or preemptible instance. The instance is
+// deleted if deleteInstance is set and terminated otherwise.
+func (s *Server) Preempt(project, zone, name string, deleteInstance bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := instanceKey(project, zone, name)
+	instance, ok := s.instances[key]
+	if !ok {
+		return
+	}
+
+	if deleteInstance {
+		delete(s.instances, key)
+		delete(s.statusQueues, key)
+	} else {
+		instance.Status = proto.String(computepb.Instance_TERMINATED.String())
+	}
+
+	s.newOperation(project, zone, "compute.instances.preempted", instance)
+}
+
+// AddZone adds a zone to the catalogue of every project.
+func (s *Server) AddZone(zone *computepb.Zone) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.zones = append(s.zones, zone)
+}
+
+// AddMachineType adds a machine type to a zone of the catalogue.
+func (s *Server) AddMachineType(zone string, machineType *computepb.MachineType) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	machineType.Zone = proto.String(zone)
+	s.machineTypes[zone] = append(s.machineTypes[zone], machineType)
+}
+
+// AddDiskType adds a disk type to a zone of the catalogue.
+func (s *Server) AddDiskType(zone string, diskType *computepb.DiskType) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	diskType.Zone = proto.String(zone)
+	s.diskTypes[zone] = append(s.diskTypes[zone], diskType)
+}
+
+// AddImage adds an image to the images of a project.
+func (s *Server) AddImage(project string, image *computepb.Image) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.images[project] = append(s.images[project], image)
+}
+
+// AddAcceleratorType adds an accelerator type to a zone of a project.
+func (s *Server) AddAcceleratorType(project, zone string, acceleratorType *computepb.AcceleratorType) {
+	acceleratorType.SelfLink = proto.String(fmt.Sprintf("projects/%s/zones/%s/acceleratorTypes/%s", project, zone, acceleratorType.GetName()))
+	s.addResource(acceleratorType.GetSelfLink(), acceleratorType)
+}
+
+// AddNetwork adds a VPC network to a project.
+func (s *Server) AddNetwork(project string, network *computepb.Network) {
+	network.SelfLink = proto.String(fmt.Sprintf("projects/%s/global/networks/%s", project, network.GetName()))
+	s.addResource(network.GetSelfLink(), network)
+}
+
+// AddSubnetwork adds a subnetwork to a region of a project.
+func (s *Server) AddSubnetwork(project, region string, subnetwork *computepb.Subnetwork) {
+	subnetwork.SelfLink = proto.String(fmt.Sprintf("projects/%s/regions/%s/subnetworks/%s", project, region, subnetwork.GetName()))
+	s.addResource(subnetwork.GetSelfLink(), subnetwork)
+}
+
+// AddAddress adds a reserved address to a region of a project.
+func (s *Server) AddAddress(project, region string, address *computepb.Address) {
+	address.SelfLink = proto.String(fmt.Sprintf("projects/%s/regions/%s/addresses/%s", project, region, address.GetName()))
+	s.addResource(address.GetSelfLink(), address)
+}
+
+// AddSnapshot adds a snapshot to a project.
+func (s *Server) AddSnapshot(project string, snapshot *computepb.Snapshot) {
+	snapshot.SelfLink = proto.String(fmt.Sprintf("projects/%s/global/snapshots/%s", project, snapshot.GetName()))
+	s.addResource(snapshot.GetSelfLink(), snapshot)
+}
+
+// AddServiceAccount adds an IAM service account. It can be looked up in any project, like with the - wildcard.
+func (s *Server) AddServiceAccount(project string, serviceAccount *iam.ServiceAccount) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	serviceAccount.Name = fmt.Sprintf("projects/%s/serviceAccounts/%s", project, serviceAccount.Email)
+	serviceAccount.ProjectId = project
+	s.serviceAccounts[serviceAccount.Email] = serviceAccount
+}
+
+// SetGuestAttribute sets a guest attribute of an instance, as written by the VM. The key is <namespace>/<key>.
+func (s *Server) SetGuestAttribute(project, zone, instance, key, value string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	instanceKey := instanceKey(project, zone, instance)
+	if s.guestAttributes[instanceKey] == nil {
+		s.guestAttributes[instanceKey] = map[string]string{}
+	}
+	s.guestAttributes[instanceKey][key] = value
+}
+
+func (s *Server) addResource(path string, resource proto.Message) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.resources[path] = resource
+}
+
+// AddDisk adds a disk that isn't attached to any instance.
+func (s *Server) AddDisk(project, zone string, disk *computepb.Disk) {
+	disk.SelfLink = proto.String(diskPath(project, zone, disk.GetName()))
+	s.addResource(disk.GetSelfLink(), disk)
+}
+
+// GetDisk returns the disk or nil if it doesn't exist.
+func (s *Server) GetDisk(project, zone, name string) *computepb.Disk {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	disk, ok := s.resources[diskPath(project, zone, name)]
+	if !ok {
+		return nil
+	}
+
+	return proto.Clone(disk).(*computepb.Disk)
+}
+
+// GetResourcePolicy returns a copy of a resource policy, or nil if it doesn't exist.
+func (s *Server) GetResourcePolicy(project, region, name string) *computepb.ResourcePolicy {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	policy, ok := s.resources[fmt.Sprintf("projects/%s/regions/%s/resourcePolicies/%s", project, region, name)]
+	if !ok {
+		return nil
+	}
+
+	return proto.Clone(policy).(*computepb.ResourcePolicy)
+}
+
+// DeleteFirewall deletes the firewall rule if it exists.
+func (s *Server) DeleteFirewall(project, name string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.resources, fmt.Sprintf("projects/%s/global/firewalls/%s", project, name))
+}
+
+// OnInsertInstance runs the function before every instance insert is handled.
+func (s *Server) OnInsertInstance(f func()) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.onInsertInstance = f
+}
+
+// GetFirewall returns the firewall rule or nil if it doesn't exist.
+func (s *Server) GetFirewall(project, name string) *computepb.Firewall {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	firewall, ok := s.resources[fmt.Sprintf("projects/%s/global/firewalls/%s", project, name)]
+	if !ok {
+		return nil
+	}
+
+	return proto.Clone(firewall).(*computepb.Firewall)
+}
+
+// Requests returns the handled requests in the "<action> <resource>" format, e.g. "start daytona-123".
+func (s *Server) Requests() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]string{}, s.requests...)
+}
+
+func (s *Server) listInstances(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	project, zone := r.PathValue("project"), r.PathValue("zone")
+	s.requests = append(s.requests, "list "+zone)
+
+	prefix := instanceKey(project, zone, "")
+	list := &computepb.InstanceList{}
+	for key, instance := range s.instances {
+		if strings.HasPrefix(key, prefix) {
+			list.Items = append(list.Items, instance)
+		}
+	}
+	sort.Slice(list.Items, func(i, j int) bool {
+		return list.Items[i].GetName() < list.Items[j].GetName()
+	})
+
+	writeMessage(w, list)
+}
+
+// listAllInstances lists the instances of every zone of a project.
+func (s *Server) listAllInstances(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	project := r.PathValue("project")
+	s.requests = append(s.requests, "list "+project)
+
+	list := &computepb.InstanceAggregatedList{Items: map[string]*computepb.InstancesScopedList{}}
+	for key, instance := range s.instances {
+		if !strings.HasPrefix(key, project+"/") {
+			continue
+		}
+
+		scope := "zones/" + instance.GetZone()
+		if list.Items[scope] == nil {
+			list.Items[scope] = &computepb.InstancesScopedList{}
+		}
+		list.Items[scope].Instances = append(list.Items[scope].Instances, instance)
+	}
+
+	writeMessage(w, list)
+}
+
+// listAllDisks lists the disks of a project in every zone. Filters are ignored.
+func (s *Server) listAllDisks(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	prefix := fmt.Sprintf("projects/%s/zones/", r.PathValue("project"))
+	list := &computepb.DiskAggregatedList{Items: map[string]*computepb.DisksScopedList{}}
+	for path, resource := range s.resources {
+		disk, ok := resource.(*computepb.Disk)
+		if !ok || !strings.HasPrefix(path, prefix) {
+			continue
+		}
+
+		zone, _, _ := strings.Cut(strings.TrimPrefix(path, prefix), "/")
+		scope := "zones/" + zone
+		if list.Items[scope] == nil {
+			list.Items[scope] = &computepb.DisksScopedList{}
+		}
+		list.Items[scope].Disks = append(list.Items[scope].Disks, disk)
+	}
+
+	writeMessage(w, list)
+}
+
+// deleteDisk deletes a disk. Like GCP it refuses to delete a disk that is attached to an instance.
+func (s *Server) deleteDisk(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	project, zone, name := r.PathValue("project"), r.PathValue("zone"), r.PathValue("disk")
+	s.requests = append(s.requests, "delete "+name)
+
+	path := diskPath(project, zone, name)
+	if _, ok := s.resources[path]; !ok {
+		writeError(w, http.StatusNotFound, fmt.Sprintf("The resource '%s' was not found", path))
+		return
+	}
+	for _, instance := range s.instances {
+		for _, disk := range instance.GetDisks() {
+			if disk.GetSource() == path {
+				writeError(w, http.StatusBadRequest, fmt.Sprintf("The disk resource '%s' is already being used by '%s'", path, instance.GetName()))
+				return
+			}
+		}
+	}
+	delete(s.resources, path)
+
+	writeMessage(w, s.newZoneOperation(zone, "delete", path))
+}
+
+func (s *Server) insertInstance(w http.ResponseWriter, r *http.Request) {
+	instance := &computepb.Instance{}
+	err := readMessage(r, instance)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	s.mu.Lock()
+	onInsertInstance := s.onInsertInstance
+	s.mu.Unlock()
+	if onInsertInstance != nil {
+		onInsertInstance()
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	project, zone := r.PathValue("project"), r.PathValue("zone")
+	s.requests = append(s.requests, "insert "+instance.GetName())
+
+	key := instanceKey(project, zone, instance.GetName())
+	if _, ok := s.instances[key]; ok {
+		writeError(w, http.StatusConflict, fmt.Sprintf("The resource 'projects/%s/zones/%s/instances/%s' already exists", project, zone, instance.GetName()))
+		return
+	}
+
+	// New disks are created as separate resources, so that they can outlive the instance.
+	// Like in GCP, an unnamed boot disk is named after the instance.
+	for _, disk := range instance.GetDisks() {
+		if disk.GetInitializeParams() == nil {
+			continue
+		}
+
+		name := disk.GetInitializeParams().GetDiskName()
+		if name == "" && disk.GetBoot() {
+			name = instance.GetName()
+		}
+		if name == "" {
+			continue
+		}
+
+		path := diskPath(project, zone, name)
+		s.resources[path] = &computepb.Disk{
+			Name:              proto.String(name),
+			SizeGb:            proto.Int64(disk.GetInitializeParams().GetDiskSizeGb()),
+			Type:              proto.String(disk.GetInitializeParams().GetDiskType()),
+			SourceImage:       disk.GetInitializeParams().SourceImage,
+			SourceSnapshot:    disk.GetInitializeParams().SourceSnapshot,
+			Labels:            disk.GetInitializeParams().GetLabels(),
+			SelfLink:          proto.String(path),
+			CreationTimestamp: proto.String(time.Now().Format(time.RFC3339)),
+		}
+		disk.Source = proto.String(path)
+	}
+
+	s.instanceCount++
+	instance.Id = proto.Uint64(s.instanceCount)
+	instance.Zone = proto.String(zone)
+	instance.Status = proto.String(computepb.Instance_RUNNING.String())
+	instance.CpuPlatform = proto.String("Intel Broadwell")
+	instance.CreationTimestamp = proto.String(time.Now().Format(time.RFC3339))
+	instance.LastStartTimestamp = instance.CreationTimestamp
+	instance.SelfLink = proto.String(fmt.Sprintf("%s/compute/v1/projects/%s/zones/%s/instances/%s", s.server.URL, project, zone, instance.GetName()))
+	s.instances[key] = instance
+
+	writeMessage(w, s.newOperation(project, zone, "insert", instance))
+}
+
+func (s *Server) deleteInstance(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	project, zone, name := r.PathValue("project"), r.PathValue("zone"), r.PathValue("instance")
+	s.requests = append(s.requests, "delete "+name)
+
+	key := instanceKey(project, zone, name)
+	instance, ok := s.instances[key]
+	if !ok {
+		writeError(w, http.StatusNotFound, fmt.Sprintf("The resource '%s' was not found", r.URL.Path))
+		return
+	}
+	delete(s.instances, key)
+	delete(s.statusQueues, key)
+	for _, disk := range instance.GetDisks() {
+		if disk.GetAutoDelete() {
+			delete(s.resources, disk.GetSource())
+		}
+	}
+
+	writeMessage(w, s.newOperation(project, zone, "delete", instance))
+}
+
+func (s *Server) getInstance(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := instanceKey(r.PathValue("project"), r.PathValue("zone"), r.PathValue("instance"))
+	s.requests = append(s.requests, "get "+r.PathValue("instance"))
+
+	instance, ok := s.instances[key]
+	if !ok {
+		writeError(w, http.StatusNotFound, fmt.Sprintf("The resource '%s' was not found", r.URL.Path))
+		return
+	}
+
+	if queue := s.statusQueues[key]; len(queue) > 0 {
+		instance.Status = proto.String(queue[0])
+		s.statusQueues[key] = queue[1:]
+	}
+
+	writeMessage(w, instance)
+}
+
+func (s *Server) setInstanceStatus(action string, status computepb.Instance_Status) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		project, zone, name := r.PathValue("project"), r.PathValue("zone"), r.PathValue("instance")
+		s.requests = append(s.requests, action+" "+name)
+
+		instance, ok := s.instances[instanceKey(project, zone, name)]
+		if !ok {
+			writeError(w, http.StatusNotFound, fmt.Sprintf("The resource '%s' was not found", r.URL.Path))
+			return
+		}
+		instance.Status = proto.String(status.String())
+		if status == computepb.Instance_RUNNING {
+			instance.LastStartTimestamp = proto.String(time.Now().Format(time.RFC3339))
+		}
+
+		writeMessage(w, s.newOperation(project, zone, action, instance))
+	}
+}
+
+// setInstanceMetadata replaces the metadata of an instance. Like GCP it rejects a stale fingerprint.
+func (s *Server) setInstanceMetadata(w http.ResponseWriter, r *http.Request) {
+	metadata := &computepb.Metadata{}
+	err := readMessage(r, metadata)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	project, zone, name := r.PathValue("project"), r.PathValue("zone"), r.PathValue("instance")
+	s.requests = append(s.requests, "setMetadata "+name)
+
+	instance, ok := s.instances[instanceKey(project, zone, name)]
+	if !ok {
+		writeError(w, http.StatusNotFound, fmt.Sprintf("The resource '%s' was not found", r.URL.Path))
+		return
+	}
+	if metadata.GetFingerprint() != instance.GetMetadata().GetFingerprint() {
+		writeError(w, http.StatusPreconditionFailed, "Supplied fingerprint does not match current metadata fingerprint")
+		return
+	}
+	metadata.Fingerprint = proto.String(fmt.Sprintf("fingerprint-%d", len(s.requests)))
+	instance.Metadata = metadata
+
+	writeMessage(w, s.newOperation(project, zone, "setMetadata", instance))
+}
+
+func (s *Server) getOperation(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	operation, ok := s.operations[r.PathValue("operation")]
+	if !ok {
+		writeError(w, http.StatusNotFound, fmt.Sprintf("The resource '%s' was not found", r.URL.Path))
+		return
+	}
+
+	writeMessage(w, operation)
+}
+
+// listOperations lists the operations of a zone. Filters are ignored.
+func (s *Server) listOperations(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	list := &computepb.OperationList{}
+	for i := 1; i <= s.operationCount; i++ {
+		operation, ok := s.operations[fmt.Sprintf("operation-%d", i)]
+		if ok && operation.GetZone() == r.PathValue("zone") {
+			list.Items = append(list.Items, operation)
+		}
+	}
+
+	writeMessage(w, list)
+}
+
+func (s *Server) listZones(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	writeMessage(w, &computepb.ZoneList{Items: s.zones})
+}
+
+func (s *Server) listMachineTypes(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	list := &computepb.MachineTypeAggregatedList{Items: map[string]*computepb.MachineTypesScopedList{}}
+	for zone, machineTypes := range s.machineTypes {
+		list.Items["zones/"+zone] = &computepb.MachineTypesScopedList{MachineTypes: machineTypes}
+	}
+
+	writeMessage(w, list)
+}
+
+func (s *Server) listDiskTypes(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	list := &computepb.DiskTypeAggregatedList{Items: map[string]*computepb.DiskTypesScopedList{}}
+	for zone, diskTypes := range s.diskTypes {
+		list.Items["zones/"+zone] = &computepb.DiskTypesScopedList{DiskTypes: diskTypes}
+	}
+
+	writeMessage(w, list)
+}
+
+// listImages lists the images of a project. Only the `deprecated.state != <state>` filter is supported.
+func (s *Server) listImages(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	excludedState, filtered := strings.CutPrefix(r.URL.Query().Get("filter"), "deprecated.state != ")
+
+	list := &computepb.ImageList{}
+	for _, image := range s.images[r.PathValue("project")] {
+		if !filtered || image.GetDeprecated().GetState() != excludedState {
+			list.Items = append(list.Items, image)
+		}
+	}
+
+	writeMessage(w, list)
+}
+
+func (s *Server) getZone(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, zone := range s.zones {
+		if zone.GetName() == r.PathValue("zone") {
+			writeMessage(w, zone)
+			return
+		}
+	}
+
+	writeError(w, http.StatusNotFound, fmt.Sprintf("The resource '%s' was not found", r.URL.Path))
+}
+
+func (s *Server) getMachineType(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, machineType := range s.machineTypes[r.PathValue("zone")] {
+		if machineType.GetName() == r.PathValue("machineType") {
+			writeMessage(w, machineType)
+			return
+		}
+	}
+
+	writeError(w, http.StatusNotFound, fmt.Sprintf("The resource '%s' was not found", r.URL.Path))
+}
+
+func (s *Server) getDiskType(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, diskType := range s.diskTypes[r.PathValue("zone")] {
+		if diskType.GetName() == r.PathValue("diskType") {
+			writeMessage(w, diskType)
+			return
+		}
+	}
+
+	writeError(w, http.StatusNotFound, fmt.Sprintf("The resource '%s' was not found", r.URL.Path))
+}
+
+func (s *Server) getImage(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, image := range s.images[r.PathValue("project")] {
+		if image.GetName() == r.PathValue("image") {
+			writeMessage(w, image)
+			return
+		}
+	}
+
+	writeError(w, http.StatusNotFound, fmt.Sprintf("The resource '%s' was not found", r.URL.Path))
+}
+
+// getImageFromFamily returns the last added image of the family that isn't deprecated.
+func (s *Server) getImageFromFamily(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	images := s.images[r.PathValue("project")]
+	for i := len(images) - 1; i >= 0; i-- {
+		if images[i].GetFamily() == r.PathValue("family") && images[i].GetDeprecated() == nil {
+			writeMessage(w, images[i])
+			return
+		}
+	}
+
+	writeError(w, http.StatusNotFound, fmt.Sprintf("The resource '%s' was not found", r.URL.Path))
+}
+
+// getResource serves the resources added with addResource by their path.
+func (s *Server) getResource(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	resource, ok := s.resources[strings.TrimPrefix(r.URL.Path, "/compute/v1/")]
+	if !ok {
+		writeError(w, http.StatusNotFound, fmt.Sprintf("The resource '%s' was not found", r.URL.Path))
+		return
+	}
+
+	writeMessage(w, resource)
+}
+
+// getGuestAttributes returns a single guest attribute of an instance. Only the variableKey parameter is supported.
+func (s *Server) getGuestAttributes(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := r.URL.Query().Get("variableKey")
+	value, ok := s.guestAttributes[instanceKey(r.PathValue("project"), r.PathValue("zone"), r.PathValue("instance"))][key]
+	if !ok {
+		writeError(w, http.StatusNotFound, fmt.Sprintf("The resource '%s' was not found", key))
+		return
+	}
+
+	writeMessage(w, &computepb.GuestAttributes{
+		VariableKey:   proto.String(key),
+		VariableValue: proto.String(value),
+	})
+}
+
+// getServiceAccount serves the IAM service accounts, which aren't Compute Engine protos.
+func (s *Server) getServiceAccount(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	serviceAccount, ok := s.serviceAccounts[r.PathValue("serviceAccount")]
+	if !ok {
+		writeError(w, http.StatusNotFound, fmt.Sprintf("Unknown service account '%s'", r.PathValue("serviceAccount")))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(serviceAccount)
+}
+
+// listAddresses lists the addresses of a region. Only the `address = "<ip>"` filter is supported.
+func (s *Server) listAddresses(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	prefix := fmt.Sprintf("projects/%s/regions/%s/addresses/", r.PathValue("project"), r.PathValue("region"))
+	filter := strings.Trim(strings.TrimPrefix(r.URL.Query().Get("filter"), "address = "), `"`)
+
+	list := &computepb.AddressList{}
+	for path, resource := range s.resources {
+		address, ok := resource.(*computepb.Address)
+		if !ok || !strings.HasPrefix(path, prefix) {
+			continue
+		}
+		if filter == "" || address.GetAddress() == filter {
+			list.Items = append(list.Items, address)
+		}
+	}
+
+	writeMessage(w, list)
+}
+
+func (s *Server) insertFirewall(w http.ResponseWriter, r *http.Request) {
+	firewall := &computepb.Firewall{}
+	err := readMessage(r, firewall)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	project := r.PathValue("project")
+	s.requests = append(s.requests, "insert "+firewall.GetName())
+
+	path := fmt.Sprintf("projects/%s/global/firewalls/%s", project, firewall.GetName())
+	if _, ok := s.resources[path]; ok {
+		writeError(w, http.StatusConflict, fmt.Sprintf("The resource '%s' already exists", path))
+		return
+	}
+	firewall.SelfLink = proto.String(path)
+	firewall.CreationTimestamp = proto.String(time.Now().Format(time.RFC3339))
+	s.resources[path] = firewall
+
+	writeMessage(w, s.newGlobalOperation("insert", path))
+}
+
+func (s *Server) insertResourcePolicy(w http.ResponseWriter, r *http.Request) {
+	policy := &computepb.ResourcePolicy{}
+	err := readMessage(r, policy)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	project, region := r.PathValue("project"), r.PathValue("region")
+	s.requests = append(s.requests, "insert "+policy.GetName())
+
+	path := fmt.Sprintf("projects/%s/regions/%s/resourcePolicies/%s", project, region, policy.GetName())
+	if _, ok := s.resources[path]; ok {
+		writeError(w, http.StatusConflict, fmt.Sprintf("The resource '%s' already exists", path))
+		return
+	}
+	policy.Region = proto.String(region)
+	policy.SelfLink = proto.String(path)
+	s.resources[path] = policy
+
+	writeMessage(w, s.newRegionOperation(region, "insert", path))
+}
+
+// deleteResourcePolicy deletes a resource policy, which like in GCP fails while it is attached to an instance.
+func (s *Server) deleteResourcePolicy(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	project, region, name := r.PathValue("project"), r.PathValue("region"), r.PathValue("resourcePolicy")
+	s.requests = append(s.requests, "delete "+name)
+
+	path := fmt.Sprintf("projects/%s/regions/%s/resourcePolicies/%s", project, region, name)
+	if _, ok := s.resources[path]; !ok {
+		writeError(w, http.StatusNotFound, fmt.Sprintf("The resource '%s' was not found", path))
+		return
+	}
+	for _, instance := range s.instances {
+		for _, policy := range instance.GetResourcePolicies() {
+			if strings.HasSuffix(policy, path) {
+				writeError(w, http.StatusBadRequest, fmt.Sprintf("The resource_policy resource '%s' is already being used by '%s'", path, instance.GetName()))
+				return
+			}
+		}
+	}
+	delete(s.resources, path)
+
+	writeMessage(w, s.newRegionOperation(region, "delete", path))
+}
+
+// insertImage creates an image of the source disk.
+func (s *Server) insertImage(w http.ResponseWriter, r *http.Request) {
+	image := &computepb.Image{}
+	err := readMessage(r, image)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	project := r.PathValue("project")
+	s.requests = append(s.requests, "insert "+image.GetName())
+
+	disk, ok := s.resources[image.GetSourceDisk()].(*computepb.Disk)
+	if !ok {
+		writeError(w, http.StatusNotFound, fmt.Sprintf("The resource '%s' was not found", image.GetSourceDisk()))
+		return
+	}
+
+	image.DiskSizeGb = proto.Int64(disk.GetSizeGb())
+	image.Status = proto.String(computepb.Image_READY.String())
+	image.SelfLink = proto.String(fmt.Sprintf("projects/%s/global/images/%s", project, image.GetName()))
+	s.images[project] = append(s.images[project], image)
+
+	writeMessage(w, s.newGlobalOperation("insert", image.GetSelfLink()))
+}
+
+// insertSnapshot creates a READY snapshot of the source disk.
+func (s *Server) insertSnapshot(w http.ResponseWriter, r *http.Request) {
+	snapshot := &computepb.Snapshot{}
+	err := readMessage(r, snapshot)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	project := r.PathValue("project")
+	s.requests = append(s.requests, "insert "+snapshot.GetName())
+
+	disk, ok := s.resources[snapshot.GetSourceDisk()].(*computepb.Disk)
+	if !ok {
+		writeError(w, http.StatusNotFound, fmt.Sprintf("The resource '%s' was not found", snapshot.GetSourceDisk()))
+		return
+	}
+
+	path := fmt.Sprintf("projects/%s/global/snapshots/%s", project, snapshot.GetName())
+	if _, ok := s.resources[path]; ok {
+		writeError(w, http.StatusConflict, fmt.Sprintf("The resource '%s' already exists", path))
+		return
+	}
+	snapshot.DiskSizeGb = proto.Int64(disk.GetSizeGb())
+	snapshot.Status = proto.String(computepb.Snapshot_READY.String())
+	snapshot.SelfLink = proto.String(path)
+	s.resources[path] = snapshot
+
+	writeMessage(w, s.newGlobalOperation("insert", path))
+}
+
+// listSnapshots lists the snapshots of a project. Only the `labels.<key> = "<value>"` filter is supported.
+func (s *Server) listSnapshots(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	prefix := fmt.Sprintf("projects/%s/global/snapshots/", r.PathValue("project"))
+	key, value, _ := strings.Cut(strings.TrimPrefix(r.URL.Query().Get("filter"), "labels."), " = ")
+	value = strings.Trim(value, `"`)
+
+	list := &computepb.SnapshotList{}
+	for path, resource := range s.resources {
+		snapshot, ok := resource.(*computepb.Snapshot)
+		if !ok || !strings.HasPrefix(path, prefix) {
+			continue
+		}
+		if key == "" || snapshot.GetLabels()[key] == value {
+			list.Items = append(list.Items, snapshot)
+		}
+	}
+	sort.Slice(list.Items, func(i, j int) bool {
+		return list.Items[i].GetName() < list.Items[j].GetName()
+	})
+
+	writeMessage(w, list)
+}
+
+// deleteGlobalResource deletes a global resource of the kind, e.g. firewalls, that was added with addResource.
+func (s *Server) deleteGlobalResource(kind string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		project, name := r.PathValue("project"), r.URL.Path[strings.LastIndex(r.URL.Path, "/")+1:]
+		s.requests = append(s.requests, "delete "+name)
+
+		path := fmt.Sprintf("projects/%s/global/%s/%s", project, kind, name)
+		if _, ok := s.resources[path]; !ok {
+			writeError(w, http.StatusNotFound, fmt.Sprintf("The resource '%s' was not found", path))
+			return
+		}
+		delete(s.resources, path)
+
+		writeMessage(w, s.newGlobalOperation("delete", path))
+	}
+}
+
+// newGlobalOperation returns a finished operation on a global resource.
+func (s *Server) newGlobalOperation(operationType, targetLink string) *computepb.Operation {
+	s.operationCount++
+	name := fmt.Sprintf("operation-%d", s.operationCount)
+
+	operation := &computepb.Operation{
+		Name:          proto.String(name),
+		OperationType: proto.String(operationType),
+		Status:        computepb.Operation_DONE.Enum(),
+		TargetLink:    proto.String(targetLink),
+	}
+	s.operations[name] = operation
+
+	return operation
+}
+
+// newRegionOperation returns a finished operation on a regional resource.
+func (s *Server) newRegionOperation(region, operationType, targetLink string) *computepb.Operation {
+	operation := s.newGlobalOperation(operationType, targetLink)
+	operation.Region = proto.String(region)
+
+	return operation
+}
+
+func (s *Server) newOperation(project, zone, operationType string, instance *computepb.Instance) *computepb.Operation {
+	operation := s.newZoneOperation(zone, operationType, fmt.Sprintf("projects/%s/zones/%s/instances/%s", project, zone, instance.GetName()))
+	operation.TargetId = proto.Uint64(instance.GetId())
+
+	return operation
+}
+
+// newZoneOperation returns a finished operation on a zonal resource.
+func (s *Server) newZoneOperation(zone, operationType, targetLink string) *computepb.Operation {
+	s.operationCount++
+	name := fmt.Sprintf("operation-%d", s.operationCount)
+
+	operation := &computepb.Operation{
+		Name:          proto.String(name),
+		OperationType: proto.String(operationType),
+		Status:        computepb.Operation_DONE.Enum(),
+		Zone:          proto.String(zone),
+		InsertTime:    proto.String(time.Now().Format(time.RFC3339)),
+		TargetLink:    proto.String(targetLink),
+	}
+	s.operations[name] = operation
+
+	return operation
+}
+
+func diskPath(project, zone, name string) string {
+	return fmt.Sprintf("projects/%s/zones/%s/disks/%s", project, zone, name)
+}
+
+func instanceKey(project, zone, name string) string {
+	return fmt.Sprintf("%s/%s/%s", project, zone, name)
+}
+
+func writeMessage(w http.ResponseWriter, message proto.Message) {
+	body, err := protojson.Marshal(message)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = w.Write(body)
+}
+
+func writeError(w http.ResponseWriter, code int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(map[string]any{
+		"error": map[string]any{
+			"code":    code,
+			"message": message,
+		},
+	})
+}
+
+// readMessage decodes a request body into the message.
+func readMessage(r *http.Request, message proto.Message) error {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		return err
+	}
+
+	return protojson.UnmarshalOptions{DiscardUnknown: true}.Unmarshal(body, message)
+}
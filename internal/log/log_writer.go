@@ -1,6 +1,7 @@
 package log
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"time"
@@ -22,7 +23,9 @@ func (w *InfoLogWriter) Write(p []byte) (n int, err error) {
 	return len(p), nil
 }
 
-func ShowSpinner(logWriter io.Writer, startStatement, endStatement string) chan struct{} {
+// ShowSpinner writes a spinner with the start statement to the log writer until the returned channel is closed
+// and then writes the end statement. If the context is done first, the spinner is cleared without the end statement.
+func ShowSpinner(ctx context.Context, logWriter io.Writer, startStatement, endStatement string) chan struct{} {
 	stopSpinnerChan := make(chan struct{})
 	go func() {
 		spinner := []string{"⠋", "⠙", "⠹", "⠸", "⠼", "⠴", "⠦", "⠧", "⠇", "⠏"}
@@ -32,6 +35,15 @@ func ShowSpinner(logWriter io.Writer, startStatement, endStatement string) chan
 				logWriter.Write([]byte("\r\033[K"))
 				logWriter.Write([]byte(endStatement + "\n"))
 				return
+			case <-ctx.Done():
+				logWriter.Write([]byte("\r\033[K"))
+				// The spinner may have been stopped right before the context was canceled
+				select {
+				case <-stopSpinnerChan:
+					logWriter.Write([]byte(endStatement + "\n"))
+				default:
+				}
+				return
 			case <-time.After(200 * time.Millisecond):
 				logWriter.Write([]byte(fmt.Sprintf("%s %s\r", spinner[i%len(spinner)], startStatement)))
 			}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"fmt"
 	"os"
 
 	"github.com/daytonaio/daytona/pkg/provider"
@@ -11,17 +12,50 @@ import (
 	p "github.com/daytonaio/daytona-provider-gcp/pkg/provider"
 )
 
+// commands are run by the provider binary instead of serving the plugin, for the operations the Daytona server doesn't call.
+var commands = map[string]func(args []string) error{
+	collectOrphansCommand:    collectOrphans,
+	snapshotWorkspaceCommand: snapshotWorkspace,
+	listSnapshotsCommand:     listSnapshots,
+	deleteSnapshotCommand:    deleteSnapshot,
+}
+
 func main() {
+	if len(os.Args) > 1 && commands[os.Args[1]] != nil {
+		err := commands[os.Args[1]](os.Args[2:])
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+
 	logger := hclog.New(&hclog.LoggerOptions{
 		Level:      hclog.Trace,
 		Output:     os.Stderr,
 		JSONFormat: true,
 	})
+	gcpProvider := &p.GCPProvider{}
+	defer gcpProvider.Close()
+
 	hc_plugin.Serve(&hc_plugin.ServeConfig{
 		HandshakeConfig: manager.ProviderHandshakeConfig,
 		Plugins: map[string]hc_plugin.Plugin{
-			"gcp-provider": &provider.ProviderPlugin{Impl: &p.GCPProvider{}},
+			"gcp-provider": &provider.ProviderPlugin{Impl: gcpProvider},
 		},
 		Logger: logger,
 	})
 }
+
+// withProvider runs the command with an initialized provider, which isn't started by the Daytona server.
+func withProvider(command func(gcpProvider *p.GCPProvider) error) error {
+	gcpProvider := &p.GCPProvider{}
+	defer gcpProvider.Close()
+
+	_, err := gcpProvider.Initialize(provider.InitializeProviderRequest{})
+	if err != nil {
+		return err
+	}
+
+	return command(gcpProvider)
+}
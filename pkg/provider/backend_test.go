@@ -0,0 +1,482 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+
+	"cloud.google.com/go/compute/apiv1/computepb"
+	gcputil "github.com/daytonaio/daytona-provider-gcp/pkg/provider/util"
+	"github.com/daytonaio/daytona-provider-gcp/pkg/types"
+	"github.com/daytonaio/daytona/pkg/provider"
+	"github.com/daytonaio/daytona/pkg/workspace"
+	"google.golang.org/protobuf/proto"
+)
+
+var errBackend = errors.New("backend error")
+
+// memoryBackend is an in-memory gcputil.ComputeBackend. Setting one of the error
+// fields makes the matching call fail.
+type memoryBackend struct {
+	instances map[string]*computepb.Instance
+	snapshots []*computepb.Snapshot
+	calls     []string
+
+	createErr error
+	startErr  error
+	stopErr   error
+	deleteErr error
+	getErr    error
+	// createsInstanceOnErr makes CreateWorkspace fail with createErr after the instance was created.
+	createsInstanceOnErr bool
+
+	suggestions    *types.Suggestions
+	suggestionsErr error
+	validateErr    error
+}
+
+func newMemoryBackend() *memoryBackend {
+	return &memoryBackend{
+		instances: map[string]*computepb.Instance{},
+	}
+}
+
+func (b *memoryBackend) CreateWorkspace(ctx context.Context, workspace *workspace.Workspace, opts *types.TargetOptions, initScript string, tx *gcputil.Transaction, logWriter io.Writer) error {
+	b.calls = append(b.calls, "create")
+	if b.createErr != nil && !b.createsInstanceOnErr {
+		return b.createErr
+	}
+
+	b.instances[workspace.Id] = &computepb.Instance{
+		Id:     proto.Uint64(uint64(len(b.instances) + 1)),
+		Name:   proto.String("daytona-" + workspace.Id),
+		Zone:   proto.String(opts.Zone),
+		Status: proto.String(computepb.Instance_RUNNING.String()),
+	}
+	tx.Record("compute instance daytona-"+workspace.Id, func(ctx context.Context, logWriter io.Writer) error {
+		b.calls = append(b.calls, "rollback")
+		delete(b.instances, workspace.Id)
+		return nil
+	})
+
+	return b.createErr
+}
+
+func (b *memoryBackend) StartWorkspace(ctx context.Context, workspace *workspace.Workspace, opts *types.TargetOptions, logWriter io.Writer) error {
+	b.calls = append(b.calls, "start")
+	if b.startErr != nil {
+		return b.startErr
+	}
+
+	return b.setStatus(workspace.Id, computepb.Instance_RUNNING)
+}
+
+func (b *memoryBackend) StopWorkspace(ctx context.Context, workspace *workspace.Workspace, opts *types.TargetOptions, logWriter io.Writer) error {
+	b.calls = append(b.calls, "stop")
+	if b.stopErr != nil {
+		return b.stopErr
+	}
+
+	return b.setStatus(workspace.Id, computepb.Instance_TERMINATED)
+}
+
+func (b *memoryBackend) DeleteWorkspace(ctx context.Context, workspace *workspace.Workspace, opts *types.TargetOptions, logWriter io.Writer) error {
+	b.calls = append(b.calls, "delete")
+	if b.deleteErr != nil {
+		return b.deleteErr
+	}
+
+	if _, ok := b.instances[workspace.Id]; !ok {
+		return errors.New("instance not found")
+	}
+	delete(b.instances, workspace.Id)
+	return nil
+}
+
+func (b *memoryBackend) RemoveWorkspaceSecrets(ctx context.Context, workspace *workspace.Workspace, opts *types.TargetOptions) error {
+	b.calls = append(b.calls, "removeSecrets")
+	return nil
+}
+
+func (b *memoryBackend) GetComputeInstance(ctx context.Context, workspace *workspace.Workspace, opts *types.TargetOptions) (*computepb.Instance, error) {
+	b.calls = append(b.calls, "get")
+	if b.getErr != nil {
+		return nil, b.getErr
+	}
+
+	instance, ok := b.instances[workspace.Id]
+	if !ok {
+		return nil, errors.New("instance not found")
+	}
+	return instance, nil
+}
+
+func (b *memoryBackend) GetWorkspaceMetadata(ctx context.Context, workspace *workspace.Workspace, opts *types.TargetOptions) (*types.WorkspaceMetadata, error) {
+	instance, err := b.GetComputeInstance(ctx, workspace, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	metadata := types.ToWorkspaceMetadata(instance)
+	return &metadata, nil
+}
+
+func (b *memoryBackend) SnapshotWorkspace(ctx context.Context, workspace *workspace.Workspace, opts *types.TargetOptions, logWriter io.Writer) ([]string, error) {
+	b.calls = append(b.calls, "snapshot")
+	if _, ok := b.instances[workspace.Id]; !ok {
+		return nil, errors.New("instance not found")
+	}
+
+	name := "daytona-" + workspace.Id + "-boot"
+	b.snapshots = append(b.snapshots, &computepb.Snapshot{
+		Name:   proto.String(name),
+		Labels: map[string]string{"daytona-workspace-id": workspace.Id},
+	})
+	return []string{name}, nil
+}
+
+func (b *memoryBackend) ListWorkspaceSnapshots(ctx context.Context, workspaceId string, opts *types.TargetOptions) ([]*computepb.Snapshot, error) {
+	b.calls = append(b.calls, "listSnapshots")
+
+	snapshots := []*computepb.Snapshot{}
+	for _, snapshot := range b.snapshots {
+		if snapshot.GetLabels()["daytona-workspace-id"] == workspaceId {
+			snapshots = append(snapshots, snapshot)
+		}
+	}
+	return snapshots, nil
+}
+
+func (b *memoryBackend) DeleteSnapshot(ctx context.Context, name string, opts *types.TargetOptions, logWriter io.Writer) error {
+	b.calls = append(b.calls, "deleteSnapshot")
+
+	for i, snapshot := range b.snapshots {
+		if snapshot.GetName() == name {
+			b.snapshots = append(b.snapshots[:i], b.snapshots[i+1:]...)
+			return nil
+		}
+	}
+	return errors.New("snapshot not found")
+}
+
+func (b *memoryBackend) CollectOrphans(ctx context.Context, opts *types.TargetOptions, orphanOpts gcputil.OrphanOptions, logWriter io.Writer) (*gcputil.OrphanSummary, error) {
+	b.calls = append(b.calls, "collectOrphans")
+	return &gcputil.OrphanSummary{}, nil
+}
+
+func (b *memoryBackend) DiscoverSuggestions(ctx context.Context, opts *types.TargetOptions) (*types.Suggestions, error) {
+	b.calls = append(b.calls, "discover")
+	if b.suggestionsErr != nil {
+		return nil, b.suggestionsErr
+	}
+
+	return b.suggestions, nil
+}
+
+func (b *memoryBackend) ValidateTargetOptions(ctx context.Context, opts *types.TargetOptions) error {
+	b.calls = append(b.calls, "validate")
+	return b.validateErr
+}
+
+func (b *memoryBackend) Close() error {
+	return nil
+}
+
+func (b *memoryBackend) setStatus(workspaceId string, status computepb.Instance_Status) error {
+	instance, ok := b.instances[workspaceId]
+	if !ok {
+		return errors.New("instance not found")
+	}
+
+	instance.Status = proto.String(status.String())
+	return nil
+}
+
+func newTestProvider(t *testing.T, backend *memoryBackend) *GCPProvider {
+	p := &GCPProvider{backend: backend}
+
+	_, err := p.Initialize(provider.InitializeProviderRequest{
+		BasePath:           t.TempDir(),
+		DaytonaDownloadUrl: "https://download.daytona.io/daytona/install.sh",
+		DaytonaVersion:     "latest",
+		LogsDir:            t.TempDir(),
+	})
+	if err != nil {
+		t.Fatalf("Error initializing provider: %s", err)
+	}
+
+	return p
+}
+
+func newTestWorkspaceRequest(targetOptions string) *provider.WorkspaceRequest {
+	return &provider.WorkspaceRequest{
+		TargetOptions: targetOptions,
+		Workspace: &workspace.Workspace{
+			Id:      "123",
+			Name:    "workspace",
+			EnvVars: map[string]string{},
+		},
+	}
+}
+
+const testTargetOptions = `{"Auth Mode": "application-default", "Project Id": "project", "Zone": "us-central1-a"}`
+
+func TestCreateWorkspaceErrors(t *testing.T) {
+	tests := []struct {
+		name          string
+		targetOptions string
+		backend       *memoryBackend
+		wantCalls     []string
+	}{
+		{
+			name:          "Invalid target options",
+			targetOptions: `{"Zone": "us-central1-a"}`,
+			backend:       newMemoryBackend(),
+		},
+		{
+			name:          "Target options fail validation",
+			targetOptions: testTargetOptions,
+			backend:       &memoryBackend{instances: map[string]*computepb.Instance{}, validateErr: errBackend},
+			wantCalls:     []string{"validate"},
+		},
+		{
+			name:          "Backend fails to create the instance",
+			targetOptions: testTargetOptions,
+			backend:       &memoryBackend{instances: map[string]*computepb.Instance{}, createErr: errBackend},
+			wantCalls:     []string{"validate", "create"},
+		},
+		{
+			name:          "Partially created workspace is rolled back",
+			targetOptions: testTargetOptions,
+			backend:       &memoryBackend{instances: map[string]*computepb.Instance{}, createErr: errBackend, createsInstanceOnErr: true},
+			wantCalls:     []string{"validate", "create", "rollback"},
+		},
+		{
+			name:          "Partially created workspace is kept on failure",
+			targetOptions: `{"Auth Mode": "application-default", "Project Id": "project", "Zone": "us-central1-a", "Keep On Failure": true}`,
+			backend:       &memoryBackend{instances: map[string]*computepb.Instance{}, createErr: errBackend, createsInstanceOnErr: true},
+			wantCalls:     []string{"validate", "create"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := newTestProvider(t, tt.backend)
+
+			_, err := p.CreateWorkspace(newTestWorkspaceRequest(tt.targetOptions))
+			if err == nil {
+				t.Fatalf("Expected CreateWorkspace to fail")
+			}
+
+			if strings.Join(tt.backend.calls, ",") != strings.Join(tt.wantCalls, ",") {
+				t.Errorf("Expected backend calls %v, got %v", tt.wantCalls, tt.backend.calls)
+			}
+		})
+	}
+}
+
+func TestCreateWorkspaceNotInitialized(t *testing.T) {
+	p := &GCPProvider{backend: newMemoryBackend()}
+
+	_, err := p.CreateWorkspace(newTestWorkspaceRequest(testTargetOptions))
+	if err == nil {
+		t.Fatalf("Expected CreateWorkspace to fail for an uninitialized provider")
+	}
+}
+
+func TestGetWorkspaceInfoWithBackend(t *testing.T) {
+	tests := []struct {
+		name          string
+		targetOptions string
+		instance      *computepb.Instance
+		getErr        error
+		want          *types.WorkspaceMetadata
+		wantErr       bool
+	}{
+		{
+			name:          "Running instance",
+			targetOptions: testTargetOptions,
+			instance: &computepb.Instance{
+				Id:                proto.Uint64(42),
+				Name:              proto.String("daytona-123"),
+				CpuPlatform:       proto.String("Intel Broadwell"),
+				Zone:              proto.String("us-central1-a"),
+				CreationTimestamp: proto.String("2024-01-01T00:00:00Z"),
+				ServiceAccounts: []*computepb.ServiceAccount{
+					{Email: proto.String("registry-reader@project.iam.gserviceaccount.com")},
+				},
+			},
+			want: &types.WorkspaceMetadata{
+				VirtualMachineId:   42,
+				VirtualMachineName: "daytona-123",
+				Platform:           "Intel Broadwell",
+				Location:           "us-central1-a",
+				Created:            "2024-01-01T00:00:00Z",
+				ProvisioningModel:  types.ProvisioningModelStandard,
+				ServiceAccount:     "registry-reader@project.iam.gserviceaccount.com",
+			},
+		},
+		{
+			name:          "Missing instance",
+			targetOptions: testTargetOptions,
+			wantErr:       true,
+		},
+		{
+			name:          "Backend error",
+			targetOptions: testTargetOptions,
+			instance:      &computepb.Instance{Name: proto.String("daytona-123")},
+			getErr:        errBackend,
+			wantErr:       true,
+		},
+		{
+			name:          "Invalid target options",
+			targetOptions: `{`,
+			wantErr:       true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			backend := newMemoryBackend()
+			backend.getErr = tt.getErr
+			if tt.instance != nil {
+				backend.instances["123"] = tt.instance
+			}
+			p := newTestProvider(t, backend)
+
+			workspaceInfo, err := p.GetWorkspaceInfo(newTestWorkspaceRequest(tt.targetOptions))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("GetWorkspaceInfo() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+
+			var metadata types.WorkspaceMetadata
+			err = json.Unmarshal([]byte(workspaceInfo.ProviderMetadata), &metadata)
+			if err != nil {
+				t.Fatalf("Error unmarshalling workspace metadata: %s", err)
+			}
+
+			if !reflect.DeepEqual(&metadata, tt.want) {
+				t.Errorf("GetWorkspaceInfo() metadata = %+v, want %+v", metadata, *tt.want)
+			}
+		})
+	}
+}
+
+func TestWorkspaceLifecycleErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		backend *memoryBackend
+		call    func(p *GCPProvider, req *provider.WorkspaceRequest) error
+		wantErr bool
+	}{
+		{
+			name:    "Stop",
+			backend: newMemoryBackend(),
+			call: func(p *GCPProvider, req *provider.WorkspaceRequest) error {
+				_, err := p.StopWorkspace(req)
+				return err
+			},
+		},
+		{
+			name:    "Stop fails",
+			backend: &memoryBackend{instances: map[string]*computepb.Instance{}, stopErr: errBackend},
+			call: func(p *GCPProvider, req *provider.WorkspaceRequest) error {
+				_, err := p.StopWorkspace(req)
+				return err
+			},
+			wantErr: true,
+		},
+		{
+			name:    "Start fails",
+			backend: &memoryBackend{instances: map[string]*computepb.Instance{}, startErr: errBackend},
+			call: func(p *GCPProvider, req *provider.WorkspaceRequest) error {
+				_, err := p.StartWorkspace(req)
+				return err
+			},
+			wantErr: true,
+		},
+		{
+			name:    "Destroy",
+			backend: newMemoryBackend(),
+			call: func(p *GCPProvider, req *provider.WorkspaceRequest) error {
+				_, err := p.DestroyWorkspace(req)
+				return err
+			},
+		},
+		{
+			name:    "Destroy fails",
+			backend: &memoryBackend{instances: map[string]*computepb.Instance{}, deleteErr: errBackend},
+			call: func(p *GCPProvider, req *provider.WorkspaceRequest) error {
+				_, err := p.DestroyWorkspace(req)
+				return err
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.backend.instances["123"] = &computepb.Instance{Name: proto.String("daytona-123")}
+			p := newTestProvider(t, tt.backend)
+
+			err := tt.call(p, newTestWorkspaceRequest(testTargetOptions))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestWorkspaceSnapshots(t *testing.T) {
+	backend := newMemoryBackend()
+	backend.instances["123"] = &computepb.Instance{Name: proto.String("daytona-123")}
+	p := newTestProvider(t, backend)
+	req := newTestWorkspaceRequest(testTargetOptions)
+
+	names, err := p.SnapshotWorkspace(req)
+	if err != nil {
+		t.Fatalf("Error snapshotting workspace: %s", err)
+	}
+	if !reflect.DeepEqual(names, []string{"daytona-123-boot"}) {
+		t.Errorf("Expected snapshot [daytona-123-boot], got %v", names)
+	}
+
+	snapshots, err := p.ListWorkspaceSnapshots(req)
+	if err != nil {
+		t.Fatalf("Error listing snapshots: %s", err)
+	}
+	if len(snapshots) != 1 || snapshots[0].GetName() != "daytona-123-boot" {
+		t.Errorf("Expected the snapshot of the workspace, got %v", snapshots)
+	}
+
+	err = p.DeleteSnapshot(testTargetOptions, "daytona-123-boot")
+	if err != nil {
+		t.Fatalf("Error deleting snapshot: %s", err)
+	}
+	snapshots, err = p.ListWorkspaceSnapshots(req)
+	if err != nil {
+		t.Fatalf("Error listing snapshots: %s", err)
+	}
+	if len(snapshots) != 0 {
+		t.Errorf("Expected no snapshots after the deletion, got %v", snapshots)
+	}
+
+	want := []string{"snapshot", "listSnapshots", "deleteSnapshot", "listSnapshots"}
+	if !reflect.DeepEqual(backend.calls, want) {
+		t.Errorf("Expected calls %v, got %v", want, backend.calls)
+	}
+
+	// Snapshotting a workspace without a VM fails
+	delete(backend.instances, "123")
+	_, err = p.SnapshotWorkspace(req)
+	if err == nil {
+		t.Errorf("Expected an error snapshotting a workspace without a VM")
+	}
+}
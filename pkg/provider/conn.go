@@ -3,9 +3,12 @@ package provider
 import (
 	"context"
 	"fmt"
+	"io"
 	"path/filepath"
 	"time"
 
+	logwriters "github.com/daytonaio/daytona-provider-gcp/internal/log"
+	"github.com/daytonaio/daytona-provider-gcp/pkg/types"
 	"github.com/daytonaio/daytona/pkg/agent/ssh/config"
 	"github.com/daytonaio/daytona/pkg/docker"
 	"github.com/daytonaio/daytona/pkg/tailscale"
@@ -32,7 +35,25 @@ func (g *GCPProvider) getTsnetConn() (*tsnet.Server, error) {
 	return g.tsnetConn, nil
 }
 
-func (g *GCPProvider) waitForDial(workspaceId string, dialTimeout time.Duration) error {
+// waitForAgent waits for the agent of the workspace to accept connections while showing a spinner. The agent gets as
+// long to start as an operation on the VM.
+func (g *GCPProvider) waitForAgent(ctx context.Context, workspaceId string, opts *types.TargetOptions, logWriter io.Writer) error {
+	spinnerCtx, cancelSpinner := context.WithCancel(ctx)
+	defer cancelSpinner()
+
+	spinner := logwriters.ShowSpinner(spinnerCtx, logWriter, "Waiting for the agent to start", "Agent started")
+	err := g.waitForDial(ctx, workspaceId, opts.GetOperationTimeout())
+	if err != nil {
+		// Canceling the context clears the spinner without the end statement
+		cancelSpinner()
+		return err
+	}
+	close(spinner)
+
+	return nil
+}
+
+func (g *GCPProvider) waitForDial(ctx context.Context, workspaceId string, dialTimeout time.Duration) error {
 	tsnetConn, err := g.getTsnetConn()
 	if err != nil {
 		return err
@@ -44,13 +65,17 @@ func (g *GCPProvider) waitForDial(workspaceId string, dialTimeout time.Duration)
 			return fmt.Errorf("timeout: dialing timed out after %f minutes", dialTimeout.Minutes())
 		}
 
-		dialConn, err := tsnetConn.Dial(context.Background(), "tcp", fmt.Sprintf("%s:%d", workspaceId, config.SSH_PORT))
+		dialConn, err := tsnetConn.Dial(ctx, "tcp", fmt.Sprintf("%s:%d", workspaceId, config.SSH_PORT))
 		if err == nil {
 			dialConn.Close()
 			return nil
 		}
 
-		time.Sleep(time.Second)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(time.Second):
+		}
 	}
 }
 
@@ -1,13 +1,16 @@
 package provider
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
 	"path"
+	"strings"
 	"time"
 
+	"cloud.google.com/go/compute/apiv1/computepb"
 	"github.com/daytonaio/daytona-provider-gcp/internal"
 	logwriters "github.com/daytonaio/daytona-provider-gcp/internal/log"
 	gcputil "github.com/daytonaio/daytona-provider-gcp/pkg/provider/util"
@@ -36,6 +39,9 @@ type GCPProvider struct {
 	ServerPort         *uint32
 	LogsDir            *string
 	tsnetConn          *tsnet.Server
+	backend            gcputil.ComputeBackend
+	// suggestionsFailedAt is when the discovery of suggestions last failed, so that it isn't retried on every request
+	suggestionsFailedAt time.Time
 }
 
 func (g *GCPProvider) Initialize(req provider.InitializeProviderRequest) (*util.Empty, error) {
@@ -48,10 +54,22 @@ func (g *GCPProvider) Initialize(req provider.InitializeProviderRequest) (*util.
 	g.ApiPort = &req.ApiPort
 	g.ServerPort = &req.ServerPort
 	g.LogsDir = &req.LogsDir
+	if g.backend == nil {
+		g.backend = gcputil.NewComputeBackend(gcputil.NewClientManager())
+	}
 
 	return new(util.Empty), nil
 }
 
+// Close releases the GCP clients shared between the provider calls.
+func (g *GCPProvider) Close() error {
+	if g.backend == nil {
+		return nil
+	}
+
+	return g.backend.Close()
+}
+
 func (g *GCPProvider) GetInfo() (provider.ProviderInfo, error) {
 	label := "GCP"
 
@@ -63,7 +81,7 @@ func (g *GCPProvider) GetInfo() (provider.ProviderInfo, error) {
 }
 
 func (g *GCPProvider) GetTargetManifest() (*provider.ProviderTargetManifest, error) {
-	return types.GetTargetManifest(), nil
+	return types.GetTargetManifestWithSuggestions(g.getSuggestions()), nil
 }
 
 func (g *GCPProvider) GetPresetTargets() (*[]provider.ProviderTarget, error) {
@@ -83,24 +101,38 @@ func (g *GCPProvider) CreateWorkspace(workspaceReq *provider.WorkspaceRequest) (
 		return nil, err
 	}
 
-	initScript := fmt.Sprintf(`curl -sfL -H "Authorization: Bearer %s" %s | bash`, workspaceReq.Workspace.ApiKey, *g.DaytonaDownloadUrl)
-	err = gcputil.CreateWorkspace(workspaceReq.Workspace, targetOptions, initScript, logWriter)
+	initScript := g.getInitScript(workspaceReq.Workspace)
+	ctx := context.Background()
+
+	err = g.backend.ValidateTargetOptions(ctx, targetOptions)
+	if err != nil {
+		logWriter.Write([]byte("Failed to validate target options: " + err.Error() + "\n"))
+		return nil, err
+	}
+
+	// The resources created so far are deleted if any of the following steps fails
+	tx := gcputil.NewTransaction()
+
+	err = g.backend.CreateWorkspace(ctx, workspaceReq.Workspace, targetOptions, initScript, tx, logWriter)
 	if err != nil {
 		logWriter.Write([]byte("Failed to create workspace: " + err.Error() + "\n"))
+		g.rollbackWorkspace(ctx, tx, "creating the compute instance", targetOptions, logWriter)
 		return nil, err
 	}
 
-	agentSpinner := logwriters.ShowSpinner(logWriter, "Waiting for the agent to start", "Agent started")
-	err = g.waitForDial(workspaceReq.Workspace.Id, 10*time.Minute)
-	close(agentSpinner)
+	err = g.waitForAgent(ctx, workspaceReq.Workspace.Id, targetOptions, logWriter)
 	if err != nil {
 		logWriter.Write([]byte("Failed to dial: " + err.Error() + "\n"))
+		g.rollbackWorkspace(ctx, tx, "waiting for the agent", targetOptions, logWriter)
 		return nil, err
 	}
 
+	g.removeWorkspaceSecrets(ctx, workspaceReq.Workspace, targetOptions, logWriter)
+
 	client, err := g.getDockerClient(workspaceReq.Workspace.Id)
 	if err != nil {
 		logWriter.Write([]byte("Failed to get client: " + err.Error() + "\n"))
+		g.rollbackWorkspace(ctx, tx, "connecting to Docker", targetOptions, logWriter)
 		return nil, err
 	}
 
@@ -111,11 +143,43 @@ func (g *GCPProvider) CreateWorkspace(workspaceReq *provider.WorkspaceRequest) (
 	})
 	if err != nil {
 		logWriter.Write([]byte("Failed to create ssh client: " + err.Error() + "\n"))
+		g.rollbackWorkspace(ctx, tx, "connecting over SSH", targetOptions, logWriter)
 		return new(util.Empty), err
 	}
 	defer sshClient.Close()
 
-	return new(util.Empty), client.CreateWorkspace(workspaceReq.Workspace, workspaceDir, logWriter, sshClient)
+	err = client.CreateWorkspace(workspaceReq.Workspace, workspaceDir, logWriter, sshClient)
+	if err != nil {
+		logWriter.Write([]byte("Failed to create the workspace on the VM: " + err.Error() + "\n"))
+		g.rollbackWorkspace(ctx, tx, "creating the workspace on the VM", targetOptions, logWriter)
+		return new(util.Empty), err
+	}
+
+	return new(util.Empty), nil
+}
+
+// rollbackWorkspace deletes the resources created for a workspace whose creation failed in the given phase, unless
+// Keep On Failure is set to debug the failure. A failed rollback is logged and leaves the remaining resources to be
+// collected as orphans.
+func (g *GCPProvider) rollbackWorkspace(ctx context.Context, tx *gcputil.Transaction, phase string, opts *types.TargetOptions, logWriter io.Writer) {
+	resources := tx.Resources()
+	if len(resources) == 0 {
+		return
+	}
+
+	if opts.KeepOnFailure {
+		logWriter.Write([]byte("Workspace creation failed while " + phase + ", keeping " + strings.Join(resources, ", ") + " for debugging\n"))
+		return
+	}
+
+	logWriter.Write([]byte("Workspace creation failed while " + phase + ", deleting " + strings.Join(resources, ", ") + "\n"))
+	err := tx.Rollback(ctx, logWriter)
+	if err != nil {
+		logWriter.Write([]byte("Failed to roll back the workspace creation: " + err.Error() + "\n"))
+		return
+	}
+
+	logWriter.Write([]byte("Workspace creation rolled back\n"))
 }
 
 func (g *GCPProvider) StartWorkspace(workspaceReq *provider.WorkspaceRequest) (*util.Empty, error) {
@@ -128,13 +192,32 @@ func (g *GCPProvider) StartWorkspace(workspaceReq *provider.WorkspaceRequest) (*
 		return nil, err
 	}
 
-	err = g.waitForDial(workspaceReq.Workspace.Id, 10*time.Minute)
+	ctx := context.Background()
+
+	err = g.backend.StartWorkspace(ctx, workspaceReq.Workspace, targetOptions, logWriter)
+	if err != nil {
+		logWriter.Write([]byte("Failed to start workspace: " + err.Error() + "\n"))
+		return nil, err
+	}
+
+	err = g.waitForAgent(ctx, workspaceReq.Workspace.Id, targetOptions, logWriter)
 	if err != nil {
 		logWriter.Write([]byte("Failed to dial: " + err.Error() + "\n"))
 		return nil, err
 	}
 
-	return new(util.Empty), gcputil.StartWorkspace(workspaceReq.Workspace, targetOptions)
+	g.removeWorkspaceSecrets(ctx, workspaceReq.Workspace, targetOptions, logWriter)
+
+	return new(util.Empty), nil
+}
+
+// removeWorkspaceSecrets deletes the secrets from the instance metadata once the agent connected, which means the VM
+// fetched them. A failure leaves them in the metadata but doesn't fail the workspace.
+func (g *GCPProvider) removeWorkspaceSecrets(ctx context.Context, workspace *workspace.Workspace, opts *types.TargetOptions, logWriter io.Writer) {
+	err := g.backend.RemoveWorkspaceSecrets(ctx, workspace, opts)
+	if err != nil {
+		logWriter.Write([]byte("Failed to remove the workspace secrets from the instance metadata: " + err.Error() + "\n"))
+	}
 }
 
 func (g *GCPProvider) StopWorkspace(workspaceReq *provider.WorkspaceRequest) (*util.Empty, error) {
@@ -147,7 +230,7 @@ func (g *GCPProvider) StopWorkspace(workspaceReq *provider.WorkspaceRequest) (*u
 		return nil, err
 	}
 
-	return new(util.Empty), gcputil.StopWorkspace(workspaceReq.Workspace, targetOptions)
+	return new(util.Empty), g.backend.StopWorkspace(context.Background(), workspaceReq.Workspace, targetOptions, logWriter)
 }
 
 func (g *GCPProvider) DestroyWorkspace(workspaceReq *provider.WorkspaceRequest) (*util.Empty, error) {
@@ -160,7 +243,81 @@ func (g *GCPProvider) DestroyWorkspace(workspaceReq *provider.WorkspaceRequest)
 		return nil, err
 	}
 
-	return new(util.Empty), gcputil.DeleteWorkspace(workspaceReq.Workspace, targetOptions)
+	return new(util.Empty), g.backend.DeleteWorkspace(context.Background(), workspaceReq.Workspace, targetOptions, logWriter)
+}
+
+// SnapshotWorkspace snapshots the boot and data disks of the workspace VM and returns the snapshot names. Stop the
+// workspace first for consistent snapshots. It isn't part of the provider plugin interface, the snapshot-workspace
+// command of the provider binary runs it.
+func (g *GCPProvider) SnapshotWorkspace(workspaceReq *provider.WorkspaceRequest) ([]string, error) {
+	logWriter, cleanupFunc := g.getWorkspaceLogWriter(workspaceReq.Workspace.Id)
+	defer cleanupFunc()
+
+	targetOptions, err := types.ParseTargetOptions(workspaceReq.TargetOptions)
+	if err != nil {
+		logWriter.Write([]byte("Failed to parse target options: " + err.Error() + "\n"))
+		return nil, err
+	}
+
+	snapshots, err := g.backend.SnapshotWorkspace(context.Background(), workspaceReq.Workspace, targetOptions, logWriter)
+	if err != nil {
+		logWriter.Write([]byte("Failed to snapshot workspace: " + err.Error() + "\n"))
+		return snapshots, err
+	}
+
+	return snapshots, nil
+}
+
+// ListWorkspaceSnapshots lists the snapshots created for the workspace. The list-snapshots command of the provider
+// binary runs it.
+func (g *GCPProvider) ListWorkspaceSnapshots(workspaceReq *provider.WorkspaceRequest) ([]*computepb.Snapshot, error) {
+	targetOptions, err := types.ParseTargetOptions(workspaceReq.TargetOptions)
+	if err != nil {
+		return nil, err
+	}
+
+	return g.backend.ListWorkspaceSnapshots(context.Background(), workspaceReq.Workspace.Id, targetOptions)
+}
+
+// DeleteSnapshot deletes a snapshot of the project of the target. The delete-snapshot command of the provider binary
+// runs it.
+func (g *GCPProvider) DeleteSnapshot(targetOptionsJson string, name string) error {
+	logWriter := &logwriters.InfoLogWriter{}
+
+	targetOptions, err := types.ParseTargetOptions(targetOptionsJson)
+	if err != nil {
+		logWriter.Write([]byte("Failed to parse target options: " + err.Error() + "\n"))
+		return err
+	}
+
+	err = g.backend.DeleteSnapshot(context.Background(), name, targetOptions, logWriter)
+	if err != nil {
+		logWriter.Write([]byte("Failed to delete snapshot " + name + ": " + err.Error() + "\n"))
+		return err
+	}
+
+	return nil
+}
+
+// CollectOrphans finds the VMs and disks of workspaces that the Daytona server doesn't know about, e.g. of workspaces whose
+// creation failed after the VM was created, and deletes them unless it is a dry run. The summary is written to the provider log.
+// It isn't part of the provider plugin interface, the collect-orphans command of the provider binary runs it.
+func (g *GCPProvider) CollectOrphans(targetOptionsJson string, orphanOpts gcputil.OrphanOptions) (*gcputil.OrphanSummary, error) {
+	logWriter := &logwriters.InfoLogWriter{}
+
+	targetOptions, err := types.ParseTargetOptions(targetOptionsJson)
+	if err != nil {
+		logWriter.Write([]byte("Failed to parse target options: " + err.Error() + "\n"))
+		return nil, err
+	}
+
+	summary, err := g.backend.CollectOrphans(context.Background(), targetOptions, orphanOpts, logWriter)
+	if err != nil {
+		logWriter.Write([]byte("Failed to collect orphaned resources: " + err.Error() + "\n"))
+		return nil, err
+	}
+
+	return summary, nil
 }
 
 func (g *GCPProvider) GetWorkspaceInfo(workspaceReq *provider.WorkspaceRequest) (*workspace.WorkspaceInfo, error) {
@@ -312,12 +469,11 @@ func (g *GCPProvider) getWorkspaceInfo(workspaceReq *provider.WorkspaceRequest)
 		return nil, err
 	}
 
-	vm, err := gcputil.GetComputeInstance(workspaceReq.Workspace, targetOptions)
+	metadata, err := g.backend.GetWorkspaceMetadata(context.Background(), workspaceReq.Workspace, targetOptions)
 	if err != nil {
 		return nil, err
 	}
 
-	metadata := types.ToWorkspaceMetadata(vm)
 	jsonMetadata, err := json.Marshal(metadata)
 	if err != nil {
 		return nil, err
@@ -329,11 +485,17 @@ func (g *GCPProvider) getWorkspaceInfo(workspaceReq *provider.WorkspaceRequest)
 	}, nil
 }
 
+// getInitScript returns the script that installs the Daytona binary on the workspace VM.
+func (g *GCPProvider) getInitScript(ws *workspace.Workspace) string {
+	return fmt.Sprintf(`curl -sfL -H "Authorization: Bearer %s" %s | bash`, ws.ApiKey, *g.DaytonaDownloadUrl)
+}
+
 func (g *GCPProvider) getWorkspaceLogWriter(workspaceId string) (io.Writer, func()) {
 	logWriter := io.MultiWriter(&logwriters.InfoLogWriter{})
 	cleanupFunc := func() {}
 
-	if g.LogsDir != nil {
+	// The provider commands run without a logs directory
+	if g.LogsDir != nil && *g.LogsDir != "" {
 		loggerFactory := logs.NewLoggerFactory(g.LogsDir, nil)
 		wsLogWriter := loggerFactory.CreateWorkspaceLogger(workspaceId, logs.LogSourceProvider)
 		logWriter = io.MultiWriter(&logwriters.InfoLogWriter{}, wsLogWriter)
@@ -1,12 +1,12 @@
 package provider
 
 import (
+	"context"
 	"encoding/json"
 	"os"
 	"testing"
 	"time"
 
-	gcputil "github.com/daytonaio/daytona-provider-gcp/pkg/provider/util"
 	"github.com/daytonaio/daytona-provider-gcp/pkg/types"
 	"github.com/daytonaio/daytona/pkg/provider"
 	"github.com/daytonaio/daytona/pkg/workspace"
@@ -31,15 +31,19 @@ var (
 )
 
 func TestCreateWorkspace(t *testing.T) {
+	skipWithoutGCP(t)
+
 	_, _ = azureProvider.CreateWorkspace(workspaceReq)
 
-	_, err := gcputil.GetComputeInstance(workspaceReq.Workspace, targetOptions)
+	_, err := azureProvider.backend.GetComputeInstance(context.Background(), workspaceReq.Workspace, targetOptions)
 	if err != nil {
 		t.Fatalf("Error getting machine: %s", err)
 	}
 }
 
 func TestWorkspaceInfo(t *testing.T) {
+	skipWithoutGCP(t)
+
 	workspaceInfo, err := azureProvider.GetWorkspaceInfo(workspaceReq)
 	if err != nil {
 		t.Fatalf("Error getting workspace info: %s", err)
@@ -51,7 +55,7 @@ func TestWorkspaceInfo(t *testing.T) {
 		t.Fatalf("Error unmarshalling workspace metadata: %s", err)
 	}
 
-	vm, err := gcputil.GetComputeInstance(workspaceReq.Workspace, targetOptions)
+	vm, err := azureProvider.backend.GetComputeInstance(context.Background(), workspaceReq.Workspace, targetOptions)
 	if err != nil {
 		t.Fatalf("Error getting machine: %s", err)
 	}
@@ -95,18 +99,28 @@ func TestWorkspaceInfo(t *testing.T) {
 }
 
 func TestDestroyWorkspace(t *testing.T) {
+	skipWithoutGCP(t)
+
 	_, err := azureProvider.DestroyWorkspace(workspaceReq)
 	if err != nil {
 		t.Fatalf("Error destroying workspace: %s", err)
 	}
 	time.Sleep(3 * time.Second)
 
-	_, err = gcputil.GetComputeInstance(workspaceReq.Workspace, targetOptions)
+	_, err = azureProvider.backend.GetComputeInstance(context.Background(), workspaceReq.Workspace, targetOptions)
 	if err == nil {
 		t.Fatalf("Error destroyed workspace still exists")
 	}
 }
 
+// skipWithoutGCP skips tests that create real resources in the GCP project set in the environment.
+// The same lifecycle is covered without GCP by the gcputil tests against the fake Compute API.
+func skipWithoutGCP(t *testing.T) {
+	if credentialFile == "" || projectId == "" {
+		t.Skip("GCP_CREDENTIAL_FILE and GCP_PROJECT_ID must be set to run tests against GCP")
+	}
+}
+
 func init() {
 	_, err := azureProvider.Initialize(provider.InitializeProviderRequest{
 		BasePath:           "/tmp/workspaces",
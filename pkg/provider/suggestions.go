@@ -0,0 +1,124 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/daytonaio/daytona-provider-gcp/pkg/types"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	suggestionsCacheTTL      = 24 * time.Hour
+	suggestionsFailureTTL    = 5 * time.Minute
+	suggestionsDiscoveryTime = 30 * time.Second
+)
+
+type suggestionsCache struct {
+	FetchedAt   time.Time
+	Suggestions types.Suggestions
+}
+
+// getSuggestions returns the target option suggestions discovered from the project set in the environment.
+// Discovered suggestions are cached on disk under the base path. The static suggestions are used
+// when no project is set or the project catalogue can't be queried. A failed discovery isn't retried
+// for a few minutes, so that requesting the manifest doesn't wait for the discovery to time out every time.
+func (g *GCPProvider) getSuggestions() types.Suggestions {
+	staticSuggestions := types.GetStaticSuggestions()
+
+	// The target options aren't known yet when the manifest is requested so only the environment can be used
+	targetOptions, err := types.ParseTargetOptions("{}")
+	if err != nil || g.backend == nil || g.BasePath == nil {
+		return staticSuggestions
+	}
+
+	cachePath := filepath.Join(*g.BasePath, "suggestions", targetOptions.ProjectID+".json")
+	cache, err := readSuggestionsCache(cachePath)
+	if err == nil && time.Since(cache.FetchedAt) < suggestionsCacheTTL {
+		return withStaticFallback(cache.Suggestions, staticSuggestions)
+	}
+
+	if time.Since(g.suggestionsFailedAt) < suggestionsFailureTTL {
+		return withStaleCache(cache, staticSuggestions)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), suggestionsDiscoveryTime)
+	defer cancel()
+
+	suggestions, err := g.backend.DiscoverSuggestions(ctx, targetOptions)
+	if err != nil {
+		log.Debugf("Failed to discover suggestions for project %s: %s", targetOptions.ProjectID, err)
+		g.suggestionsFailedAt = time.Now()
+		return withStaleCache(cache, staticSuggestions)
+	}
+	g.suggestionsFailedAt = time.Time{}
+
+	err = writeSuggestionsCache(cachePath, &suggestionsCache{
+		FetchedAt:   time.Now(),
+		Suggestions: *suggestions,
+	})
+	if err != nil {
+		log.Debugf("Failed to cache suggestions for project %s: %s", targetOptions.ProjectID, err)
+	}
+
+	return withStaticFallback(*suggestions, staticSuggestions)
+}
+
+// withStaleCache returns the expired cached suggestions if there are any, because they are still closer to the
+// project catalogue than the static suggestions.
+func withStaleCache(cache *suggestionsCache, staticSuggestions types.Suggestions) types.Suggestions {
+	if cache != nil {
+		return withStaticFallback(cache.Suggestions, staticSuggestions)
+	}
+
+	return staticSuggestions
+}
+
+func withStaticFallback(suggestions, staticSuggestions types.Suggestions) types.Suggestions {
+	if len(suggestions.Zones) == 0 {
+		suggestions.Zones = staticSuggestions.Zones
+	}
+	if len(suggestions.MachineTypes) == 0 {
+		suggestions.MachineTypes = staticSuggestions.MachineTypes
+	}
+	if len(suggestions.DiskTypes) == 0 {
+		suggestions.DiskTypes = staticSuggestions.DiskTypes
+	}
+	if len(suggestions.VMImages) == 0 {
+		suggestions.VMImages = staticSuggestions.VMImages
+	}
+
+	return suggestions
+}
+
+func readSuggestionsCache(path string) (*suggestionsCache, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var cache suggestionsCache
+	err = json.Unmarshal(content, &cache)
+	if err != nil {
+		return nil, err
+	}
+
+	return &cache, nil
+}
+
+func writeSuggestionsCache(path string, cache *suggestionsCache) error {
+	content, err := json.Marshal(cache)
+	if err != nil {
+		return err
+	}
+
+	err = os.MkdirAll(filepath.Dir(path), 0755)
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(path, content, 0644)
+}
@@ -0,0 +1,71 @@
+package provider
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/daytonaio/daytona-provider-gcp/pkg/types"
+)
+
+func TestGetSuggestions(t *testing.T) {
+	t.Setenv("GCP_PROJECT_ID", "project")
+
+	backend := newMemoryBackend()
+	backend.suggestions = &types.Suggestions{
+		Zones:        []string{"us-central1-a"},
+		MachineTypes: []string{"n1-standard-1"},
+		DiskTypes:    []string{"pd-standard"},
+	}
+	p := newTestProvider(t, backend)
+
+	want := types.Suggestions{
+		Zones:        []string{"us-central1-a"},
+		MachineTypes: []string{"n1-standard-1"},
+		DiskTypes:    []string{"pd-standard"},
+		VMImages:     types.GetStaticSuggestions().VMImages,
+	}
+
+	suggestions := p.getSuggestions()
+	if !reflect.DeepEqual(suggestions, want) {
+		t.Errorf("getSuggestions() = %+v, want %+v", suggestions, want)
+	}
+
+	// The second call is served from the cache, even if discovery would fail
+	backend.suggestionsErr = errBackend
+	suggestions = p.getSuggestions()
+	if !reflect.DeepEqual(suggestions, want) {
+		t.Errorf("getSuggestions() = %+v, want %+v", suggestions, want)
+	}
+
+	if len(backend.calls) != 1 {
+		t.Errorf("Expected a single discovery call, got %v", backend.calls)
+	}
+}
+
+func TestGetSuggestionsFallback(t *testing.T) {
+	t.Setenv("GCP_PROJECT_ID", "project")
+
+	backend := newMemoryBackend()
+	backend.suggestionsErr = errBackend
+	p := newTestProvider(t, backend)
+
+	suggestions := p.getSuggestions()
+	if !reflect.DeepEqual(suggestions, types.GetStaticSuggestions()) {
+		t.Errorf("Expected the static suggestions when discovery fails")
+	}
+
+	// A failed discovery isn't retried right away
+	p.getSuggestions()
+	if len(backend.calls) != 1 {
+		t.Errorf("Expected a single discovery call, got %v", backend.calls)
+	}
+
+	p.suggestionsFailedAt = time.Now().Add(-suggestionsFailureTTL)
+	backend.suggestionsErr = nil
+	backend.suggestions = &types.Suggestions{Zones: []string{"us-central1-a"}}
+	suggestions = p.getSuggestions()
+	if !reflect.DeepEqual(suggestions.Zones, []string{"us-central1-a"}) || len(backend.calls) != 2 {
+		t.Errorf("Expected the discovery to be retried after a while, got %+v and calls %v", suggestions, backend.calls)
+	}
+}
@@ -0,0 +1,44 @@
+package util
+
+import (
+	"context"
+	"fmt"
+
+	compute "cloud.google.com/go/compute/apiv1"
+	"github.com/daytonaio/daytona-provider-gcp/pkg/types"
+	"google.golang.org/api/impersonate"
+	"google.golang.org/api/option"
+)
+
+// getClientOptions resolves the credentials configured in the target options.
+// Every GCP client created by the provider must be built with these options.
+func getClientOptions(opts *types.TargetOptions) ([]option.ClientOption, error) {
+	switch opts.GetAuthMode() {
+	case types.AuthModeApplicationDefault:
+		return []option.ClientOption{}, nil
+	case types.AuthModeCredentialFile:
+		return []option.ClientOption{option.WithCredentialsFile(opts.CredentialFile)}, nil
+	case types.AuthModeCredentialJson:
+		return []option.ClientOption{option.WithCredentialsJSON([]byte(opts.CredentialJson))}, nil
+	case types.AuthModeImpersonate:
+		sourceOptions := []option.ClientOption{}
+		if opts.CredentialFile != "" {
+			sourceOptions = append(sourceOptions, option.WithCredentialsFile(opts.CredentialFile))
+		} else if opts.CredentialJson != "" {
+			sourceOptions = append(sourceOptions, option.WithCredentialsJSON([]byte(opts.CredentialJson)))
+		}
+
+		// The token source outlives the call that created it, so it must not be bound to a request context.
+		tokenSource, err := impersonate.CredentialsTokenSource(context.Background(), impersonate.CredentialsConfig{
+			TargetPrincipal: opts.ImpersonateServiceAccount,
+			Scopes:          compute.DefaultAuthScopes(),
+		}, sourceOptions...)
+		if err != nil {
+			return nil, err
+		}
+
+		return []option.ClientOption{option.WithTokenSource(tokenSource)}, nil
+	default:
+		return nil, fmt.Errorf("invalid auth mode %q", opts.AuthMode)
+	}
+}
@@ -0,0 +1,96 @@
+package util
+
+import (
+	"context"
+	"io"
+
+	"cloud.google.com/go/compute/apiv1/computepb"
+	"github.com/daytonaio/daytona-provider-gcp/pkg/types"
+	"github.com/daytonaio/daytona/pkg/workspace"
+)
+
+// ComputeBackend is the GCP surface the provider works against.
+// The default implementation calls the Compute Engine API.
+type ComputeBackend interface {
+	CreateWorkspace(ctx context.Context, workspace *workspace.Workspace, opts *types.TargetOptions, initScript string, tx *Transaction, logWriter io.Writer) error
+	StartWorkspace(ctx context.Context, workspace *workspace.Workspace, opts *types.TargetOptions, logWriter io.Writer) error
+	StopWorkspace(ctx context.Context, workspace *workspace.Workspace, opts *types.TargetOptions, logWriter io.Writer) error
+	DeleteWorkspace(ctx context.Context, workspace *workspace.Workspace, opts *types.TargetOptions, logWriter io.Writer) error
+	RemoveWorkspaceSecrets(ctx context.Context, workspace *workspace.Workspace, opts *types.TargetOptions) error
+	GetComputeInstance(ctx context.Context, workspace *workspace.Workspace, opts *types.TargetOptions) (*computepb.Instance, error)
+	GetWorkspaceMetadata(ctx context.Context, workspace *workspace.Workspace, opts *types.TargetOptions) (*types.WorkspaceMetadata, error)
+	SnapshotWorkspace(ctx context.Context, workspace *workspace.Workspace, opts *types.TargetOptions, logWriter io.Writer) ([]string, error)
+	ListWorkspaceSnapshots(ctx context.Context, workspaceId string, opts *types.TargetOptions) ([]*computepb.Snapshot, error)
+	DeleteSnapshot(ctx context.Context, name string, opts *types.TargetOptions, logWriter io.Writer) error
+	CollectOrphans(ctx context.Context, opts *types.TargetOptions, orphanOpts OrphanOptions, logWriter io.Writer) (*OrphanSummary, error)
+	DiscoverSuggestions(ctx context.Context, opts *types.TargetOptions) (*types.Suggestions, error)
+	ValidateTargetOptions(ctx context.Context, opts *types.TargetOptions) error
+	Close() error
+}
+
+type computeBackend struct {
+	clients *ClientManager
+}
+
+// NewComputeBackend creates a ComputeBackend that calls the Compute Engine API with the clients of the client manager.
+func NewComputeBackend(clients *ClientManager) ComputeBackend {
+	return &computeBackend{
+		clients: clients,
+	}
+}
+
+func (b *computeBackend) CreateWorkspace(ctx context.Context, workspace *workspace.Workspace, opts *types.TargetOptions, initScript string, tx *Transaction, logWriter io.Writer) error {
+	return CreateWorkspace(ctx, b.clients, workspace, opts, initScript, tx, logWriter)
+}
+
+func (b *computeBackend) StartWorkspace(ctx context.Context, workspace *workspace.Workspace, opts *types.TargetOptions, logWriter io.Writer) error {
+	return StartWorkspace(ctx, b.clients, workspace, opts, logWriter)
+}
+
+func (b *computeBackend) StopWorkspace(ctx context.Context, workspace *workspace.Workspace, opts *types.TargetOptions, logWriter io.Writer) error {
+	return StopWorkspace(ctx, b.clients, workspace, opts, logWriter)
+}
+
+func (b *computeBackend) DeleteWorkspace(ctx context.Context, workspace *workspace.Workspace, opts *types.TargetOptions, logWriter io.Writer) error {
+	return DeleteWorkspace(ctx, b.clients, workspace, opts, logWriter)
+}
+
+func (b *computeBackend) RemoveWorkspaceSecrets(ctx context.Context, workspace *workspace.Workspace, opts *types.TargetOptions) error {
+	return RemoveWorkspaceSecrets(ctx, b.clients, workspace, opts)
+}
+
+func (b *computeBackend) GetComputeInstance(ctx context.Context, workspace *workspace.Workspace, opts *types.TargetOptions) (*computepb.Instance, error) {
+	return GetComputeInstance(ctx, b.clients, workspace, opts)
+}
+
+func (b *computeBackend) GetWorkspaceMetadata(ctx context.Context, workspace *workspace.Workspace, opts *types.TargetOptions) (*types.WorkspaceMetadata, error) {
+	return GetWorkspaceMetadata(ctx, b.clients, workspace, opts)
+}
+
+func (b *computeBackend) SnapshotWorkspace(ctx context.Context, workspace *workspace.Workspace, opts *types.TargetOptions, logWriter io.Writer) ([]string, error) {
+	return SnapshotWorkspace(ctx, b.clients, workspace, opts, logWriter)
+}
+
+func (b *computeBackend) ListWorkspaceSnapshots(ctx context.Context, workspaceId string, opts *types.TargetOptions) ([]*computepb.Snapshot, error) {
+	return ListWorkspaceSnapshots(ctx, b.clients, workspaceId, opts)
+}
+
+func (b *computeBackend) DeleteSnapshot(ctx context.Context, name string, opts *types.TargetOptions, logWriter io.Writer) error {
+	return DeleteSnapshot(ctx, b.clients, name, opts, logWriter)
+}
+
+func (b *computeBackend) CollectOrphans(ctx context.Context, opts *types.TargetOptions, orphanOpts OrphanOptions, logWriter io.Writer) (*OrphanSummary, error) {
+	return CollectOrphans(ctx, b.clients, opts, orphanOpts, logWriter)
+}
+
+func (b *computeBackend) DiscoverSuggestions(ctx context.Context, opts *types.TargetOptions) (*types.Suggestions, error) {
+	return DiscoverSuggestions(ctx, b.clients, opts)
+}
+
+func (b *computeBackend) ValidateTargetOptions(ctx context.Context, opts *types.TargetOptions) error {
+	return ValidateTargetOptions(ctx, b.clients, opts)
+}
+
+func (b *computeBackend) Close() error {
+	return b.clients.Close()
+}
@@ -0,0 +1,185 @@
+package util
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"strings"
+	"sync"
+
+	compute "cloud.google.com/go/compute/apiv1"
+	"github.com/daytonaio/daytona-provider-gcp/pkg/types"
+	iam "google.golang.org/api/iam/v1"
+	"google.golang.org/api/option"
+)
+
+// ClientManager caches GCP API clients per credential set and project so that
+// credentials are resolved and connections are established only once.
+// It is safe for concurrent use.
+type ClientManager struct {
+	mu            sync.Mutex
+	clients       map[clientKey]io.Closer
+	clientOptions []option.ClientOption
+	closed        bool
+}
+
+type clientKey struct {
+	kind        string
+	credentials string
+}
+
+// NewClientManager creates a client manager. The client options are applied to every
+// client after the credentials, e.g. to point the clients to a different endpoint.
+func NewClientManager(clientOptions ...option.ClientOption) *ClientManager {
+	return &ClientManager{
+		clients:       map[clientKey]io.Closer{},
+		clientOptions: clientOptions,
+	}
+}
+
+func (m *ClientManager) InstancesClient(opts *types.TargetOptions) (*compute.InstancesClient, error) {
+	return getClient(m, "instances", opts, compute.NewInstancesRESTClient)
+}
+
+func (m *ClientManager) ZonesClient(opts *types.TargetOptions) (*compute.ZonesClient, error) {
+	return getClient(m, "zones", opts, compute.NewZonesRESTClient)
+}
+
+func (m *ClientManager) MachineTypesClient(opts *types.TargetOptions) (*compute.MachineTypesClient, error) {
+	return getClient(m, "machineTypes", opts, compute.NewMachineTypesRESTClient)
+}
+
+func (m *ClientManager) DiskTypesClient(opts *types.TargetOptions) (*compute.DiskTypesClient, error) {
+	return getClient(m, "diskTypes", opts, compute.NewDiskTypesRESTClient)
+}
+
+func (m *ClientManager) ImagesClient(opts *types.TargetOptions) (*compute.ImagesClient, error) {
+	return getClient(m, "images", opts, compute.NewImagesRESTClient)
+}
+
+func (m *ClientManager) NetworksClient(opts *types.TargetOptions) (*compute.NetworksClient, error) {
+	return getClient(m, "networks", opts, compute.NewNetworksRESTClient)
+}
+
+func (m *ClientManager) SubnetworksClient(opts *types.TargetOptions) (*compute.SubnetworksClient, error) {
+	return getClient(m, "subnetworks", opts, compute.NewSubnetworksRESTClient)
+}
+
+func (m *ClientManager) AddressesClient(opts *types.TargetOptions) (*compute.AddressesClient, error) {
+	return getClient(m, "addresses", opts, compute.NewAddressesRESTClient)
+}
+
+func (m *ClientManager) FirewallsClient(opts *types.TargetOptions) (*compute.FirewallsClient, error) {
+	return getClient(m, "firewalls", opts, compute.NewFirewallsRESTClient)
+}
+
+func (m *ClientManager) ZoneOperationsClient(opts *types.TargetOptions) (*compute.ZoneOperationsClient, error) {
+	return getClient(m, "zoneOperations", opts, compute.NewZoneOperationsRESTClient)
+}
+
+func (m *ClientManager) AcceleratorTypesClient(opts *types.TargetOptions) (*compute.AcceleratorTypesClient, error) {
+	return getClient(m, "acceleratorTypes", opts, compute.NewAcceleratorTypesRESTClient)
+}
+
+func (m *ClientManager) DisksClient(opts *types.TargetOptions) (*compute.DisksClient, error) {
+	return getClient(m, "disks", opts, compute.NewDisksRESTClient)
+}
+
+func (m *ClientManager) SnapshotsClient(opts *types.TargetOptions) (*compute.SnapshotsClient, error) {
+	return getClient(m, "snapshots", opts, compute.NewSnapshotsRESTClient)
+}
+
+func (m *ClientManager) ResourcePoliciesClient(opts *types.TargetOptions) (*compute.ResourcePoliciesClient, error) {
+	return getClient(m, "resourcePolicies", opts, compute.NewResourcePoliciesRESTClient)
+}
+
+func (m *ClientManager) IAMService(opts *types.TargetOptions) (*iam.Service, error) {
+	client, err := getClient(m, "iam", opts, newIAMClient)
+	if err != nil {
+		return nil, err
+	}
+
+	return client.Service, nil
+}
+
+// iamClient makes the IAM service cacheable. The service holds no connections of its own to close.
+type iamClient struct {
+	*iam.Service
+}
+
+func newIAMClient(ctx context.Context, clientOptions ...option.ClientOption) (*iamClient, error) {
+	service, err := iam.NewService(ctx, clientOptions...)
+	if err != nil {
+		return nil, err
+	}
+
+	return &iamClient{Service: service}, nil
+}
+
+func (c *iamClient) Close() error {
+	return nil
+}
+
+// Close closes all cached clients. The manager can't be used after it has been closed.
+func (m *ClientManager) Close() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var errs []error
+	for key, client := range m.clients {
+		errs = append(errs, client.Close())
+		delete(m.clients, key)
+	}
+	m.closed = true
+
+	return errors.Join(errs...)
+}
+
+func getClient[T io.Closer](m *ClientManager, kind string, opts *types.TargetOptions, newClient func(context.Context, ...option.ClientOption) (T, error)) (T, error) {
+	var empty T
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.closed {
+		return empty, errors.New("client manager is closed")
+	}
+
+	key := clientKey{
+		kind:        kind,
+		credentials: getCredentialsKey(opts),
+	}
+	if client, ok := m.clients[key]; ok {
+		return client.(T), nil
+	}
+
+	clientOptions, err := getClientOptions(opts)
+	if err != nil {
+		return empty, err
+	}
+
+	// Clients are shared between calls so they must not be bound to a request context.
+	client, err := newClient(context.Background(), append(clientOptions, m.clientOptions...)...)
+	if err != nil {
+		return empty, err
+	}
+	m.clients[key] = client
+
+	return client, nil
+}
+
+// getCredentialsKey identifies the credential set and project of the target options
+// without keeping the inline credentials in memory.
+func getCredentialsKey(opts *types.TargetOptions) string {
+	credentialJsonHash := sha256.Sum256([]byte(opts.CredentialJson))
+
+	return strings.Join([]string{
+		opts.GetAuthMode(),
+		opts.CredentialFile,
+		hex.EncodeToString(credentialJsonHash[:]),
+		opts.ImpersonateServiceAccount,
+		opts.ProjectID,
+	}, "\x00")
+}
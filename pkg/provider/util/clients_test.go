@@ -0,0 +1,63 @@
+package util
+
+import (
+	"context"
+	"testing"
+
+	"github.com/daytonaio/daytona-provider-gcp/pkg/types"
+	"google.golang.org/api/option"
+)
+
+type fakeClient struct {
+	closed bool
+}
+
+func (c *fakeClient) Close() error {
+	c.closed = true
+	return nil
+}
+
+func newFakeClient(context.Context, ...option.ClientOption) (*fakeClient, error) {
+	return &fakeClient{}, nil
+}
+
+func TestClientManagerCachesClients(t *testing.T) {
+	manager := NewClientManager()
+
+	opts := &types.TargetOptions{AuthMode: types.AuthModeApplicationDefault, ProjectID: "project-a"}
+	otherProjectOpts := &types.TargetOptions{AuthMode: types.AuthModeApplicationDefault, ProjectID: "project-b"}
+
+	first, err := getClient(manager, "fake", opts, newFakeClient)
+	if err != nil {
+		t.Fatalf("Error getting client: %s", err)
+	}
+
+	second, err := getClient(manager, "fake", opts, newFakeClient)
+	if err != nil {
+		t.Fatalf("Error getting client: %s", err)
+	}
+	if first != second {
+		t.Fatalf("Expected the cached client to be reused")
+	}
+
+	other, err := getClient(manager, "fake", otherProjectOpts, newFakeClient)
+	if err != nil {
+		t.Fatalf("Error getting client: %s", err)
+	}
+	if first == other {
+		t.Fatalf("Expected a separate client for a different project")
+	}
+
+	err = manager.Close()
+	if err != nil {
+		t.Fatalf("Error closing client manager: %s", err)
+	}
+	if !first.closed || !other.closed {
+		t.Fatalf("Expected all clients to be closed")
+	}
+
+	_, err = getClient(manager, "fake", opts, newFakeClient)
+	if err == nil {
+		t.Fatalf("Expected an error when using a closed client manager")
+	}
+}
@@ -0,0 +1,210 @@
+package util
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"cloud.google.com/go/compute/apiv1/computepb"
+	"github.com/daytonaio/daytona-provider-gcp/pkg/types"
+	"google.golang.org/api/iterator"
+)
+
+// publicImageProjects are the projects of the public images offered as VM image suggestions.
+var publicImageProjects = []string{
+	"centos-cloud",
+	"cos-cloud",
+	"debian-cloud",
+	"fedora-coreos-cloud",
+	"opensuse-cloud",
+	"rhel-cloud",
+	"rocky-linux-cloud",
+	"suse-cloud",
+	"ubuntu-os-cloud",
+	"ubuntu-os-pro-cloud",
+}
+
+// DiscoverSuggestions lists the zones, machine types, disk types and image families available to the project.
+func DiscoverSuggestions(ctx context.Context, clients *ClientManager, opts *types.TargetOptions) (*types.Suggestions, error) {
+	zones, err := discoverZones(ctx, clients, opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list zones: %w", err)
+	}
+
+	machineTypes, err := discoverMachineTypes(ctx, clients, opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list machine types: %w", err)
+	}
+
+	diskTypes, err := discoverDiskTypes(ctx, clients, opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list disk types: %w", err)
+	}
+
+	vmImages, err := discoverImageFamilies(ctx, clients, opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list images: %w", err)
+	}
+
+	return &types.Suggestions{
+		Zones:        zones,
+		MachineTypes: machineTypes,
+		DiskTypes:    diskTypes,
+		VMImages:     vmImages,
+	}, nil
+}
+
+func discoverZones(ctx context.Context, clients *ClientManager, opts *types.TargetOptions) ([]string, error) {
+	client, err := clients.ZonesClient(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	zones := []string{}
+	it := client.List(ctx, &computepb.ListZonesRequest{
+		Project: opts.ProjectID,
+	})
+	for {
+		zone, err := it.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		if zone.GetStatus() == computepb.Zone_UP.String() {
+			zones = append(zones, zone.GetName())
+		}
+	}
+
+	sort.Strings(zones)
+	return zones, nil
+}
+
+// discoverMachineTypes lists the machine types available in the zone of the target options. Without a zone, only the
+// machine types available in every zone are listed, so that e.g. GPU machine types aren't suggested for zones that
+// don't offer them. Availability in the selected zone is checked when the target options are validated.
+func discoverMachineTypes(ctx context.Context, clients *ClientManager, opts *types.TargetOptions) ([]string, error) {
+	client, err := clients.MachineTypesClient(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	zoneCount := 0
+	machineTypeZones := map[string]int{}
+	it := client.AggregatedList(ctx, &computepb.AggregatedListMachineTypesRequest{
+		Project: opts.ProjectID,
+	})
+	for {
+		pair, err := it.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		zone := strings.TrimPrefix(pair.Key, "zones/")
+		if len(pair.Value.GetMachineTypes()) == 0 || (opts.Zone != "" && zone != opts.Zone) {
+			continue
+		}
+
+		zoneCount++
+		for _, machineType := range pair.Value.GetMachineTypes() {
+			if !isDeprecated(machineType.GetDeprecated()) {
+				machineTypeZones[machineType.GetName()]++
+			}
+		}
+	}
+
+	machineTypes := map[string]bool{}
+	for machineType, zones := range machineTypeZones {
+		if zones == zoneCount {
+			machineTypes[machineType] = true
+		}
+	}
+
+	return sortedKeys(machineTypes), nil
+}
+
+func discoverDiskTypes(ctx context.Context, clients *ClientManager, opts *types.TargetOptions) ([]string, error) {
+	client, err := clients.DiskTypesClient(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	diskTypes := map[string]bool{}
+	it := client.AggregatedList(ctx, &computepb.AggregatedListDiskTypesRequest{
+		Project: opts.ProjectID,
+	})
+	for {
+		pair, err := it.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		for _, diskType := range pair.Value.GetDiskTypes() {
+			if !isDeprecated(diskType.GetDeprecated()) {
+				diskTypes[diskType.GetName()] = true
+			}
+		}
+	}
+
+	return sortedKeys(diskTypes), nil
+}
+
+// discoverImageFamilies lists the image families of the project and of the public image projects.
+func discoverImageFamilies(ctx context.Context, clients *ClientManager, opts *types.TargetOptions) ([]string, error) {
+	client, err := clients.ImagesClient(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	families := map[string]bool{}
+	for _, project := range append([]string{opts.ProjectID}, publicImageProjects...) {
+		// Deprecated images make up most of the public image projects, so they are filtered out by GCP
+		it := client.List(ctx, &computepb.ListImagesRequest{
+			Project: project,
+			Filter:  toPtr(notDeprecatedFilter),
+		})
+		for {
+			image, err := it.Next()
+			if err == iterator.Done {
+				break
+			}
+			if err != nil {
+				return nil, err
+			}
+
+			if image.GetFamily() == "" || isDeprecated(image.GetDeprecated()) {
+				continue
+			}
+			families[fmt.Sprintf("projects/%s/global/images/family/%s", project, image.GetFamily())] = true
+		}
+	}
+
+	return sortedKeys(families), nil
+}
+
+// notDeprecatedFilter filters deprecated images out of image lists. Obsolete and deleted images are rare enough to be
+// filtered out after listing.
+const notDeprecatedFilter = "deprecated.state != DEPRECATED"
+
+func isDeprecated(status *computepb.DeprecationStatus) bool {
+	return status != nil && status.GetState() != "" && !strings.EqualFold(status.GetState(), computepb.DeprecationStatus_ACTIVE.String())
+}
+
+func sortedKeys(values map[string]bool) []string {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+	return keys
+}
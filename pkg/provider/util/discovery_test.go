@@ -0,0 +1,77 @@
+package util
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"cloud.google.com/go/compute/apiv1/computepb"
+	"github.com/daytonaio/daytona-provider-gcp/internal/fakecompute"
+	"github.com/daytonaio/daytona-provider-gcp/pkg/types"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestDiscoverSuggestions(t *testing.T) {
+	server := fakecompute.NewServer()
+	defer server.Close()
+
+	clients := NewClientManager(server.ClientOptions()...)
+	defer clients.Close()
+
+	server.AddZone(&computepb.Zone{Name: proto.String("us-central1-a"), Status: proto.String(computepb.Zone_UP.String())})
+	server.AddZone(&computepb.Zone{Name: proto.String("europe-west1-b"), Status: proto.String(computepb.Zone_UP.String())})
+	server.AddZone(&computepb.Zone{Name: proto.String("us-east1-a"), Status: proto.String(computepb.Zone_DOWN.String())})
+
+	server.AddMachineType("us-central1-a", &computepb.MachineType{Name: proto.String("n1-standard-1")})
+	server.AddMachineType("us-central1-a", &computepb.MachineType{Name: proto.String("a2-highgpu-1g")})
+	server.AddMachineType("europe-west1-b", &computepb.MachineType{Name: proto.String("n1-standard-1")})
+	server.AddMachineType("europe-west1-b", &computepb.MachineType{
+		Name:       proto.String("m1-retired"),
+		Deprecated: &computepb.DeprecationStatus{State: proto.String(computepb.DeprecationStatus_OBSOLETE.String())},
+	})
+
+	server.AddDiskType("us-central1-a", &computepb.DiskType{Name: proto.String("pd-standard")})
+	server.AddDiskType("europe-west1-b", &computepb.DiskType{Name: proto.String("pd-ssd")})
+
+	server.AddImage("project", &computepb.Image{Name: proto.String("custom-1"), Family: proto.String("custom")})
+	server.AddImage("ubuntu-os-cloud", &computepb.Image{Name: proto.String("ubuntu-2204-v1"), Family: proto.String("ubuntu-2204-lts")})
+	server.AddImage("ubuntu-os-cloud", &computepb.Image{Name: proto.String("ubuntu-2204-v2"), Family: proto.String("ubuntu-2204-lts")})
+	server.AddImage("ubuntu-os-cloud", &computepb.Image{
+		Name:       proto.String("ubuntu-1804-v1"),
+		Family:     proto.String("ubuntu-1804-lts"),
+		Deprecated: &computepb.DeprecationStatus{State: proto.String(computepb.DeprecationStatus_DEPRECATED.String())},
+	})
+	server.AddImage("debian-cloud", &computepb.Image{Name: proto.String("no-family")})
+
+	opts := &types.TargetOptions{
+		AuthMode:  types.AuthModeApplicationDefault,
+		ProjectID: "project",
+	}
+
+	suggestions, err := DiscoverSuggestions(context.Background(), clients, opts)
+	if err != nil {
+		t.Fatalf("Error discovering suggestions: %s", err)
+	}
+
+	want := &types.Suggestions{
+		Zones:        []string{"europe-west1-b", "us-central1-a"},
+		MachineTypes: []string{"n1-standard-1"},
+		DiskTypes:    []string{"pd-ssd", "pd-standard"},
+		VMImages: []string{
+			"projects/project/global/images/family/custom",
+			"projects/ubuntu-os-cloud/global/images/family/ubuntu-2204-lts",
+		},
+	}
+	if !reflect.DeepEqual(suggestions, want) {
+		t.Errorf("DiscoverSuggestions() = %+v, want %+v", suggestions, want)
+	}
+
+	opts.Zone = "us-central1-a"
+	suggestions, err = DiscoverSuggestions(context.Background(), clients, opts)
+	if err != nil {
+		t.Fatalf("Error discovering suggestions: %s", err)
+	}
+	if want := []string{"a2-highgpu-1g", "n1-standard-1"}; !reflect.DeepEqual(suggestions.MachineTypes, want) {
+		t.Errorf("Expected the machine types of the zone %v, got %v", want, suggestions.MachineTypes)
+	}
+}
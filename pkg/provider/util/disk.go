@@ -0,0 +1,116 @@
+package util
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+
+	"cloud.google.com/go/compute/apiv1/computepb"
+	"github.com/daytonaio/daytona-provider-gcp/pkg/types"
+)
+
+var invalidDiskNameChars = regexp.MustCompile(`[^a-z0-9-]`)
+
+// getDataDiskName returns the name of the data disk, daytona-<workspace name>-data. A recreated workspace gets a new ID
+// but keeps its name, so the disk is named after the name to be reattached. Characters that aren't valid in disk names
+// are replaced with dashes.
+func getDataDiskName(workspaceName string) string {
+	name := invalidDiskNameChars.ReplaceAllString(strings.ToLower(workspaceName), "-")
+	if maxLength := 63 - len(getResourceName("-data")); len(name) > maxLength {
+		name = name[:maxLength]
+	}
+
+	return getResourceName(strings.TrimRight(name, "-")) + "-data"
+}
+
+// isDataDiskName checks whether a disk is named like a data disk.
+func isDataDiskName(name string) bool {
+	return strings.HasPrefix(name, getResourceName("")) && strings.HasSuffix(name, "-data")
+}
+
+// getDataDisk returns the data disk to attach to the VM. A disk retained by a destroyed workspace with the same name is reattached.
+func getDataDisk(ctx context.Context, clients *ClientManager, workspaceName string, labels map[string]string, opts *types.TargetOptions) (*computepb.AttachedDisk, error) {
+	client, err := clients.DisksClient(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	dataDisk := &computepb.AttachedDisk{
+		AutoDelete: toPtr(!opts.RetainDataDisk),
+		DeviceName: toPtr(types.DataDiskDeviceName),
+		Type:       toPtr(computepb.AttachedDisk_PERSISTENT.String()),
+	}
+
+	disk, err := client.Get(ctx, &computepb.GetDiskRequest{
+		Project: opts.ProjectID,
+		Zone:    opts.Zone,
+		Disk:    getDataDiskName(workspaceName),
+	})
+	if err == nil {
+		dataDisk.Source = toPtr(disk.GetSelfLink())
+		return dataDisk, nil
+	}
+	if !isNotFound(err) {
+		return nil, err
+	}
+
+	dataDisk.InitializeParams = &computepb.AttachedDiskInitializeParams{
+		DiskName:   toPtr(getDataDiskName(workspaceName)),
+		DiskType:   toPtr(fmt.Sprintf("projects/%s/zones/%s/diskTypes/%s", opts.ProjectID, opts.Zone, opts.GetDataDiskType())),
+		DiskSizeGb: toPtr(int64(opts.DataDiskSize)),
+		Labels:     labels,
+	}
+	if opts.SourceDataSnapshot != "" {
+		dataDisk.InitializeParams.SourceSnapshot = toPtr(getSnapshotPath(opts.SourceDataSnapshot, opts))
+	}
+
+	return dataDisk, nil
+}
+
+func deleteDataDisk(ctx context.Context, clients *ClientManager, workspaceName string, opts *types.TargetOptions, logWriter io.Writer) error {
+	client, err := clients.DisksClient(opts)
+	if err != nil {
+		return err
+	}
+
+	name := getDataDiskName(workspaceName)
+	op, err := client.Delete(ctx, &computepb.DeleteDiskRequest{
+		Project: opts.ProjectID,
+		Zone:    opts.Zone,
+		Disk:    name,
+	})
+	if err != nil {
+		return wrapOperationError(ctx, "deleting the data disk", opts, err)
+	}
+
+	err = waitForOperation(ctx, op, logWriter, "Deleting GCP disk "+name, "GCP disk "+name+" deleted")
+	return wrapOperationError(ctx, "deleting the data disk", opts, err)
+}
+
+func validateDataDisk(ctx context.Context, clients *ClientManager, opts *types.TargetOptions, validationErr *ValidationError) error {
+	if opts.DataDiskSize < 0 {
+		validationErr.add("Data Disk Size", "data disk size must not be negative")
+	}
+	if opts.DataDiskSize <= 0 || opts.DataDiskType == "" || opts.DataDiskType == opts.DiskType {
+		return nil
+	}
+
+	client, err := clients.DiskTypesClient(opts)
+	if err != nil {
+		return err
+	}
+
+	_, err = client.Get(ctx, &computepb.GetDiskTypeRequest{
+		Project:  opts.ProjectID,
+		Zone:     opts.Zone,
+		DiskType: opts.DataDiskType,
+	})
+	if isNotFound(err) {
+		validationErr.add("Data Disk Type", "disk type %q is not available in zone %q", opts.DataDiskType, opts.Zone)
+		return nil
+	}
+
+	return err
+}
@@ -0,0 +1,106 @@
+package util
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/daytonaio/daytona-provider-gcp/internal/fakecompute"
+	"github.com/daytonaio/daytona-provider-gcp/pkg/types"
+	"github.com/daytonaio/daytona/pkg/workspace"
+)
+
+func TestDataDiskRetention(t *testing.T) {
+	server := fakecompute.NewServer()
+	defer server.Close()
+
+	clients := NewClientManager(server.ClientOptions()...)
+	defer clients.Close()
+
+	ctx := context.Background()
+	logWriter := &bytes.Buffer{}
+	opts := &types.TargetOptions{
+		AuthMode:       types.AuthModeApplicationDefault,
+		ProjectID:      "project",
+		Zone:           "us-central1-a",
+		MachineType:    "n1-standard-1",
+		DiskType:       "pd-standard",
+		DiskSize:       20,
+		VMImage:        "projects/ubuntu-os-cloud/global/images/family/ubuntu-2204-lts",
+		DataDiskSize:   100,
+		DataDiskType:   "pd-ssd",
+		RetainDataDisk: true,
+	}
+	ws := &workspace.Workspace{Id: "123", Name: "my-workspace", EnvVars: map[string]string{}}
+
+	err := CreateWorkspace(ctx, clients, ws, opts, "echo init", nil, logWriter)
+	if err != nil {
+		t.Fatalf("Error creating workspace: %s", err)
+	}
+
+	disk := server.GetDisk("project", "us-central1-a", "daytona-my-workspace-data")
+	if disk == nil {
+		t.Fatalf("Expected the data disk to be created")
+	}
+	if disk.GetSizeGb() != 100 || !strings.HasSuffix(disk.GetType(), "/pd-ssd") {
+		t.Errorf("Expected a 100 GB pd-ssd data disk, got %v", disk)
+	}
+
+	startupScript := server.GetInstance("project", "us-central1-a", "daytona-123").GetMetadata().GetItems()[0].GetValue()
+	if strings.Index(startupScript, "mount /home/daytona") > strings.Index(startupScript, "useradd") {
+		t.Errorf("Expected the data disk to be mounted before the daytona user is created")
+	}
+
+	metadata, err := GetWorkspaceMetadata(ctx, clients, ws, opts)
+	if err != nil {
+		t.Fatalf("Error getting workspace metadata: %s", err)
+	}
+	if metadata.DataDisk != "daytona-my-workspace-data" {
+		t.Errorf("Expected data disk daytona-my-workspace-data in the metadata, got %q", metadata.DataDisk)
+	}
+
+	err = DeleteWorkspace(ctx, clients, ws, opts, logWriter)
+	if err != nil {
+		t.Fatalf("Error deleting workspace: %s", err)
+	}
+	if server.GetDisk("project", "us-central1-a", "daytona-my-workspace-data") == nil {
+		t.Fatalf("Expected the data disk to be retained")
+	}
+
+	// The recreated workspace gets a new ID, reattaches the retained disk by its name and deletes it with the VM
+	opts.RetainDataDisk = false
+	recreated := &workspace.Workspace{Id: "456", Name: "my-workspace", EnvVars: map[string]string{}}
+	err = CreateWorkspace(ctx, clients, recreated, opts, "echo init", nil, logWriter)
+	if err != nil {
+		t.Fatalf("Error recreating workspace: %s", err)
+	}
+
+	dataDisk := server.GetInstance("project", "us-central1-a", "daytona-456").GetDisks()[1]
+	if dataDisk.GetInitializeParams() != nil || !strings.HasSuffix(dataDisk.GetSource(), "/disks/daytona-my-workspace-data") {
+		t.Errorf("Expected the retained data disk to be reattached, got %v", dataDisk)
+	}
+
+	err = DeleteWorkspace(ctx, clients, recreated, opts, logWriter)
+	if err != nil {
+		t.Fatalf("Error deleting workspace: %s", err)
+	}
+	if server.GetDisk("project", "us-central1-a", "daytona-my-workspace-data") != nil {
+		t.Errorf("Expected the data disk to be deleted with the VM")
+	}
+}
+
+func TestGetDataDiskName(t *testing.T) {
+	tests := map[string]string{
+		"my-workspace":                 "daytona-my-workspace-data",
+		"My_Workspace.2":               "daytona-my-workspace-2-data",
+		strings.Repeat("a", 60):        "daytona-" + strings.Repeat("a", 50) + "-data",
+		strings.Repeat("a", 49) + "-b": "daytona-" + strings.Repeat("a", 49) + "-data",
+	}
+
+	for workspaceName, want := range tests {
+		if got := getDataDiskName(workspaceName); got != want {
+			t.Errorf("getDataDiskName(%q) = %q, want %q", workspaceName, got, want)
+		}
+	}
+}
@@ -0,0 +1,251 @@
+package util
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"cloud.google.com/go/compute/apiv1/computepb"
+	"github.com/daytonaio/daytona-provider-gcp/pkg/types"
+	"google.golang.org/api/googleapi"
+	"google.golang.org/api/iterator"
+)
+
+// workspaceNetworkTag is set on every workspace VM so that firewall rules can target them.
+const workspaceNetworkTag = "daytona-workspace"
+
+// The default rules of the default network have the lowest priority (65534), so the managed rule takes precedence over them.
+// Rules created by the user default to priority 1000, so they still allow ingress on top of the managed rule.
+const managedFirewallPriority = 65000
+
+// managedFirewallDescription marks the firewall rules created by the provider, which can't be labelled.
+const managedFirewallDescription = "Managed by Daytona. Denies all ingress to Daytona workspace VMs."
+
+var networkTagPattern = regexp.MustCompile(`^[a-z]([-a-z0-9]{0,61}[a-z0-9])?$`)
+
+// getNetworkTags returns the network tags of the workspace VM.
+func getNetworkTags(opts *types.TargetOptions) []string {
+	tags := []string{workspaceNetworkTag}
+	for _, tag := range opts.GetNetworkTags() {
+		if tag != workspaceNetworkTag {
+			tags = append(tags, tag)
+		}
+	}
+
+	return tags
+}
+
+// getManagedFirewallName returns the name of the managed deny-all-ingress rule of a network.
+func getManagedFirewallName(network string) string {
+	name := "daytona-deny-ingress-" + network
+	if len(name) > 63 {
+		name = name[:63]
+	}
+
+	return strings.TrimRight(name, "-")
+}
+
+// ensureManagedFirewall creates the managed deny-all-ingress rule for the network of the VM if it doesn't exist yet.
+// The rule is shared by the workspace VMs on the network, so it is only deleted with the last of them. Another workspace
+// can delete it while this VM is being created, so it is ensured again once the VM is attached to the network.
+func ensureManagedFirewall(ctx context.Context, clients *ClientManager, opts *types.TargetOptions, logWriter io.Writer) error {
+	networkPath, err := getWorkspaceNetwork(ctx, clients, opts)
+	if err != nil {
+		return err
+	}
+
+	project, _, network, err := parseResourcePath(networkPath)
+	if err != nil {
+		return err
+	}
+
+	client, err := clients.FirewallsClient(opts)
+	if err != nil {
+		return err
+	}
+
+	name := getManagedFirewallName(network)
+	_, err = client.Get(ctx, &computepb.GetFirewallRequest{
+		Project:  project,
+		Firewall: name,
+	})
+	if err == nil || !isNotFound(err) {
+		return err
+	}
+
+	op, err := client.Insert(ctx, &computepb.InsertFirewallRequest{
+		Project: project,
+		FirewallResource: &computepb.Firewall{
+			Name:         toPtr(name),
+			Description:  toPtr(managedFirewallDescription),
+			Network:      toPtr(networkPath),
+			Direction:    toPtr(computepb.Firewall_INGRESS.String()),
+			Priority:     toPtr(int32(managedFirewallPriority)),
+			SourceRanges: []string{"0.0.0.0/0"},
+			TargetTags:   []string{workspaceNetworkTag},
+			Denied: []*computepb.Denied{
+				{
+					IPProtocol: toPtr("all"),
+				},
+			},
+		},
+	})
+	// Another workspace created the rule in the meantime
+	if isConflict(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	return waitForOperation(ctx, op, logWriter, "Creating GCP firewall rule "+name, "GCP firewall rule "+name+" created")
+}
+
+// cleanupManagedFirewall deletes the managed deny-all-ingress rule of the network of the VM
+// once no workspace VM in the project or the network project is attached to the network.
+func cleanupManagedFirewall(ctx context.Context, clients *ClientManager, opts *types.TargetOptions, logWriter io.Writer) error {
+	firewall, project, err := getUnusedManagedFirewall(ctx, clients, opts)
+	if err != nil || firewall == nil {
+		return err
+	}
+
+	client, err := clients.FirewallsClient(opts)
+	if err != nil {
+		return err
+	}
+
+	name := firewall.GetName()
+	op, err := client.Delete(ctx, &computepb.DeleteFirewallRequest{
+		Project:  project,
+		Firewall: name,
+	})
+	if isNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	return waitForOperation(ctx, op, logWriter, "Deleting GCP firewall rule "+name, "GCP firewall rule "+name+" deleted")
+}
+
+// getUnusedManagedFirewall returns the managed deny-all-ingress rule of the network of the target and its project if no
+// workspace VM in the project or the network project is attached to the network. It returns a nil rule if the rule
+// doesn't exist or is in use.
+func getUnusedManagedFirewall(ctx context.Context, clients *ClientManager, opts *types.TargetOptions) (*computepb.Firewall, string, error) {
+	networkPath, err := getWorkspaceNetwork(ctx, clients, opts)
+	if err != nil {
+		return nil, "", err
+	}
+
+	project, _, network, err := parseResourcePath(networkPath)
+	if err != nil {
+		return nil, "", err
+	}
+
+	client, err := clients.FirewallsClient(opts)
+	if err != nil {
+		return nil, "", err
+	}
+
+	firewall, err := client.Get(ctx, &computepb.GetFirewallRequest{
+		Project:  project,
+		Firewall: getManagedFirewallName(network),
+	})
+	if isNotFound(err) {
+		return nil, "", nil
+	}
+	if err != nil {
+		return nil, "", err
+	}
+	// A rule of the same name that wasn't created by the provider is left alone
+	if firewall.GetDescription() != managedFirewallDescription {
+		return nil, "", nil
+	}
+
+	projects := []string{opts.ProjectID}
+	if project != opts.ProjectID {
+		projects = append(projects, project)
+	}
+
+	for _, instanceProject := range projects {
+		inUse, err := isNetworkUsedByWorkspaces(ctx, clients, opts, instanceProject, project, network)
+		if err != nil {
+			return nil, "", err
+		}
+		if inUse {
+			return nil, "", nil
+		}
+	}
+
+	return firewall, project, nil
+}
+
+// isNetworkUsedByWorkspaces checks whether any workspace VM in the project is attached to the network.
+func isNetworkUsedByWorkspaces(ctx context.Context, clients *ClientManager, opts *types.TargetOptions, instanceProject, networkProject, network string) (bool, error) {
+	client, err := clients.InstancesClient(opts)
+	if err != nil {
+		return false, err
+	}
+
+	it := client.AggregatedList(ctx, &computepb.AggregatedListInstancesRequest{
+		Project: instanceProject,
+	})
+	for {
+		pair, err := it.Next()
+		if errors.Is(err, iterator.Done) {
+			return false, nil
+		}
+		if err != nil {
+			return false, err
+		}
+
+		for _, instance := range pair.Value.GetInstances() {
+			if hasWorkspaceNetworkTag(instance) && isAttachedToNetwork(instance, networkProject, network) {
+				return true, nil
+			}
+		}
+	}
+}
+
+func hasWorkspaceNetworkTag(instance *computepb.Instance) bool {
+	for _, tag := range instance.GetTags().GetItems() {
+		if tag == workspaceNetworkTag {
+			return true
+		}
+	}
+
+	return false
+}
+
+func isAttachedToNetwork(instance *computepb.Instance, networkProject, network string) bool {
+	for _, networkInterface := range instance.GetNetworkInterfaces() {
+		project, _, name, err := parseResourcePath(networkInterface.GetNetwork())
+		if err == nil && project == networkProject && name == network {
+			return true
+		}
+	}
+
+	return false
+}
+
+func validateNetworkTags(opts *types.TargetOptions, validationErr *ValidationError) {
+	tags := getNetworkTags(opts)
+	if len(tags) > 64 {
+		validationErr.add("Network Tags", "a VM can have at most 64 network tags, including %s", workspaceNetworkTag)
+	}
+
+	for _, tag := range tags {
+		if !networkTagPattern.MatchString(tag) {
+			validationErr.add("Network Tags", "network tag %q must be 1-63 lowercase letters, digits or dashes, start with a letter and not end with a dash", tag)
+		}
+	}
+}
+
+func isConflict(err error) bool {
+	var apiErr *googleapi.Error
+	return errors.As(err, &apiErr) && apiErr.Code == http.StatusConflict
+}
@@ -0,0 +1,181 @@
+package util
+
+import (
+	"bytes"
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/daytonaio/daytona-provider-gcp/internal/fakecompute"
+	"github.com/daytonaio/daytona-provider-gcp/pkg/types"
+	"github.com/daytonaio/daytona/pkg/workspace"
+)
+
+func TestManagedFirewall(t *testing.T) {
+	server := fakecompute.NewServer()
+	defer server.Close()
+
+	clients := NewClientManager(server.ClientOptions()...)
+	defer clients.Close()
+
+	ctx := context.Background()
+	logWriter := &bytes.Buffer{}
+	opts := &types.TargetOptions{
+		AuthMode:        types.AuthModeApplicationDefault,
+		ProjectID:       "project",
+		Zone:            "us-central1-a",
+		MachineType:     "n1-standard-1",
+		DiskType:        "pd-standard",
+		DiskSize:        20,
+		VMImage:         "projects/ubuntu-os-cloud/global/images/family/ubuntu-2204-lts",
+		NetworkTags:     "dev",
+		ManagedFirewall: true,
+	}
+	first := &workspace.Workspace{Id: "1", EnvVars: map[string]string{}}
+	second := &workspace.Workspace{Id: "2", EnvVars: map[string]string{}}
+
+	for _, ws := range []*workspace.Workspace{first, second} {
+		err := CreateWorkspace(ctx, clients, ws, opts, "", nil, logWriter)
+		if err != nil {
+			t.Fatalf("Error creating workspace %s: %s", ws.Id, err)
+		}
+	}
+
+	instance, err := GetComputeInstance(ctx, clients, first, opts)
+	if err != nil {
+		t.Fatalf("Error getting instance: %s", err)
+	}
+	if !reflect.DeepEqual(instance.GetTags().GetItems(), []string{"daytona-workspace", "dev"}) {
+		t.Errorf("Expected network tags [daytona-workspace dev], got %v", instance.GetTags().GetItems())
+	}
+
+	firewall := server.GetFirewall("project", "daytona-deny-ingress-default")
+	if firewall == nil {
+		t.Fatalf("Expected the managed firewall rule to be created")
+	}
+	if !reflect.DeepEqual(firewall.GetTargetTags(), []string{"daytona-workspace"}) || firewall.GetDirection() != "INGRESS" || len(firewall.GetDenied()) != 1 {
+		t.Errorf("Expected a deny-all-ingress rule for the daytona-workspace tag, got %v", firewall)
+	}
+	if firewall.GetPriority() != 65000 {
+		t.Errorf("Expected the managed firewall rule to yield to rules with the default priority, got priority %d", firewall.GetPriority())
+	}
+
+	err = DeleteWorkspace(ctx, clients, first, opts, logWriter)
+	if err != nil {
+		t.Fatalf("Error deleting workspace: %s", err)
+	}
+	if server.GetFirewall("project", "daytona-deny-ingress-default") == nil {
+		t.Errorf("Expected the managed firewall rule to be kept while a workspace uses the network")
+	}
+
+	err = DeleteWorkspace(ctx, clients, second, opts, logWriter)
+	if err != nil {
+		t.Fatalf("Error deleting workspace: %s", err)
+	}
+	if server.GetFirewall("project", "daytona-deny-ingress-default") != nil {
+		t.Errorf("Expected the managed firewall rule to be deleted with the last workspace")
+	}
+}
+
+func TestCollectUnusedManagedFirewall(t *testing.T) {
+	server := fakecompute.NewServer()
+	defer server.Close()
+
+	clients := NewClientManager(server.ClientOptions()...)
+	defer clients.Close()
+
+	ctx := context.Background()
+	logWriter := &bytes.Buffer{}
+	opts := &types.TargetOptions{
+		AuthMode:        types.AuthModeApplicationDefault,
+		ProjectID:       "project",
+		Zone:            "us-central1-a",
+		MachineType:     "n1-standard-1",
+		DiskType:        "pd-standard",
+		DiskSize:        20,
+		VMImage:         "projects/ubuntu-os-cloud/global/images/family/ubuntu-2204-lts",
+		ManagedFirewall: true,
+	}
+	ws := &workspace.Workspace{Id: "123", EnvVars: map[string]string{}}
+
+	err := CreateWorkspace(ctx, clients, ws, opts, "", nil, logWriter)
+	if err != nil {
+		t.Fatalf("Error creating workspace: %s", err)
+	}
+
+	// The collector keeps the rule while a workspace uses the network
+	orphanOpts := OrphanOptions{KnownWorkspaceIds: []string{ws.Id}}
+	summary, err := CollectOrphans(ctx, clients, opts, orphanOpts, logWriter)
+	if err != nil {
+		t.Fatalf("Error collecting orphans: %s", err)
+	}
+	if len(summary.Orphans) != 0 || server.GetFirewall("project", "daytona-deny-ingress-default") == nil {
+		t.Errorf("Expected the managed firewall rule to be kept while a workspace uses the network, got %v", summary.Orphans)
+	}
+
+	// The VM of a failed creation is deleted without the rule
+	server.DeleteInstance("project", "us-central1-a", getResourceName(ws.Id))
+
+	summary, err = CollectOrphans(ctx, clients, opts, orphanOpts, logWriter)
+	if err != nil {
+		t.Fatalf("Error collecting orphans: %s", err)
+	}
+	if len(summary.Orphans) != 1 || summary.Orphans[0].Kind != OrphanKindFirewall || !summary.Orphans[0].Deleted {
+		t.Errorf("Expected the unused firewall rule to be collected, got %v", summary.Orphans)
+	}
+	if server.GetFirewall("project", "daytona-deny-ingress-default") != nil {
+		t.Errorf("Expected the unused managed firewall rule to be deleted")
+	}
+}
+
+func TestManagedFirewallRecreatedAfterCollection(t *testing.T) {
+	server := fakecompute.NewServer()
+	defer server.Close()
+
+	clients := NewClientManager(server.ClientOptions()...)
+	defer clients.Close()
+
+	opts := &types.TargetOptions{
+		AuthMode:        types.AuthModeApplicationDefault,
+		ProjectID:       "project",
+		Zone:            "us-central1-a",
+		MachineType:     "n1-standard-1",
+		DiskType:        "pd-standard",
+		DiskSize:        20,
+		VMImage:         "projects/ubuntu-os-cloud/global/images/family/ubuntu-2204-lts",
+		ManagedFirewall: true,
+	}
+
+	// The collector deletes the unused rule while the workspace VM is being created
+	server.OnInsertInstance(func() {
+		server.DeleteFirewall("project", "daytona-deny-ingress-default")
+	})
+
+	err := CreateWorkspace(context.Background(), clients, &workspace.Workspace{Id: "123", EnvVars: map[string]string{}}, opts, "", nil, &bytes.Buffer{})
+	if err != nil {
+		t.Fatalf("Error creating workspace: %s", err)
+	}
+	if server.GetFirewall("project", "daytona-deny-ingress-default") == nil {
+		t.Errorf("Expected the managed firewall rule to be recreated after the instance was created")
+	}
+}
+
+func TestGetManagedFirewallName(t *testing.T) {
+	tests := []struct {
+		network string
+		want    string
+	}{
+		{network: "default", want: "daytona-deny-ingress-default"},
+		{network: "workspace-network-of-the-platform-team-in-europe", want: "daytona-deny-ingress-workspace-network-of-the-platform-team-in"},
+	}
+
+	for _, tt := range tests {
+		got := getManagedFirewallName(tt.network)
+		if got != tt.want {
+			t.Errorf("getManagedFirewallName(%q) = %q, want %q", tt.network, got, tt.want)
+		}
+		if len(got) > 63 {
+			t.Errorf("Expected at most 63 characters, got %d", len(got))
+		}
+	}
+}
@@ -4,214 +4,358 @@ import (
 	"context"
 	"fmt"
 	"io"
+	"maps"
+	"slices"
 
-	compute "cloud.google.com/go/compute/apiv1"
 	"cloud.google.com/go/compute/apiv1/computepb"
-	logwriters "github.com/daytonaio/daytona-provider-gcp/internal/log"
+	"github.com/daytonaio/daytona-provider-gcp/pkg/startupscript"
 	"github.com/daytonaio/daytona-provider-gcp/pkg/types"
 	"github.com/daytonaio/daytona/pkg/workspace"
-	"google.golang.org/api/option"
 )
 
-func CreateWorkspace(workspace *workspace.Workspace, opts *types.TargetOptions, initScript string, logWriter io.Writer) error {
+// CreateWorkspace creates the compute instance of a workspace. The created resources are recorded in the transaction, so
+// that they can be rolled back if the creation of the workspace fails. The baked image is shared between workspaces and
+// isn't recorded.
+func CreateWorkspace(ctx context.Context, clients *ClientManager, workspace *workspace.Workspace, opts *types.TargetOptions, initScript string, tx *Transaction, logWriter io.Writer) error {
 	envVars := workspace.EnvVars
 	envVars["DAYTONA_AGENT_LOG_FILE_PATH"] = "/home/daytona/.daytona-agent.log"
 
-	customData := `#!/bin/bash
-useradd -m -d /home/daytona daytona
+	startupScript, err := getStartupScript(opts, envVars, initScript)
+	if err != nil {
+		return err
+	}
 
-curl -fsSL https://get.docker.com | bash
+	if opts.ImageFamily != "" && opts.SourceSnapshot == "" {
+		err = ensureImageFamily(ctx, clients, opts, logWriter)
+		if err != nil {
+			return err
+		}
+	}
 
-# Modify Docker daemon configuration
-cat > /etc/docker/daemon.json <<EOF
-{
-  "hosts": ["unix:///var/run/docker.sock", "tcp://127.0.0.1:2375"]
+	return createComputeInstance(ctx, clients, workspace.Id, workspace.Name, getWorkspaceLabels(workspace, opts), startupScript, opts, tx, logWriter)
 }
-EOF
-
-# Create a systemd drop-in file to modify the Docker service
-mkdir -p /etc/systemd/system/docker.service.d
-cat > /etc/systemd/system/docker.service.d/override.conf <<EOF
-[Service]
-ExecStart=
-ExecStart=/usr/bin/dockerd
-EOF
-
-systemctl daemon-reload
-systemctl restart docker
-systemctl start docker
-
-usermod -aG docker daytona
-
-if grep -q sudo /etc/group; then
-	usermod -aG sudo,docker daytona
-elif grep -q wheel /etc/group; then
-	usermod -aG wheel,docker daytona
-fi
-
-echo "daytona ALL=(ALL) NOPASSWD:ALL" > /etc/sudoers.d/91-daytona
-
-`
-
-	for k, v := range envVars {
-		customData += fmt.Sprintf("export %s=%s\n", k, v)
-	}
-	customData += initScript
-	customData += `
-echo '[Unit]
-Description=Daytona Agent Service
-After=network.target
-
-[Service]
-User=daytona
-ExecStart=/usr/local/bin/daytona agent --host
-Restart=always
-`
-
-	for k, v := range envVars {
-		customData += fmt.Sprintf("Environment='%s=%s'\n", k, v)
-	}
-
-	customData += `
-[Install]
-WantedBy=multi-user.target' > /etc/systemd/system/daytona-agent.service
-systemctl daemon-reload
-systemctl enable daytona-agent.service
-systemctl start daytona-agent.service
-`
-	return createComputeInstance(workspace.Id, customData, opts, logWriter)
+
+// getStartupScript returns the startup script of a workspace VM. It runs on every boot, so every stage must be idempotent.
+// The environment variables and the init script carry the API key, so they are passed as secrets that the VM fetches once.
+func getStartupScript(opts *types.TargetOptions, envVars map[string]string, initScript string) (*startupscript.Script, error) {
+	builder := startupscript.NewBuilder(startupscript.Config{
+		Distro:         startupscript.DistroForImage(opts.VMImage),
+		DataDiskDevice: types.DataDiskDeviceName,
+		EnvVars:        envVars,
+		InitScript:     initScript,
+		IdleTimeout:    opts.GetIdleTimeout(),
+	}).Enable(startupscript.StageSetup, startupscript.StageEnv, startupscript.StageInit, startupscript.StageAgent)
+
+	if opts.DataDiskSize > 0 {
+		builder.Enable(startupscript.StageMountDataDisk, startupscript.StageOwnDataDisk)
+	}
+	if opts.InstallGPUDrivers {
+		builder.Enable(startupscript.StageGPUDrivers)
+	}
+	if opts.GetIdleTimeout() > 0 {
+		builder.Enable(startupscript.StageIdleWatchdog)
+	}
+
+	return builder.Build()
 }
 
-func StartWorkspace(workspace *workspace.Workspace, opts *types.TargetOptions) error {
-	client, err := compute.NewInstancesRESTClient(context.Background(), option.WithCredentialsFile(opts.CredentialFile))
+func StopWorkspace(ctx context.Context, clients *ClientManager, workspace *workspace.Workspace, opts *types.TargetOptions, logWriter io.Writer) error {
+	client, err := clients.InstancesClient(opts)
 	if err != nil {
 		return err
 	}
-	defer client.Close()
 
-	op, err := client.Start(context.Background(), &computepb.StartInstanceRequest{
+	ctx, cancel := withOperationTimeout(ctx, opts)
+	defer cancel()
+
+	op, err := client.Stop(ctx, &computepb.StopInstanceRequest{
 		Project:  opts.ProjectID,
 		Zone:     opts.Zone,
 		Instance: getResourceName(workspace.Id),
 	})
 	if err != nil {
-		return err
+		return wrapOperationError(ctx, "stopping the compute instance", opts, err)
 	}
 
-	return op.Wait(context.Background())
+	err = waitForOperation(ctx, op, logWriter, "Stopping GCP compute instance", "GCP compute instance stopped")
+	return wrapOperationError(ctx, "stopping the compute instance", opts, err)
 }
 
-func StopWorkspace(workspace *workspace.Workspace, opts *types.TargetOptions) error {
-	client, err := compute.NewInstancesRESTClient(context.Background(), option.WithCredentialsFile(opts.CredentialFile))
+func DeleteWorkspace(ctx context.Context, clients *ClientManager, workspace *workspace.Workspace, opts *types.TargetOptions, logWriter io.Writer) error {
+	ctx, cancel := withOperationTimeout(ctx, opts)
+	defer cancel()
+
+	err := deleteComputeInstance(ctx, clients, workspace.Id, opts, logWriter)
 	if err != nil {
 		return err
 	}
-	defer client.Close()
 
-	op, err := client.Stop(context.Background(), &computepb.StopInstanceRequest{
-		Project:  opts.ProjectID,
-		Zone:     opts.Zone,
-		Instance: getResourceName(workspace.Id),
-	})
-	if err != nil {
-		return err
+	if opts.DataDiskSize > 0 && opts.RetainDataDisk {
+		logWriter.Write([]byte("Data disk " + getDataDiskName(workspace.Name) + " retained\n"))
 	}
 
-	return op.Wait(context.Background())
+	// The instance is gone, so a leftover instance schedule or firewall rule must not fail the deletion
+	if opts.HasSchedule() {
+		err = deleteSchedulePolicy(ctx, clients, workspace.Id, opts, logWriter)
+		if err != nil {
+			logWriter.Write([]byte("Failed to delete the instance schedule: " + err.Error() + "\n"))
+		}
+	}
+
+	if opts.ManagedFirewall {
+		err = cleanupManagedFirewall(ctx, clients, opts, logWriter)
+		if err != nil {
+			logWriter.Write([]byte("Failed to delete the managed firewall rule: " + err.Error() + "\n"))
+		}
+	}
+
+	return nil
 }
 
-func DeleteWorkspace(workspace *workspace.Workspace, opts *types.TargetOptions) error {
-	client, err := compute.NewInstancesRESTClient(context.Background(), option.WithCredentialsFile(opts.CredentialFile))
+func deleteComputeInstance(ctx context.Context, clients *ClientManager, workspaceId string, opts *types.TargetOptions, logWriter io.Writer) error {
+	client, err := clients.InstancesClient(opts)
 	if err != nil {
 		return err
 	}
-	defer client.Close()
 
-	op, err := client.Delete(context.Background(), &computepb.DeleteInstanceRequest{
+	op, err := client.Delete(ctx, &computepb.DeleteInstanceRequest{
 		Project:  opts.ProjectID,
 		Zone:     opts.Zone,
-		Instance: getResourceName(workspace.Id),
+		Instance: getResourceName(workspaceId),
 	})
 	if err != nil {
-		return err
+		return wrapOperationError(ctx, "deleting the compute instance", opts, err)
 	}
 
-	return op.Wait(context.Background())
+	err = waitForOperation(ctx, op, logWriter, "Deleting GCP compute instance", "GCP compute instance deleted")
+	return wrapOperationError(ctx, "deleting the compute instance", opts, err)
 }
 
-func createComputeInstance(workspaceId string, initScript string, opts *types.TargetOptions, logWriter io.Writer) error {
-	instancesClient, err := compute.NewInstancesRESTClient(context.Background(), option.WithCredentialsFile(opts.CredentialFile))
+func createComputeInstance(ctx context.Context, clients *ClientManager, workspaceId, workspaceName string, labels map[string]string, startupScript *startupscript.Script, opts *types.TargetOptions, tx *Transaction, logWriter io.Writer) error {
+	instancesClient, err := clients.InstancesClient(opts)
 	if err != nil {
 		return err
 	}
-	defer instancesClient.Close()
+
+	ctx, cancel := withOperationTimeout(ctx, opts)
+	defer cancel()
 
 	instanceName := getResourceName(workspaceId)
 	machineType := fmt.Sprintf("zones/%s/machineTypes/%s", opts.Zone, opts.MachineType)
 	diskType := fmt.Sprintf("projects/%s/zones/%s/diskTypes/%s", opts.ProjectID, opts.Zone, opts.DiskType)
 
-	spinner := logwriters.ShowSpinner(logWriter, "Creating GCP compute instance", "GCP compute instance created")
-	operation, err := instancesClient.Insert(context.Background(), &computepb.InsertInstanceRequest{
+	networkInterface, err := getNetworkInterface(ctx, clients, opts)
+	if err != nil {
+		return wrapOperationError(ctx, "creating the compute instance", opts, err)
+	}
+
+	bootDiskParams := &computepb.AttachedDiskInitializeParams{
+		DiskType:   toPtr(diskType),
+		DiskSizeGb: toPtr(int64(opts.DiskSize)),
+		Labels:     labels,
+	}
+	metadataItems := []*computepb.Items{
+		{
+			Key:   toPtr("startup-script"),
+			Value: toPtr(startupScript.StartupScript),
+		},
+	}
+	for _, key := range slices.Sorted(maps.Keys(startupScript.Secrets)) {
+		metadataItems = append(metadataItems, &computepb.Items{
+			Key:   toPtr(key),
+			Value: toPtr(startupScript.Secrets[key]),
+		})
+	}
+	if startupScript.GuestAttributes {
+		metadataItems = append(metadataItems, &computepb.Items{
+			Key:   toPtr(enableGuestAttributesMetadataKey),
+			Value: toPtr("TRUE"),
+		})
+	}
+	metadataItems = append(metadataItems, getIdleMetadataItems(opts)...)
+	if opts.SourceSnapshot != "" {
+		bootDiskParams.SourceSnapshot = toPtr(getSnapshotPath(opts.SourceSnapshot, opts))
+		metadataItems = append(metadataItems, &computepb.Items{
+			Key:   toPtr(types.SourceSnapshotMetadataKey),
+			Value: toPtr(opts.SourceSnapshot),
+		})
+	} else {
+		bootDiskParams.SourceImage = toPtr(opts.GetBootImage())
+	}
+
+	disks := []*computepb.AttachedDisk{
+		{
+			AutoDelete:       toPtr(true),
+			Boot:             toPtr(true),
+			Type:             toPtr(computepb.AttachedDisk_PERSISTENT.String()),
+			InitializeParams: bootDiskParams,
+		},
+	}
+	// A new data disk that is retained isn't deleted with the instance
+	createsRetainedDataDisk := false
+	if opts.DataDiskSize > 0 {
+		dataDisk, err := getDataDisk(ctx, clients, workspaceName, labels, opts)
+		if err != nil {
+			return wrapOperationError(ctx, "creating the compute instance", opts, err)
+		}
+		disks = append(disks, dataDisk)
+		createsRetainedDataDisk = dataDisk.GetSource() == "" && opts.RetainDataDisk
+	}
+
+	if opts.ManagedFirewall {
+		// The rule is shared with the other workspaces on the network, so it isn't recorded for the rollback
+		err = ensureManagedFirewall(ctx, clients, opts, logWriter)
+		if err != nil {
+			return wrapOperationError(ctx, "creating the managed firewall rule", opts, err)
+		}
+	}
+
+	var resourcePolicies []string
+	if opts.HasSchedule() {
+		schedulePolicy, err := createSchedulePolicy(ctx, clients, workspaceId, opts, logWriter)
+		if err != nil {
+			return wrapOperationError(ctx, "creating the instance schedule", opts, err)
+		}
+		resourcePolicies = append(resourcePolicies, schedulePolicy)
+		tx.Record("instance schedule "+getSchedulePolicyName(workspaceId), func(ctx context.Context, logWriter io.Writer) error {
+			ctx, cancel := withOperationTimeout(ctx, opts)
+			defer cancel()
+
+			return deleteSchedulePolicy(ctx, clients, workspaceId, opts, logWriter)
+		})
+	}
+
+	operation, err := instancesClient.Insert(ctx, &computepb.InsertInstanceRequest{
 		Project: opts.ProjectID,
 		Zone:    opts.Zone,
 		InstanceResource: &computepb.Instance{
-			Name:        toPtr(instanceName),
-			MachineType: toPtr(machineType),
-			Disks: []*computepb.AttachedDisk{
-				{
-					AutoDelete: toPtr(true),
-					Boot:       toPtr(true),
-					Type:       toPtr(computepb.AttachedDisk_PERSISTENT.String()),
-					InitializeParams: &computepb.AttachedDiskInitializeParams{
-						DiskType:    toPtr(diskType),
-						SourceImage: toPtr(opts.VMImage),
-						DiskSizeGb:  toPtr(int64(opts.DiskSize)),
-					},
-				},
-			},
-			NetworkInterfaces: []*computepb.NetworkInterface{
-				{
-					Name: toPtr("global/networks/default"),
-					AccessConfigs: []*computepb.AccessConfig{
-						{
-							Name: toPtr("External NAT"),
-							Type: toPtr(computepb.AccessConfig_ONE_TO_ONE_NAT.String()),
-						},
-					},
-				},
+			Name:              toPtr(instanceName),
+			MachineType:       toPtr(machineType),
+			Labels:            labels,
+			Disks:             disks,
+			NetworkInterfaces: []*computepb.NetworkInterface{networkInterface},
+			Tags: &computepb.Tags{
+				Items: getNetworkTags(opts),
 			},
+			Scheduling:                 getScheduling(opts),
+			GuestAccelerators:          getGuestAccelerators(opts),
+			ServiceAccounts:            getServiceAccounts(opts),
+			ShieldedInstanceConfig:     getShieldedInstanceConfig(opts),
+			ConfidentialInstanceConfig: getConfidentialInstanceConfig(opts),
+			ResourcePolicies:           resourcePolicies,
 			Metadata: &computepb.Metadata{
-				Items: []*computepb.Items{
-					{
-						Key:   toPtr("startup-script"),
-						Value: &initScript,
-					},
-				},
+				Items: metadataItems,
 			},
 		},
 	})
 	if err != nil {
-		close(spinner)
+		return wrapOperationError(ctx, "creating the compute instance", opts, err)
+	}
+
+	// The instance and its disks can exist even if the operation fails. The data disk is recorded before the instance, so
+	// that it's deleted after the instance detached it.
+	if createsRetainedDataDisk {
+		tx.Record("data disk "+getDataDiskName(workspaceName), func(ctx context.Context, logWriter io.Writer) error {
+			ctx, cancel := withOperationTimeout(ctx, opts)
+			defer cancel()
+
+			err := deleteDataDisk(ctx, clients, workspaceName, opts, logWriter)
+			if isNotFound(err) {
+				return nil
+			}
+			return err
+		})
+	}
+	tx.Record("compute instance "+instanceName, func(ctx context.Context, logWriter io.Writer) error {
+		ctx, cancel := withOperationTimeout(ctx, opts)
+		defer cancel()
+
+		err := deleteComputeInstance(ctx, clients, workspaceId, opts, logWriter)
+		if isNotFound(err) {
+			return nil
+		}
 		return err
+	})
+
+	err = waitForOperation(ctx, operation, logWriter, "Creating GCP compute instance", "GCP compute instance created")
+	if err != nil {
+		return wrapOperationError(ctx, "creating the compute instance", opts, err)
+	}
+
+	// CollectOrphans may have deleted the rule as unused before the instance was attached to the network
+	if opts.ManagedFirewall {
+		err = ensureManagedFirewall(ctx, clients, opts, logWriter)
+		if err != nil {
+			return wrapOperationError(ctx, "creating the managed firewall rule", opts, err)
+		}
 	}
 
-	err = operation.Wait(context.Background())
-	close(spinner)
-	return err
+	return nil
 }
 
-func GetComputeInstance(workspace *workspace.Workspace, opts *types.TargetOptions) (*computepb.Instance, error) {
-	client, err := compute.NewInstancesRESTClient(context.Background(), option.WithCredentialsFile(opts.CredentialFile))
+func GetComputeInstance(ctx context.Context, clients *ClientManager, workspace *workspace.Workspace, opts *types.TargetOptions) (*computepb.Instance, error) {
+	client, err := clients.InstancesClient(opts)
 	if err != nil {
 		return nil, err
 	}
-	defer client.Close()
 
-	return client.Get(context.Background(), &computepb.GetInstanceRequest{
+	ctx, cancel := withOperationTimeout(ctx, opts)
+	defer cancel()
+
+	instance, err := client.Get(ctx, &computepb.GetInstanceRequest{
 		Project:  opts.ProjectID,
 		Zone:     opts.Zone,
 		Instance: getResourceName(workspace.Id),
 	})
+	if err != nil {
+		return nil, wrapOperationError(ctx, "getting the compute instance", opts, err)
+	}
+
+	return instance, nil
+}
+
+// GetWorkspaceMetadata returns the metadata of the workspace compute instance, including whether it was preempted.
+// A spot instance that GCP deleted on preemption is reported as preempted instead of failing.
+func GetWorkspaceMetadata(ctx context.Context, clients *ClientManager, workspace *workspace.Workspace, opts *types.TargetOptions) (*types.WorkspaceMetadata, error) {
+	instanceName := getResourceName(workspace.Id)
+
+	instance, err := GetComputeInstance(ctx, clients, workspace, opts)
+	if isNotFound(err) && opts.IsDeletedOnPreemption() {
+		preempted, preemptedErr := wasPreempted(ctx, clients, opts, instanceName, "")
+		if preemptedErr != nil || !preempted {
+			return nil, err
+		}
+
+		return &types.WorkspaceMetadata{
+			VirtualMachineName: instanceName,
+			Location:           opts.Zone,
+			ProvisioningModel:  types.ProvisioningModelSpot,
+			Preempted:          true,
+		}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	metadata := types.ToWorkspaceMetadata(instance)
+	if metadata.ProvisioningModel != types.ProvisioningModelStandard && instance.GetStatus() == computepb.Instance_TERMINATED.String() {
+		metadata.Preempted, err = wasPreempted(ctx, clients, opts, instanceName, instance.GetLastStartTimestamp())
+		if err != nil {
+			return nil, err
+		}
+	}
+	metadata.Schedule, err = getInstanceSchedule(ctx, clients, opts, instance)
+	if err != nil {
+		return nil, err
+	}
+	if metadata.IdleTimeout > 0 && instance.GetStatus() == computepb.Instance_RUNNING.String() {
+		metadata.AutoStopAt, err = getAutoStopAt(ctx, clients, opts, instanceName, metadata.IdleTimeout)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &metadata, nil
 }
 
 func getResourceName(identifier string) string {
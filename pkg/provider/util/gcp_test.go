@@ -0,0 +1,146 @@
+package util
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+
+	"cloud.google.com/go/compute/apiv1/computepb"
+	"github.com/daytonaio/daytona-provider-gcp/internal/fakecompute"
+	"github.com/daytonaio/daytona-provider-gcp/pkg/startupscript"
+	"github.com/daytonaio/daytona-provider-gcp/pkg/types"
+	"github.com/daytonaio/daytona/pkg/workspace"
+	"google.golang.org/api/googleapi"
+)
+
+func TestWorkspaceLifecycle(t *testing.T) {
+	server := fakecompute.NewServer()
+	defer server.Close()
+
+	clients := NewClientManager(server.ClientOptions()...)
+	defer clients.Close()
+
+	ctx := context.Background()
+	logWriter := &bytes.Buffer{}
+	opts := &types.TargetOptions{
+		AuthMode:    types.AuthModeApplicationDefault,
+		ProjectID:   "project",
+		Zone:        "us-central1-a",
+		MachineType: "n1-standard-1",
+		DiskType:    "pd-standard",
+		DiskSize:    20,
+		VMImage:     "projects/ubuntu-os-cloud/global/images/family/ubuntu-2204-lts",
+	}
+	ws := &workspace.Workspace{
+		Id:      "123",
+		Name:    "workspace",
+		EnvVars: map[string]string{"DAYTONA_WS_ID": "123"},
+	}
+
+	err := CreateWorkspace(ctx, clients, ws, opts, "echo init", nil, logWriter)
+	if err != nil {
+		t.Fatalf("Error creating workspace: %s", err)
+	}
+
+	instance, err := GetComputeInstance(ctx, clients, ws, opts)
+	if err != nil {
+		t.Fatalf("Error getting instance: %s", err)
+	}
+	if instance.GetName() != "daytona-123" {
+		t.Errorf("Expected instance name daytona-123, got %s", instance.GetName())
+	}
+	if !strings.HasSuffix(instance.GetMachineType(), "/n1-standard-1") {
+		t.Errorf("Expected machine type n1-standard-1, got %s", instance.GetMachineType())
+	}
+
+	metadata := map[string]string{}
+	for _, item := range instance.GetMetadata().GetItems() {
+		metadata[item.GetKey()] = item.GetValue()
+	}
+	if strings.Contains(metadata["startup-script"], "echo init") || strings.Contains(metadata["startup-script"], "DAYTONA_WS_ID") {
+		t.Errorf("Expected the startup script to leave out the secrets")
+	}
+	if metadata[startupscript.SecretsMetadataPrefix+"init-script"] != "echo init" {
+		t.Errorf("Expected the init script in the secret metadata items")
+	}
+	if !strings.Contains(metadata[startupscript.SecretsMetadataPrefix+"env"], "DAYTONA_WS_ID='123'") {
+		t.Errorf("Expected the environment variables in the secret metadata items")
+	}
+
+	err = RemoveWorkspaceSecrets(ctx, clients, ws, opts)
+	if err != nil {
+		t.Fatalf("Error removing the workspace secrets: %s", err)
+	}
+
+	instance, err = GetComputeInstance(ctx, clients, ws, opts)
+	if err != nil {
+		t.Fatalf("Error getting instance: %s", err)
+	}
+	items := instance.GetMetadata().GetItems()
+	if len(items) != 1 || items[0].GetKey() != "startup-script" || items[0].GetValue() != metadata["startup-script"] {
+		t.Errorf("Expected only the startup script to be left in the metadata, got %v", items)
+	}
+
+	// Without secrets left there is nothing to update
+	err = RemoveWorkspaceSecrets(ctx, clients, ws, opts)
+	if err != nil {
+		t.Fatalf("Error removing the workspace secrets again: %s", err)
+	}
+
+	err = StopWorkspace(ctx, clients, ws, opts, logWriter)
+	if err != nil {
+		t.Fatalf("Error stopping workspace: %s", err)
+	}
+
+	instance, err = GetComputeInstance(ctx, clients, ws, opts)
+	if err != nil {
+		t.Fatalf("Error getting instance: %s", err)
+	}
+	if instance.GetStatus() != computepb.Instance_TERMINATED.String() {
+		t.Errorf("Expected instance status TERMINATED, got %s", instance.GetStatus())
+	}
+
+	err = StartWorkspace(ctx, clients, ws, opts, logWriter)
+	if err != nil {
+		t.Fatalf("Error starting workspace: %s", err)
+	}
+
+	err = DeleteWorkspace(ctx, clients, ws, opts, logWriter)
+	if err != nil {
+		t.Fatalf("Error deleting workspace: %s", err)
+	}
+
+	_, err = GetComputeInstance(ctx, clients, ws, opts)
+	var apiErr *googleapi.Error
+	if !errors.As(err, &apiErr) || apiErr.Code != http.StatusNotFound {
+		t.Fatalf("Expected a not found error for the deleted instance, got %v", err)
+	}
+}
+
+func TestCreateWorkspaceAlreadyExists(t *testing.T) {
+	server := fakecompute.NewServer()
+	defer server.Close()
+
+	clients := NewClientManager(server.ClientOptions()...)
+	defer clients.Close()
+
+	opts := &types.TargetOptions{
+		AuthMode:  types.AuthModeApplicationDefault,
+		ProjectID: "project",
+		Zone:      "us-central1-a",
+	}
+	ws := &workspace.Workspace{Id: "123", EnvVars: map[string]string{}}
+
+	err := CreateWorkspace(context.Background(), clients, ws, opts, "", nil, &bytes.Buffer{})
+	if err != nil {
+		t.Fatalf("Error creating workspace: %s", err)
+	}
+
+	err = CreateWorkspace(context.Background(), clients, ws, opts, "", nil, &bytes.Buffer{})
+	if err == nil {
+		t.Fatalf("Expected an error when creating an existing workspace")
+	}
+}
@@ -0,0 +1,93 @@
+package util
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"cloud.google.com/go/compute/apiv1/computepb"
+	"github.com/daytonaio/daytona-provider-gcp/pkg/startupscript"
+	"github.com/daytonaio/daytona-provider-gcp/pkg/types"
+)
+
+// gpuMachineFamilies are the machine families with built-in GPUs.
+var gpuMachineFamilies = []string{"a2", "a3", "g2"}
+
+// hasGPUs returns whether the VM has GPUs, either attached or built into the machine type.
+func hasGPUs(opts *types.TargetOptions) bool {
+	return opts.AcceleratorType != "" || isGPUMachineType(opts.MachineType)
+}
+
+func isGPUMachineType(machineType string) bool {
+	return slices.Contains(gpuMachineFamilies, getMachineFamily(machineType))
+}
+
+// getMachineFamily returns the machine family of a machine type, e.g. n2d for n2d-standard-2.
+func getMachineFamily(machineType string) string {
+	family, _, _ := strings.Cut(machineType, "-")
+	return family
+}
+
+// isN1MachineType returns whether the machine type is an N1 machine type, including N1 custom machine types.
+func isN1MachineType(machineType string) bool {
+	return strings.HasPrefix(machineType, "n1-") || strings.HasPrefix(machineType, "custom-")
+}
+
+// getGuestAccelerators returns the GPUs attached to the VM.
+func getGuestAccelerators(opts *types.TargetOptions) []*computepb.AcceleratorConfig {
+	if opts.AcceleratorType == "" {
+		return nil
+	}
+
+	return []*computepb.AcceleratorConfig{
+		{
+			AcceleratorType:  toPtr(fmt.Sprintf("zones/%s/acceleratorTypes/%s", opts.Zone, opts.AcceleratorType)),
+			AcceleratorCount: toPtr(int32(opts.GetAcceleratorCount())),
+		},
+	}
+}
+
+func validateAccelerator(ctx context.Context, clients *ClientManager, opts *types.TargetOptions, validationErr *ValidationError) error {
+	if opts.InstallGPUDrivers && !hasGPUs(opts) {
+		validationErr.add("Install GPU Drivers", "machine type %q has no GPUs and no accelerator type is set", opts.MachineType)
+	}
+	// Container-Optimized OS has no NVIDIA Container Toolkit, so Docker can't pass the GPUs to the project containers
+	if opts.InstallGPUDrivers && startupscript.DistroForImage(opts.VMImage) == startupscript.DistroCOS {
+		validationErr.add("Install GPU Drivers", "GPU drivers can't be installed on Container-Optimized OS image %q, use a Debian, Ubuntu or RHEL compatible image", opts.VMImage)
+	}
+
+	// The accelerator count defaults to 1, so it is ignored without an accelerator type
+	if opts.AcceleratorType == "" {
+		return nil
+	}
+
+	if !isN1MachineType(opts.MachineType) {
+		validationErr.add("Accelerator Type", "GPUs can only be attached to N1 machine types, not %q", opts.MachineType)
+	}
+
+	client, err := clients.AcceleratorTypesClient(opts)
+	if err != nil {
+		return err
+	}
+
+	acceleratorType, err := client.Get(ctx, &computepb.GetAcceleratorTypeRequest{
+		Project:         opts.ProjectID,
+		Zone:            opts.Zone,
+		AcceleratorType: opts.AcceleratorType,
+	})
+	if isNotFound(err) {
+		validationErr.add("Accelerator Type", "accelerator type %q is not available in zone %q", opts.AcceleratorType, opts.Zone)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	maxCount := acceleratorType.GetMaximumCardsPerInstance()
+	if maxCount > 0 && int32(opts.GetAcceleratorCount()) > maxCount {
+		validationErr.add("Accelerator Count", "at most %d %s GPUs can be attached to a VM", maxCount, opts.AcceleratorType)
+	}
+
+	return nil
+}
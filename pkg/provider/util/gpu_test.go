@@ -0,0 +1,84 @@
+package util
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"cloud.google.com/go/compute/apiv1/computepb"
+	"github.com/daytonaio/daytona-provider-gcp/internal/fakecompute"
+	"github.com/daytonaio/daytona-provider-gcp/pkg/types"
+	"github.com/daytonaio/daytona/pkg/workspace"
+)
+
+func TestCreateWorkspaceWithGPU(t *testing.T) {
+	server := fakecompute.NewServer()
+	defer server.Close()
+
+	clients := NewClientManager(server.ClientOptions()...)
+	defer clients.Close()
+
+	opts := &types.TargetOptions{
+		AuthMode:          types.AuthModeApplicationDefault,
+		ProjectID:         "project",
+		Zone:              "us-central1-a",
+		MachineType:       "n1-standard-4",
+		DiskType:          "pd-standard",
+		DiskSize:          50,
+		VMImage:           "projects/ubuntu-os-cloud/global/images/family/ubuntu-2204-lts",
+		AcceleratorType:   "nvidia-tesla-t4",
+		AcceleratorCount:  2,
+		InstallGPUDrivers: true,
+	}
+	ws := &workspace.Workspace{Id: "123", EnvVars: map[string]string{}}
+
+	err := CreateWorkspace(context.Background(), clients, ws, opts, "echo init", nil, &bytes.Buffer{})
+	if err != nil {
+		t.Fatalf("Error creating workspace: %s", err)
+	}
+
+	instance := server.GetInstance("project", "us-central1-a", "daytona-123")
+	accelerators := instance.GetGuestAccelerators()
+	if len(accelerators) != 1 || accelerators[0].GetAcceleratorType() != "zones/us-central1-a/acceleratorTypes/nvidia-tesla-t4" || accelerators[0].GetAcceleratorCount() != 2 {
+		t.Errorf("Expected 2 nvidia-tesla-t4 GPUs, got %v", accelerators)
+	}
+	if instance.GetScheduling().GetOnHostMaintenance() != computepb.Scheduling_TERMINATE.String() {
+		t.Errorf("Expected the instance to be terminated on host maintenance, got %v", instance.GetScheduling())
+	}
+
+	startupScript := instance.GetMetadata().GetItems()[0].GetValue()
+	if !strings.Contains(startupScript, "nvidia-ctk runtime configure --runtime=docker --set-as-default") {
+		t.Errorf("Expected the startup script to install the NVIDIA Container Toolkit")
+	}
+}
+
+func TestGetSchedulingWithGPUs(t *testing.T) {
+	tests := []struct {
+		name                 string
+		opts                 types.TargetOptions
+		wantAutomaticRestart bool
+	}{
+		{
+			name:                 "Machine type with built-in GPUs",
+			opts:                 types.TargetOptions{MachineType: "a2-highgpu-1g"},
+			wantAutomaticRestart: true,
+		},
+		{
+			name: "Spot VM with an attached GPU",
+			opts: types.TargetOptions{MachineType: "n1-standard-4", AcceleratorType: "nvidia-tesla-t4", ProvisioningModel: types.ProvisioningModelSpot},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			scheduling := getScheduling(&tt.opts)
+			if scheduling.GetOnHostMaintenance() != computepb.Scheduling_TERMINATE.String() {
+				t.Errorf("Expected the instance to be terminated on host maintenance, got %v", scheduling)
+			}
+			if scheduling.GetAutomaticRestart() != tt.wantAutomaticRestart {
+				t.Errorf("Expected automatic restart %t, got %v", tt.wantAutomaticRestart, scheduling)
+			}
+		})
+	}
+}
@@ -0,0 +1,64 @@
+package util
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"cloud.google.com/go/compute/apiv1/computepb"
+	"github.com/daytonaio/daytona-provider-gcp/pkg/startupscript"
+	"github.com/daytonaio/daytona-provider-gcp/pkg/types"
+)
+
+// enableGuestAttributesMetadataKey lets the VM write guest attributes, which the idle watchdog reports its last activity
+// in and the image builder a failed setup.
+const enableGuestAttributesMetadataKey = "enable-guest-attributes"
+
+// getIdleMetadataItems returns the instance metadata items of the idle watchdog.
+func getIdleMetadataItems(opts *types.TargetOptions) []*computepb.Items {
+	if opts.GetIdleTimeout() == 0 {
+		return nil
+	}
+
+	return []*computepb.Items{
+		{
+			Key:   toPtr(types.IdleTimeoutMetadataKey),
+			Value: toPtr(strconv.Itoa(opts.IdleTimeout)),
+		},
+	}
+}
+
+// getAutoStopAt returns the time the idle watchdog will stop the instance at if it stays idle, from the last activity
+// it reported. It is empty until the watchdog reported the first activity.
+func getAutoStopAt(ctx context.Context, clients *ClientManager, opts *types.TargetOptions, instanceName string, idleTimeout int) (string, error) {
+	client, err := clients.InstancesClient(opts)
+	if err != nil {
+		return "", err
+	}
+
+	attributes, err := client.GetGuestAttributes(ctx, &computepb.GetGuestAttributesInstanceRequest{
+		Project:     opts.ProjectID,
+		Zone:        opts.Zone,
+		Instance:    instanceName,
+		VariableKey: toPtr(startupscript.LastActivityGuestAttribute),
+	})
+	if isNotFound(err) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+
+	lastActivity, err := strconv.ParseInt(attributes.GetVariableValue(), 10, 64)
+	if err != nil {
+		return "", nil
+	}
+
+	return time.Unix(lastActivity, 0).UTC().Add(time.Duration(idleTimeout) * time.Minute).Format(time.RFC3339), nil
+}
+
+func validateIdleTimeout(opts *types.TargetOptions, validationErr *ValidationError) {
+	if opts.IdleTimeout < 0 {
+		validationErr.add("Idle Timeout", "idle timeout must not be negative")
+	}
+}
@@ -0,0 +1,92 @@
+package util
+
+import (
+	"bytes"
+	"context"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/daytonaio/daytona-provider-gcp/internal/fakecompute"
+	"github.com/daytonaio/daytona-provider-gcp/pkg/startupscript"
+	"github.com/daytonaio/daytona-provider-gcp/pkg/types"
+	"github.com/daytonaio/daytona/pkg/workspace"
+)
+
+func TestCreateIdleWorkspace(t *testing.T) {
+	server := fakecompute.NewServer()
+	defer server.Close()
+
+	clients := NewClientManager(server.ClientOptions()...)
+	defer clients.Close()
+
+	ctx := context.Background()
+	opts := &types.TargetOptions{
+		AuthMode:    types.AuthModeApplicationDefault,
+		ProjectID:   "project",
+		Zone:        "us-central1-a",
+		IdleTimeout: 30,
+	}
+	ws := &workspace.Workspace{Id: "123", EnvVars: map[string]string{}}
+
+	err := CreateWorkspace(ctx, clients, ws, opts, "", nil, &bytes.Buffer{})
+	if err != nil {
+		t.Fatalf("Error creating workspace: %s", err)
+	}
+
+	items := map[string]string{}
+	for _, item := range server.GetInstance("project", "us-central1-a", "daytona-123").GetMetadata().GetItems() {
+		items[item.GetKey()] = item.GetValue()
+	}
+	if items[enableGuestAttributesMetadataKey] != "TRUE" || items[types.IdleTimeoutMetadataKey] != "30" {
+		t.Errorf("Expected guest attributes and the idle timeout in the instance metadata, got %v", items)
+	}
+	if !strings.Contains(items["startup-script"], "daytona-idle-watchdog.timer") {
+		t.Errorf("Expected the startup script to install the idle watchdog")
+	}
+
+	metadata, err := GetWorkspaceMetadata(ctx, clients, ws, opts)
+	if err != nil {
+		t.Fatalf("Error getting the workspace metadata: %s", err)
+	}
+	if metadata.IdleTimeout != 30 || metadata.AutoStopAt != "" {
+		t.Errorf("Expected an idle timeout of 30 minutes without an auto-stop time, got %d and %q", metadata.IdleTimeout, metadata.AutoStopAt)
+	}
+
+	lastActivity := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	server.SetGuestAttribute("project", "us-central1-a", "daytona-123", startupscript.LastActivityGuestAttribute, strconv.FormatInt(lastActivity.Unix(), 10))
+
+	metadata, err = GetWorkspaceMetadata(ctx, clients, ws, opts)
+	if err != nil {
+		t.Fatalf("Error getting the workspace metadata: %s", err)
+	}
+	if metadata.AutoStopAt != "2024-05-01T12:30:00Z" {
+		t.Errorf("Expected the VM to auto-stop at 2024-05-01T12:30:00Z, got %q", metadata.AutoStopAt)
+	}
+}
+
+func TestCreateWorkspaceWithoutIdleTimeout(t *testing.T) {
+	server := fakecompute.NewServer()
+	defer server.Close()
+
+	clients := NewClientManager(server.ClientOptions()...)
+	defer clients.Close()
+
+	opts := &types.TargetOptions{
+		AuthMode:  types.AuthModeApplicationDefault,
+		ProjectID: "project",
+		Zone:      "us-central1-a",
+	}
+
+	err := CreateWorkspace(context.Background(), clients, &workspace.Workspace{Id: "123", EnvVars: map[string]string{}}, opts, "", nil, &bytes.Buffer{})
+	if err != nil {
+		t.Fatalf("Error creating workspace: %s", err)
+	}
+
+	for _, item := range server.GetInstance("project", "us-central1-a", "daytona-123").GetMetadata().GetItems() {
+		if item.GetKey() == types.IdleTimeoutMetadataKey || strings.Contains(item.GetValue(), "daytona-idle-watchdog") {
+			t.Errorf("Expected no idle watchdog without an idle timeout")
+		}
+	}
+}
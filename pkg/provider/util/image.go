@@ -0,0 +1,257 @@
+package util
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"time"
+
+	compute "cloud.google.com/go/compute/apiv1"
+	"cloud.google.com/go/compute/apiv1/computepb"
+	logwriters "github.com/daytonaio/daytona-provider-gcp/internal/log"
+	"github.com/daytonaio/daytona-provider-gcp/pkg/startupscript"
+	"github.com/daytonaio/daytona-provider-gcp/pkg/types"
+)
+
+// maxImageFamilyLength leaves room for the timestamp suffix of the image names in the family.
+const maxImageFamilyLength = 48
+
+var imageFamilyPattern = regexp.MustCompile(`^[a-z]([-a-z0-9]*[a-z0-9])?$`)
+
+// getImageBuilderId returns the ID the builder VM of the image family is named after, daytona-image-builder-<family>.
+func getImageBuilderId(family string) string {
+	id := "image-builder-" + family
+	if len(getResourceName(id)) > 63 {
+		id = strings.TrimRight(id[:63-len(getResourceName(""))], "-")
+	}
+
+	return id
+}
+
+// getImageBuilderOptions returns the options of the builder VM, a plain VM created from the VM image.
+func getImageBuilderOptions(opts *types.TargetOptions) *types.TargetOptions {
+	builderOpts := *opts
+	builderOpts.ImageFamily = ""
+	builderOpts.SourceSnapshot = ""
+	builderOpts.DataDiskSize = 0
+	builderOpts.SourceDataSnapshot = ""
+	builderOpts.ManagedFirewall = false
+	builderOpts.ProvisioningModel = types.ProvisioningModelStandard
+	builderOpts.AcceleratorType = ""
+	builderOpts.AcceleratorCount = 0
+	builderOpts.InstallGPUDrivers = false
+	builderOpts.IdleTimeout = 0
+	builderOpts.StartSchedule = ""
+	builderOpts.StopSchedule = ""
+	// The setup only downloads from the internet, so the builder doesn't need the workspace service account
+	builderOpts.ServiceAccount = types.ServiceAccountNone
+
+	return &builderOpts
+}
+
+// getImageBuilderScript returns the startup script of the builder VM. It runs the setup and shuts the VM down once the setup
+// succeeded, which tells the provider that the boot disk is ready to be imaged. A failed setup publishes its exit status in
+// a guest attribute instead, which fails the bake right away.
+func getImageBuilderScript(opts *types.TargetOptions) (*startupscript.Script, error) {
+	return startupscript.NewBuilder(startupscript.Config{
+		Distro:      startupscript.DistroForImage(opts.VMImage),
+		ExitOnError: true,
+	}).Enable(startupscript.StageSetup, startupscript.StageBakeImage).Build()
+}
+
+// ensureImageFamily bakes the image family of the target options unless it already has an image.
+func ensureImageFamily(ctx context.Context, clients *ClientManager, opts *types.TargetOptions, logWriter io.Writer) error {
+	client, err := clients.ImagesClient(opts)
+	if err != nil {
+		return err
+	}
+
+	_, err = client.GetFromFamily(ctx, &computepb.GetFromFamilyImageRequest{
+		Project: opts.ProjectID,
+		Family:  opts.ImageFamily,
+	})
+	if !isNotFound(err) {
+		return err
+	}
+
+	logWriter.Write([]byte("Image family " + opts.ImageFamily + " has no images, baking it from " + opts.VMImage + "\n"))
+	return BakeImage(ctx, clients, opts, logWriter)
+}
+
+// BakeImage creates a new image in the image family of the target options. A builder VM created from the VM image runs
+// the VM setup and shuts down, then its boot disk is imaged and the builder VM is deleted.
+func BakeImage(ctx context.Context, clients *ClientManager, opts *types.TargetOptions, logWriter io.Writer) error {
+	instancesClient, err := clients.InstancesClient(opts)
+	if err != nil {
+		return err
+	}
+
+	imagesClient, err := clients.ImagesClient(opts)
+	if err != nil {
+		return err
+	}
+
+	builderOpts := getImageBuilderOptions(opts)
+	builderId := getImageBuilderId(opts.ImageFamily)
+	builderName := getResourceName(builderId)
+
+	builderScript, err := getImageBuilderScript(opts)
+	if err != nil {
+		return err
+	}
+
+	// The builder VM has to go away even if the bake failed or timed out, or the creation failed after the insert
+	tx := NewTransaction()
+	defer tx.Rollback(context.WithoutCancel(ctx), logWriter)
+
+	err = createComputeInstance(ctx, clients, builderId, builderId, getLabels(opts), builderScript, builderOpts, tx, logWriter)
+	// The builder VM is named after the family, so another workspace is baking the family right now
+	if isConflict(err) {
+		logWriter.Write([]byte("Image family " + opts.ImageFamily + " is being baked by another workspace, waiting for it\n"))
+		return waitForConcurrentBake(ctx, instancesClient, imagesClient, builderName, opts, logWriter)
+	}
+	if err != nil {
+		return err
+	}
+
+	instance, err := waitForImageBuilder(ctx, instancesClient, builderName, opts, logWriter)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := withOperationTimeout(ctx, opts)
+	defer cancel()
+
+	imageName := fmt.Sprintf("%s-%s", opts.ImageFamily, time.Now().UTC().Format("20060102150405"))
+	op, err := imagesClient.Insert(ctx, &computepb.InsertImageRequest{
+		Project: opts.ProjectID,
+		ImageResource: &computepb.Image{
+			Name:        toPtr(imageName),
+			Family:      toPtr(opts.ImageFamily),
+			SourceDisk:  toPtr(instance.GetDisks()[0].GetSource()),
+			Description: toPtr("Daytona workspace image baked from " + opts.VMImage),
+			Labels:      getLabels(opts),
+		},
+	})
+	if err != nil {
+		return wrapOperationError(ctx, "creating the image", opts, err)
+	}
+
+	err = waitForOperation(ctx, op, logWriter, "Creating GCP image "+imageName, "GCP image "+imageName+" created")
+	return wrapOperationError(ctx, "creating the image", opts, err)
+}
+
+// waitForImageBuilder waits for the builder VM to shut down after the VM setup. It fails as soon as the setup reports
+// a failure.
+func waitForImageBuilder(ctx context.Context, client *compute.InstancesClient, builderName string, opts *types.TargetOptions, logWriter io.Writer) (*computepb.Instance, error) {
+	ctx, cancel := withOperationTimeout(ctx, opts)
+	defer cancel()
+
+	spinner := logwriters.ShowSpinner(ctx, logWriter, "Waiting for the image builder VM to set up", "Image builder VM set up")
+	defer close(spinner)
+
+	for {
+		instance, err := client.Get(ctx, &computepb.GetInstanceRequest{
+			Project:  opts.ProjectID,
+			Zone:     opts.Zone,
+			Instance: builderName,
+		})
+		if err != nil {
+			return nil, wrapOperationError(ctx, "baking the image", opts, err)
+		}
+
+		if instance.GetStatus() == computepb.Instance_TERMINATED.String() {
+			return instance, nil
+		}
+
+		// A failed setup leaves the VM running, so the guest attribute tells it apart from a setup that takes long
+		attribute, err := client.GetGuestAttributes(ctx, &computepb.GetGuestAttributesInstanceRequest{
+			Project:     opts.ProjectID,
+			Zone:        opts.Zone,
+			Instance:    builderName,
+			VariableKey: toPtr(startupscript.SetupFailedGuestAttribute),
+		})
+		if err == nil {
+			return nil, fmt.Errorf("the setup of the image builder VM %s failed with exit status %s", builderName, attribute.GetVariableValue())
+		}
+		if !isNotFound(err) {
+			return nil, wrapOperationError(ctx, "baking the image", opts, err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, wrapOperationError(ctx, "baking the image", opts, ctx.Err())
+		case <-time.After(statusPollInterval):
+		}
+	}
+}
+
+// waitForConcurrentBake waits for the bake that owns the builder VM to add an image to the family. The other bake takes
+// up to an operation timeout for the VM setup and another one for the image, so the wait is bounded by twice the
+// operation timeout. It fails if the builder VM is deleted without an image, i.e. the other bake failed.
+func waitForConcurrentBake(ctx context.Context, instancesClient *compute.InstancesClient, imagesClient *compute.ImagesClient, builderName string, opts *types.TargetOptions, logWriter io.Writer) error {
+	ctx, cancel := context.WithTimeout(ctx, 2*opts.GetOperationTimeout())
+	defer cancel()
+
+	spinnerCtx, cancelSpinner := context.WithCancel(ctx)
+	defer cancelSpinner()
+
+	spinner := logwriters.ShowSpinner(spinnerCtx, logWriter, "Waiting for the concurrent bake of image family "+opts.ImageFamily, "Image family "+opts.ImageFamily+" baked")
+	for {
+		_, err := imagesClient.GetFromFamily(ctx, &computepb.GetFromFamilyImageRequest{
+			Project: opts.ProjectID,
+			Family:  opts.ImageFamily,
+		})
+		if err == nil {
+			close(spinner)
+			return nil
+		}
+		if !isNotFound(err) {
+			return wrapOperationError(ctx, "waiting for the concurrent bake", opts, err)
+		}
+
+		_, err = instancesClient.Get(ctx, &computepb.GetInstanceRequest{
+			Project:  opts.ProjectID,
+			Zone:     opts.Zone,
+			Instance: builderName,
+		})
+		if isNotFound(err) {
+			// The image may have been created right before the builder VM was deleted
+			_, err = imagesClient.GetFromFamily(ctx, &computepb.GetFromFamilyImageRequest{
+				Project: opts.ProjectID,
+				Family:  opts.ImageFamily,
+			})
+			if err == nil {
+				close(spinner)
+				return nil
+			}
+			return fmt.Errorf("the concurrent bake of image family %s failed without creating an image", opts.ImageFamily)
+		}
+		if err != nil {
+			return wrapOperationError(ctx, "waiting for the concurrent bake", opts, err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("timeout: the concurrent bake of image family %s did not complete within %s: %w", opts.ImageFamily, 2*opts.GetOperationTimeout(), ctx.Err())
+		case <-time.After(statusPollInterval):
+		}
+	}
+}
+
+func validateImageFamily(opts *types.TargetOptions, validationErr *ValidationError) {
+	if opts.ImageFamily == "" {
+		return
+	}
+
+	if len(opts.ImageFamily) > maxImageFamilyLength || !imageFamilyPattern.MatchString(opts.ImageFamily) {
+		validationErr.add("Image Family", "image family %q must be at most %d lowercase letters, digits and dashes, starting with a letter", opts.ImageFamily, maxImageFamilyLength)
+	}
+
+	// Container-Optimized OS resets /etc on every boot, so the setup can't be baked into an image
+	if startupscript.DistroForImage(opts.VMImage) == startupscript.DistroCOS {
+		validationErr.add("Image Family", "images can't be prebaked from Container-Optimized OS image %q", opts.VMImage)
+	}
+}
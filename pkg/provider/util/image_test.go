@@ -0,0 +1,220 @@
+package util
+
+import (
+	"bytes"
+	"context"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/compute/apiv1/computepb"
+	"github.com/daytonaio/daytona-provider-gcp/internal/fakecompute"
+	"github.com/daytonaio/daytona-provider-gcp/pkg/startupscript"
+	"github.com/daytonaio/daytona-provider-gcp/pkg/types"
+	"github.com/daytonaio/daytona/pkg/workspace"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestCreateWorkspaceWithImageFamily(t *testing.T) {
+	statusPollInterval = time.Millisecond
+
+	server := fakecompute.NewServer()
+	defer server.Close()
+
+	clients := NewClientManager(server.ClientOptions()...)
+	defer clients.Close()
+
+	ctx := context.Background()
+	logWriter := &bytes.Buffer{}
+	opts := &types.TargetOptions{
+		AuthMode:     types.AuthModeApplicationDefault,
+		ProjectID:    "project",
+		Zone:         "us-central1-a",
+		MachineType:  "n1-standard-1",
+		DiskType:     "pd-standard",
+		DiskSize:     20,
+		VMImage:      "projects/ubuntu-os-cloud/global/images/family/ubuntu-2204-lts",
+		ImageFamily:  "daytona-workspace",
+		DataDiskSize: 100,
+	}
+
+	// The builder VM shuts down once the VM setup is done
+	server.QueueStatuses("project", "us-central1-a", "daytona-image-builder-daytona-workspace", computepb.Instance_RUNNING, computepb.Instance_TERMINATED)
+
+	for _, id := range []string{"123", "456"} {
+		err := CreateWorkspace(ctx, clients, &workspace.Workspace{Id: id, EnvVars: map[string]string{}}, opts, "echo init", nil, logWriter)
+		if err != nil {
+			t.Fatalf("Error creating workspace %s: %s", id, err)
+		}
+	}
+
+	builds := 0
+	for _, request := range server.Requests() {
+		if request == "insert daytona-image-builder-daytona-workspace" {
+			builds++
+		}
+	}
+	if builds != 1 {
+		t.Errorf("Expected the image family to be baked once, got %d builds", builds)
+	}
+
+	builder := server.GetInstance("project", "us-central1-a", "daytona-image-builder-daytona-workspace")
+	if builder != nil {
+		t.Errorf("Expected the image builder VM to be deleted")
+	}
+
+	image, err := getImage(ctx, clients, opts, opts.GetBootImage())
+	if err != nil {
+		t.Fatalf("Error getting the baked image: %s", err)
+	}
+	if !strings.HasPrefix(image.GetName(), "daytona-workspace-") || image.GetDiskSizeGb() != 20 {
+		t.Errorf("Expected a 20 GB image in the daytona-workspace family, got %v", image)
+	}
+
+	instance := server.GetInstance("project", "us-central1-a", "daytona-123")
+	bootDisk := instance.GetDisks()[0].GetInitializeParams()
+	if bootDisk.GetSourceImage() != "projects/project/global/images/family/daytona-workspace" {
+		t.Errorf("Expected the boot disk to be created from the image family, got %q", bootDisk.GetSourceImage())
+	}
+
+	startupScript := instance.GetMetadata().GetItems()[0].GetValue()
+	if !strings.Contains(startupScript, "if [ ! -f "+startupscript.BakedImageMarker+" ]; then\nuseradd") {
+		t.Errorf("Expected the startup script to skip the VM setup on baked images, got:\n%s", startupScript)
+	}
+}
+
+func TestImageBuilderScript(t *testing.T) {
+	builderScript, err := getImageBuilderScript(&types.TargetOptions{VMImage: "projects/ubuntu-os-cloud/global/images/family/ubuntu-2204-lts"})
+	if err != nil {
+		t.Fatalf("Error building the image builder script: %s", err)
+	}
+	imageBuilderScript := builderScript.StartupScript
+
+	if !strings.HasPrefix(imageBuilderScript, "#!/bin/bash\nset -e\n") {
+		t.Errorf("Expected the builder script to stop at the first failure")
+	}
+	if strings.Index(imageBuilderScript, "touch "+startupscript.BakedImageMarker) > strings.Index(imageBuilderScript, "shutdown -h now") {
+		t.Errorf("Expected the builder script to mark the image before shutting down")
+	}
+	if strings.Contains(imageBuilderScript, "daytona agent") || strings.Contains(imageBuilderScript, "export ") {
+		t.Errorf("Expected the builder script to leave out the workspace specific setup")
+	}
+	if len(builderScript.Secrets) > 0 {
+		t.Errorf("Expected the builder VM to get no secrets")
+	}
+	if !builderScript.GuestAttributes || !strings.Contains(imageBuilderScript, startupscript.SetupFailedGuestAttribute) {
+		t.Errorf("Expected the builder script to report a failed setup in a guest attribute")
+	}
+}
+
+func TestGetImageBuilderId(t *testing.T) {
+	id := getImageBuilderId(strings.Repeat("a", 47) + "-b")
+	if len(getResourceName(id)) > 63 || strings.HasSuffix(id, "-") {
+		t.Errorf("Expected a valid builder name, got %q", getResourceName(id))
+	}
+}
+
+func TestBakeImageConcurrently(t *testing.T) {
+	statusPollInterval = time.Millisecond
+
+	tests := []struct {
+		name      string
+		addsImage bool
+		wantErr   bool
+	}{
+		{
+			name:      "Other bake succeeds",
+			addsImage: true,
+		},
+		{
+			name:    "Other bake fails",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := fakecompute.NewServer()
+			defer server.Close()
+
+			clients := NewClientManager(server.ClientOptions()...)
+			defer clients.Close()
+
+			opts := &types.TargetOptions{
+				AuthMode:    types.AuthModeApplicationDefault,
+				ProjectID:   "project",
+				Zone:        "us-central1-a",
+				MachineType: "n1-standard-1",
+				DiskType:    "pd-standard",
+				DiskSize:    20,
+				VMImage:     "projects/ubuntu-os-cloud/global/images/family/ubuntu-2204-lts",
+				ImageFamily: "daytona-workspace",
+			}
+
+			// Another workspace started baking the family first
+			builderName := "daytona-image-builder-daytona-workspace"
+			server.SetInstance("project", "us-central1-a", &computepb.Instance{
+				Name:   proto.String(builderName),
+				Status: proto.String(computepb.Instance_RUNNING.String()),
+			})
+			go func() {
+				for !slices.Contains(server.Requests(), "insert "+builderName) {
+					time.Sleep(time.Millisecond)
+				}
+				if tt.addsImage {
+					server.AddImage("project", &computepb.Image{Name: proto.String("daytona-workspace-1"), Family: proto.String("daytona-workspace")})
+				}
+				server.DeleteInstance("project", "us-central1-a", builderName)
+			}()
+
+			logWriter := &bytes.Buffer{}
+			err := ensureImageFamily(context.Background(), clients, opts, logWriter)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ensureImageFamily() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !strings.Contains(logWriter.String(), "is being baked by another workspace") {
+				t.Errorf("Expected the wait for the other bake to be logged, got:\n%s", logWriter.String())
+			}
+		})
+	}
+}
+
+func TestBakeImageSetupFailure(t *testing.T) {
+	statusPollInterval = time.Millisecond
+
+	server := fakecompute.NewServer()
+	defer server.Close()
+
+	clients := NewClientManager(server.ClientOptions()...)
+	defer clients.Close()
+
+	opts := &types.TargetOptions{
+		AuthMode:    types.AuthModeApplicationDefault,
+		ProjectID:   "project",
+		Zone:        "us-central1-a",
+		MachineType: "n1-standard-1",
+		DiskType:    "pd-standard",
+		DiskSize:    20,
+		VMImage:     "projects/ubuntu-os-cloud/global/images/family/ubuntu-2204-lts",
+		ImageFamily: "daytona-workspace",
+	}
+
+	// The setup fails and leaves the builder VM running
+	builderName := "daytona-image-builder-daytona-workspace"
+	server.QueueStatuses("project", "us-central1-a", builderName, computepb.Instance_RUNNING)
+	server.SetGuestAttribute("project", "us-central1-a", builderName, startupscript.SetupFailedGuestAttribute, "100")
+
+	err := BakeImage(context.Background(), clients, opts, &bytes.Buffer{})
+	if err == nil || !strings.Contains(err.Error(), "exit status 100") {
+		t.Fatalf("Expected the failed setup to fail the bake, got %v", err)
+	}
+	if server.GetInstance("project", "us-central1-a", builderName) != nil {
+		t.Errorf("Expected the image builder VM to be deleted")
+	}
+	for _, request := range server.Requests() {
+		if strings.HasPrefix(request, "insert daytona-workspace-") {
+			t.Errorf("Expected no image to be created, got %q", request)
+		}
+	}
+}
@@ -0,0 +1,95 @@
+package util
+
+import (
+	"maps"
+	"regexp"
+	"strings"
+
+	"github.com/daytonaio/daytona-provider-gcp/internal"
+	"github.com/daytonaio/daytona-provider-gcp/pkg/types"
+	"github.com/daytonaio/daytona/pkg/workspace"
+)
+
+const (
+	// workspaceIdLabel ties resources like instances, disks and snapshots back to the workspace they were created for.
+	workspaceIdLabel     = "daytona-workspace-id"
+	workspaceNameLabel   = "daytona-workspace-name"
+	providerVersionLabel = "daytona-provider-version"
+	// managedByLabel marks every resource the provider creates.
+	managedByLabel = "managed-by"
+	managedByValue = "daytona"
+)
+
+// maxLabels is the maximum number of labels of a GCE resource.
+const maxLabels = 64
+
+var invalidLabelValueChars = regexp.MustCompile(`[^a-z0-9_-]`)
+
+// toLabelValue converts a value to a valid GCE label value.
+func toLabelValue(value string) string {
+	value = invalidLabelValueChars.ReplaceAllString(strings.ToLower(value), "-")
+	if len(value) > 63 {
+		value = value[:63]
+	}
+
+	return value
+}
+
+// toLabelKey converts a key to a valid GCE label key, which also has to start with a letter. Keys without a letter
+// convert to an empty key.
+func toLabelKey(key string) string {
+	return strings.TrimLeft(toLabelValue(key), "0123456789_-")
+}
+
+// getManagedLabels returns the labels of every resource the provider creates.
+func getManagedLabels() map[string]string {
+	return map[string]string{
+		managedByLabel:       managedByValue,
+		providerVersionLabel: toLabelValue(internal.Version),
+	}
+}
+
+// getWorkspaceLabels returns the labels of the resources of a workspace, the labels of the target options and the
+// labels set by the provider. The labels set by the provider take precedence.
+func getWorkspaceLabels(workspace *workspace.Workspace, opts *types.TargetOptions) map[string]string {
+	labels := getLabels(opts)
+	labels[workspaceIdLabel] = toLabelValue(workspace.Id)
+	labels[workspaceNameLabel] = toLabelValue(workspace.Name)
+
+	return labels
+}
+
+// getLabels returns the sanitized labels of the target options with the managed labels.
+func getLabels(opts *types.TargetOptions) map[string]string {
+	labels := map[string]string{}
+	for key, value := range opts.GetLabels() {
+		key = toLabelKey(key)
+		if key != "" {
+			labels[key] = toLabelValue(value)
+		}
+	}
+	maps.Copy(labels, getManagedLabels())
+
+	return labels
+}
+
+func validateLabels(opts *types.TargetOptions, validationErr *ValidationError) {
+	reserved := []string{workspaceIdLabel, workspaceNameLabel, providerVersionLabel, managedByLabel}
+
+	keys := map[string]bool{}
+	for key := range opts.GetLabels() {
+		labelKey := toLabelKey(key)
+		switch {
+		case labelKey == "":
+			validationErr.add("Labels", "label key %q must contain a letter", key)
+		case strings.HasPrefix(labelKey, "daytona-") || labelKey == managedByLabel:
+			validationErr.add("Labels", "label key %q is reserved for the labels set by the provider", key)
+		default:
+			keys[labelKey] = true
+		}
+	}
+
+	if len(keys)+len(reserved) > maxLabels {
+		validationErr.add("Labels", "a resource can have at most %d labels, including the %d labels set by the provider", maxLabels, len(reserved))
+	}
+}
@@ -0,0 +1,83 @@
+package util
+
+import (
+	"bytes"
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/daytonaio/daytona-provider-gcp/internal"
+	"github.com/daytonaio/daytona-provider-gcp/internal/fakecompute"
+	"github.com/daytonaio/daytona-provider-gcp/pkg/types"
+	"github.com/daytonaio/daytona/pkg/workspace"
+)
+
+func TestCreateWorkspaceLabels(t *testing.T) {
+	server := fakecompute.NewServer()
+	defer server.Close()
+
+	clients := NewClientManager(server.ClientOptions()...)
+	defer clients.Close()
+
+	ctx := context.Background()
+	opts := &types.TargetOptions{
+		AuthMode:     types.AuthModeApplicationDefault,
+		ProjectID:    "project",
+		Zone:         "us-central1-a",
+		DiskType:     "pd-standard",
+		DataDiskSize: 100,
+		Labels:       "Team=Platform Tools,cost-center=1234",
+	}
+	ws := &workspace.Workspace{Id: "abc123", Name: "My.Workspace", EnvVars: map[string]string{}}
+
+	err := CreateWorkspace(ctx, clients, ws, opts, "", nil, &bytes.Buffer{})
+	if err != nil {
+		t.Fatalf("Error creating workspace: %s", err)
+	}
+
+	want := map[string]string{
+		"team":               "platform-tools",
+		"cost-center":        "1234",
+		workspaceIdLabel:     "abc123",
+		workspaceNameLabel:   "my-workspace",
+		providerVersionLabel: toLabelValue(internal.Version),
+		managedByLabel:       managedByValue,
+	}
+
+	instance := server.GetInstance("project", "us-central1-a", "daytona-abc123")
+	if !reflect.DeepEqual(instance.GetLabels(), want) {
+		t.Errorf("Expected instance labels %v, got %v", want, instance.GetLabels())
+	}
+	for _, disk := range []string{"daytona-abc123", "daytona-my-workspace-data"} {
+		labels := server.GetDisk("project", "us-central1-a", disk).GetLabels()
+		if !reflect.DeepEqual(labels, want) {
+			t.Errorf("Expected labels %v on disk %s, got %v", want, disk, labels)
+		}
+	}
+
+	metadata, err := GetWorkspaceMetadata(ctx, clients, ws, opts)
+	if err != nil {
+		t.Fatalf("Error getting the workspace metadata: %s", err)
+	}
+	if !reflect.DeepEqual(metadata.Labels, want) {
+		t.Errorf("Expected labels %v in the metadata, got %v", want, metadata.Labels)
+	}
+}
+
+func TestToLabelKey(t *testing.T) {
+	tests := []struct {
+		key  string
+		want string
+	}{
+		{key: "team", want: "team"},
+		{key: "Cost Center", want: "cost-center"},
+		{key: "2fa_enabled", want: "fa_enabled"},
+		{key: "-_42", want: ""},
+	}
+
+	for _, tt := range tests {
+		if key := toLabelKey(tt.key); key != tt.want {
+			t.Errorf("Expected label key %q for %q, got %q", tt.want, tt.key, key)
+		}
+	}
+}
@@ -0,0 +1,149 @@
+package util
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+
+	"cloud.google.com/go/compute/apiv1/computepb"
+	"github.com/daytonaio/daytona-provider-gcp/pkg/types"
+)
+
+// getNetworkInterface builds the network interface of the workspace VM from the network target options.
+func getNetworkInterface(ctx context.Context, clients *ClientManager, opts *types.TargetOptions) (*computepb.NetworkInterface, error) {
+	networkInterface := &computepb.NetworkInterface{}
+
+	if opts.Network != "" {
+		networkInterface.Network = toPtr(getNetworkPath(opts))
+	}
+	if opts.Subnetwork != "" {
+		networkInterface.Subnetwork = toPtr(getSubnetworkPath(opts))
+	}
+	// The network is implied by the subnetwork, the default network is used only if neither is set
+	if networkInterface.Network == nil && networkInterface.Subnetwork == nil {
+		networkInterface.Network = toPtr(getDefaultNetworkPath(opts))
+	}
+
+	if !opts.GetAssignExternalIP() {
+		return networkInterface, nil
+	}
+
+	accessConfig := &computepb.AccessConfig{
+		Name: toPtr("External NAT"),
+		Type: toPtr(computepb.AccessConfig_ONE_TO_ONE_NAT.String()),
+	}
+	if opts.ExternalIPAddress != "" {
+		address, err := getExternalIPAddress(ctx, clients, opts)
+		if err != nil {
+			return nil, err
+		}
+		accessConfig.NatIP = toPtr(address.GetAddress())
+	}
+	networkInterface.AccessConfigs = []*computepb.AccessConfig{accessConfig}
+
+	return networkInterface, nil
+}
+
+// getExternalIPAddress looks up the static address reserved in the region of the zone by its address or name.
+func getExternalIPAddress(ctx context.Context, clients *ClientManager, opts *types.TargetOptions) (*computepb.Address, error) {
+	client, err := clients.AddressesClient(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	if net.ParseIP(opts.ExternalIPAddress) == nil {
+		return client.Get(ctx, &computepb.GetAddressRequest{
+			Project: opts.ProjectID,
+			Region:  getRegion(opts.Zone),
+			Address: opts.ExternalIPAddress,
+		})
+	}
+
+	it := client.List(ctx, &computepb.ListAddressesRequest{
+		Project: opts.ProjectID,
+		Region:  getRegion(opts.Zone),
+		Filter:  toPtr(fmt.Sprintf("address = %q", opts.ExternalIPAddress)),
+	})
+	address, err := it.Next()
+	if err != nil {
+		return nil, fmt.Errorf("failed to find the reserved address %s: %w", opts.ExternalIPAddress, err)
+	}
+
+	return address, nil
+}
+
+// getWorkspaceNetwork returns the path of the network the VM is attached to, looking up the network of the subnetwork if only that is set.
+func getWorkspaceNetwork(ctx context.Context, clients *ClientManager, opts *types.TargetOptions) (string, error) {
+	if opts.Network != "" {
+		return getNetworkPath(opts), nil
+	}
+	if opts.Subnetwork == "" {
+		return getDefaultNetworkPath(opts), nil
+	}
+
+	project, region, name, err := parseResourcePath(getSubnetworkPath(opts))
+	if err != nil {
+		return "", err
+	}
+
+	client, err := clients.SubnetworksClient(opts)
+	if err != nil {
+		return "", err
+	}
+
+	subnetwork, err := client.Get(ctx, &computepb.GetSubnetworkRequest{
+		Project:    project,
+		Region:     region,
+		Subnetwork: name,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return strings.TrimPrefix(subnetwork.GetNetwork(), "https://www.googleapis.com/compute/v1/"), nil
+}
+
+func getDefaultNetworkPath(opts *types.TargetOptions) string {
+	return fmt.Sprintf("projects/%s/global/networks/default", opts.GetNetworkProjectID())
+}
+
+func getNetworkPath(opts *types.TargetOptions) string {
+	if strings.Contains(opts.Network, "/") {
+		return opts.Network
+	}
+
+	return fmt.Sprintf("projects/%s/global/networks/%s", opts.GetNetworkProjectID(), opts.Network)
+}
+
+func getSubnetworkPath(opts *types.TargetOptions) string {
+	if strings.Contains(opts.Subnetwork, "/") {
+		return opts.Subnetwork
+	}
+
+	return fmt.Sprintf("projects/%s/regions/%s/subnetworks/%s", opts.GetNetworkProjectID(), getRegion(opts.Zone), opts.Subnetwork)
+}
+
+// parseResourcePath returns the project, location and name of a projects/<project>/<scope>/<location>/<kind>/<name>
+// or projects/<project>/global/<kind>/<name> resource path.
+func parseResourcePath(path string) (project, location, name string, err error) {
+	parts := strings.Split(strings.TrimPrefix(path, "https://www.googleapis.com/compute/v1/"), "/")
+	switch {
+	case len(parts) == 5 && parts[0] == "projects" && parts[2] == "global":
+		return parts[1], "", parts[4], nil
+	case len(parts) == 6 && parts[0] == "projects":
+		return parts[1], parts[3], parts[5], nil
+	default:
+		return "", "", "", fmt.Errorf("invalid resource path %q", path)
+	}
+}
+
+// getRegion returns the region of a zone, e.g. us-central1 for us-central1-a.
+func getRegion(zone string) string {
+	index := strings.LastIndex(zone, "-")
+	if index < 0 {
+		return zone
+	}
+
+	return zone[:index]
+}
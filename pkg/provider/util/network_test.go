@@ -0,0 +1,83 @@
+package util
+
+import (
+	"context"
+	"testing"
+
+	"cloud.google.com/go/compute/apiv1/computepb"
+	"github.com/daytonaio/daytona-provider-gcp/internal/fakecompute"
+	"github.com/daytonaio/daytona-provider-gcp/pkg/types"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestGetNetworkInterface(t *testing.T) {
+	server := fakecompute.NewServer()
+	defer server.Close()
+
+	server.AddAddress("project", "us-central1", &computepb.Address{
+		Name:    proto.String("workspace-ip"),
+		Address: proto.String("203.0.113.10"),
+	})
+
+	clients := NewClientManager(server.ClientOptions()...)
+	defer clients.Close()
+
+	tests := []struct {
+		name           string
+		opts           types.TargetOptions
+		wantNetwork    string
+		wantSubnetwork string
+		wantNatIP      *string
+	}{
+		{
+			name:        "Default network",
+			opts:        types.TargetOptions{},
+			wantNetwork: "projects/project/global/networks/default",
+			wantNatIP:   proto.String(""),
+		},
+		{
+			name:           "Shared VPC subnetwork without external IP",
+			opts:           types.TargetOptions{Subnetwork: "workspaces", NetworkProjectID: "host-project", AssignExternalIP: proto.Bool(false)},
+			wantSubnetwork: "projects/host-project/regions/us-central1/subnetworks/workspaces",
+		},
+		{
+			name:        "Custom network with a reserved address",
+			opts:        types.TargetOptions{Network: "workspaces", ExternalIPAddress: "203.0.113.10"},
+			wantNetwork: "projects/project/global/networks/workspaces",
+			wantNatIP:   proto.String("203.0.113.10"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := tt.opts
+			opts.ProjectID = "project"
+			opts.Zone = "us-central1-a"
+
+			networkInterface, err := getNetworkInterface(context.Background(), clients, &opts)
+			if err != nil {
+				t.Fatalf("Failed to get network interface: %s", err)
+			}
+
+			if networkInterface.GetNetwork() != tt.wantNetwork {
+				t.Errorf("Expected network %q, got %q", tt.wantNetwork, networkInterface.GetNetwork())
+			}
+			if networkInterface.GetSubnetwork() != tt.wantSubnetwork {
+				t.Errorf("Expected subnetwork %q, got %q", tt.wantSubnetwork, networkInterface.GetSubnetwork())
+			}
+
+			if tt.wantNatIP == nil {
+				if len(networkInterface.AccessConfigs) != 0 {
+					t.Errorf("Expected no access configs, got %v", networkInterface.AccessConfigs)
+				}
+				return
+			}
+			if len(networkInterface.AccessConfigs) != 1 {
+				t.Fatalf("Expected one access config, got %v", networkInterface.AccessConfigs)
+			}
+			if networkInterface.AccessConfigs[0].GetNatIP() != *tt.wantNatIP {
+				t.Errorf("Expected NAT IP %q, got %q", *tt.wantNatIP, networkInterface.AccessConfigs[0].GetNatIP())
+			}
+		})
+	}
+}
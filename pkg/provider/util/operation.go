@@ -0,0 +1,49 @@
+package util
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	compute "cloud.google.com/go/compute/apiv1"
+	logwriters "github.com/daytonaio/daytona-provider-gcp/internal/log"
+	"github.com/daytonaio/daytona-provider-gcp/pkg/types"
+)
+
+// withOperationTimeout bounds a lifecycle operation by the operation timeout set in the target options.
+func withOperationTimeout(ctx context.Context, opts *types.TargetOptions) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(ctx, opts.GetOperationTimeout())
+}
+
+// waitForOperation waits for the operation to finish while showing a spinner. The end statement is only written if
+// the operation succeeded.
+func waitForOperation(ctx context.Context, op *compute.Operation, logWriter io.Writer, startStatement, endStatement string) error {
+	spinnerCtx, cancelSpinner := context.WithCancel(ctx)
+	defer cancelSpinner()
+
+	spinner := logwriters.ShowSpinner(spinnerCtx, logWriter, startStatement, endStatement)
+	err := op.Wait(ctx)
+	if err != nil {
+		// Canceling the context clears the spinner without the end statement
+		cancelSpinner()
+		return err
+	}
+	close(spinner)
+
+	return nil
+}
+
+// wrapOperationError reports an expired operation deadline as a timeout instead of
+// the context error returned by the GCP client.
+func wrapOperationError(ctx context.Context, operation string, opts *types.TargetOptions, err error) error {
+	if err == nil {
+		return nil
+	}
+
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return fmt.Errorf("timeout: %s did not complete within %s: %w", operation, opts.GetOperationTimeout(), err)
+	}
+
+	return err
+}
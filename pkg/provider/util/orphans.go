@@ -0,0 +1,375 @@
+package util
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/compute/apiv1/computepb"
+	"github.com/daytonaio/daytona-provider-gcp/pkg/types"
+	"google.golang.org/api/iterator"
+)
+
+const (
+	OrphanKindInstance = "instance"
+	OrphanKindDisk     = "disk"
+	OrphanKindFirewall = "firewall rule"
+)
+
+// OrphanOptions configures CollectOrphans.
+type OrphanOptions struct {
+	// KnownWorkspaceIds are the workspaces the Daytona server knows about. Resources of any other workspace are orphans.
+	KnownWorkspaceIds []string
+	// MinAge keeps resources younger than this, e.g. of a workspace that is being created and isn't known yet.
+	MinAge time.Duration
+	// DryRun only reports the orphans without deleting them.
+	DryRun bool
+	// DeleteRetainedDataDisks also deletes the data disks kept by Retain Data Disk. They are kept by default, so that a
+	// workspace with the same name can reattach them.
+	DeleteRetainedDataDisks bool
+}
+
+// OrphanedResource is an instance or disk of a workspace that the Daytona server doesn't know about, or a managed
+// firewall rule that no workspace VM uses anymore.
+type OrphanedResource struct {
+	Kind string
+	Name string
+	// Project is the network project for firewall rules, which can differ from the project of the target.
+	Project     string
+	Zone        string
+	WorkspaceId string
+	Created     time.Time
+	Deleted     bool
+	Err         error
+}
+
+// OrphanSummary is the outcome of CollectOrphans.
+type OrphanSummary struct {
+	Orphans []*OrphanedResource
+	// Unlabeled are the resources of unknown workspaces that are only recognized by their daytona-<workspace id> name.
+	// They might not have been created by the provider, so they are reported but never deleted.
+	Unlabeled []*OrphanedResource
+	// TooYoung counts the orphans kept because they are younger than the minimum age.
+	TooYoung int
+	// RetainedDataDisks counts the retained data disks of unknown workspaces that were kept.
+	RetainedDataDisks int
+}
+
+// CollectOrphans finds the instances and unattached disks of workspaces that aren't known in every zone of the project
+// and deletes them unless it is a dry run. With Managed Firewall, the managed firewall rule of the network is deleted
+// too once no workspace VM is attached to the network. Only resources with the managed-by label are deleted. Resources
+// that are only recognized by their daytona-<workspace id> name, e.g. created before the labels, are reported as
+// unlabeled to be checked and deleted manually. The image builder VMs are left to the bake. A summary is written to the
+// log writer. Failed deletions are reported in the summary instead of failing the collection.
+func CollectOrphans(ctx context.Context, clients *ClientManager, opts *types.TargetOptions, orphanOpts OrphanOptions, logWriter io.Writer) (*OrphanSummary, error) {
+	known := map[string]bool{}
+	for _, workspaceId := range orphanOpts.KnownWorkspaceIds {
+		known[toLabelValue(workspaceId)] = true
+	}
+
+	summary := &OrphanSummary{}
+	isOrphan := func(workspaceId, created string) bool {
+		if workspaceId == "" || known[workspaceId] {
+			return false
+		}
+
+		// A resource without a valid creation time might have just been created
+		createdAt, err := time.Parse(time.RFC3339, created)
+		if err != nil || time.Since(createdAt) < orphanOpts.MinAge {
+			summary.TooYoung++
+			return false
+		}
+
+		return true
+	}
+
+	instances, disks, err := listManagedResources(ctx, clients, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	attachedDisks := map[string]bool{}
+	for _, instance := range instances {
+		for _, disk := range instance.GetDisks() {
+			attachedDisks[getDiskKey(disk.GetSource())] = true
+		}
+	}
+
+	for _, instance := range instances {
+		workspaceId := getOrphanWorkspaceId(instance.GetLabels(), instance.GetName())
+		if !isOrphan(workspaceId, instance.GetCreationTimestamp()) {
+			continue
+		}
+
+		created, _ := time.Parse(time.RFC3339, instance.GetCreationTimestamp())
+		summary.add(instance.GetLabels(), &OrphanedResource{
+			Kind:        OrphanKindInstance,
+			Name:        instance.GetName(),
+			Project:     opts.ProjectID,
+			Zone:        path.Base(instance.GetZone()),
+			WorkspaceId: workspaceId,
+			Created:     created,
+		})
+	}
+
+	for _, disk := range disks {
+		if attachedDisks[getDiskKey(disk.GetSelfLink())] {
+			continue
+		}
+
+		workspaceId := getOrphanWorkspaceId(disk.GetLabels(), strings.TrimSuffix(disk.GetName(), "-data"))
+		if !isOrphan(workspaceId, disk.GetCreationTimestamp()) {
+			continue
+		}
+		if isDataDiskName(disk.GetName()) && !orphanOpts.DeleteRetainedDataDisks {
+			summary.RetainedDataDisks++
+			continue
+		}
+
+		created, _ := time.Parse(time.RFC3339, disk.GetCreationTimestamp())
+		summary.add(disk.GetLabels(), &OrphanedResource{
+			Kind:        OrphanKindDisk,
+			Name:        disk.GetName(),
+			Project:     opts.ProjectID,
+			Zone:        path.Base(path.Dir(path.Dir(disk.GetSelfLink()))),
+			WorkspaceId: workspaceId,
+			Created:     created,
+		})
+	}
+
+	if opts.ManagedFirewall {
+		firewall, err := getUnusedFirewallOrphan(ctx, clients, opts, orphanOpts, summary)
+		if err != nil {
+			return nil, err
+		}
+		if firewall != nil {
+			summary.Orphans = append(summary.Orphans, firewall)
+		}
+	}
+
+	for _, orphan := range summary.Orphans {
+		status := "would be deleted (dry run)"
+		if !orphanOpts.DryRun {
+			orphan.Err = deleteOrphan(ctx, clients, opts, orphan)
+			orphan.Deleted = orphan.Err == nil
+			status = "deleted"
+			if orphan.Err != nil {
+				status = "failed to delete: " + orphan.Err.Error()
+			}
+		}
+
+		owner := ""
+		if orphan.WorkspaceId != "" {
+			owner = " of workspace " + orphan.WorkspaceId
+		}
+		logWriter.Write([]byte(fmt.Sprintf("Orphaned %s %s in %s%s, created %s: %s\n",
+			orphan.Kind, orphan.Name, orphan.Zone, owner, orphan.Created.Format(time.RFC3339), status)))
+	}
+	for _, resource := range summary.Unlabeled {
+		logWriter.Write([]byte(fmt.Sprintf("Unlabeled %s %s in %s of workspace %s, created %s: not deleted, check and delete it manually\n",
+			resource.Kind, resource.Name, resource.Zone, resource.WorkspaceId, resource.Created.Format(time.RFC3339))))
+	}
+	logWriter.Write([]byte(summary.String() + "\n"))
+
+	return summary, nil
+}
+
+// getUnusedFirewallOrphan returns the managed firewall rule of the network of the target as an orphan if no workspace
+// VM is attached to the network. A rule younger than the minimum age is kept, because a workspace that is being created
+// may be about to attach its VM.
+func getUnusedFirewallOrphan(ctx context.Context, clients *ClientManager, opts *types.TargetOptions, orphanOpts OrphanOptions, summary *OrphanSummary) (*OrphanedResource, error) {
+	ctx, cancel := withOperationTimeout(ctx, opts)
+	defer cancel()
+
+	firewall, project, err := getUnusedManagedFirewall(ctx, clients, opts)
+	if err != nil {
+		return nil, wrapOperationError(ctx, "finding the unused managed firewall rule", opts, err)
+	}
+	if firewall == nil {
+		return nil, nil
+	}
+
+	created, err := time.Parse(time.RFC3339, firewall.GetCreationTimestamp())
+	if err != nil || time.Since(created) < orphanOpts.MinAge {
+		summary.TooYoung++
+		return nil, nil
+	}
+
+	return &OrphanedResource{
+		Kind:    OrphanKindFirewall,
+		Name:    firewall.GetName(),
+		Project: project,
+		Zone:    "global",
+		Created: created,
+	}, nil
+}
+
+// add adds an orphaned resource, as unlabeled if it doesn't have the managed-by label.
+func (s *OrphanSummary) add(labels map[string]string, orphan *OrphanedResource) {
+	if labels[managedByLabel] != managedByValue {
+		s.Unlabeled = append(s.Unlabeled, orphan)
+		return
+	}
+
+	s.Orphans = append(s.Orphans, orphan)
+}
+
+func (s *OrphanSummary) String() string {
+	deleted, failed := 0, 0
+	for _, orphan := range s.Orphans {
+		if orphan.Deleted {
+			deleted++
+		} else if orphan.Err != nil {
+			failed++
+		}
+	}
+
+	return fmt.Sprintf("Found %d orphaned resources: %d deleted, %d failed, %d kept because they are too young, %d retained data disks kept, %d unlabeled resources reported",
+		len(s.Orphans), deleted, failed, s.TooYoung, s.RetainedDataDisks, len(s.Unlabeled))
+}
+
+// listManagedResources lists the instances and disks of the project in every zone that are labeled or named like the
+// resources created by the provider.
+func listManagedResources(ctx context.Context, clients *ClientManager, opts *types.TargetOptions) ([]*computepb.Instance, []*computepb.Disk, error) {
+	instancesClient, err := clients.InstancesClient(opts)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	disksClient, err := clients.DisksClient(opts)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	ctx, cancel := withOperationTimeout(ctx, opts)
+	defer cancel()
+
+	instances := []*computepb.Instance{}
+	instanceIt := instancesClient.AggregatedList(ctx, &computepb.AggregatedListInstancesRequest{
+		Project: opts.ProjectID,
+	})
+	for {
+		pair, err := instanceIt.Next()
+		if errors.Is(err, iterator.Done) {
+			break
+		}
+		if err != nil {
+			return nil, nil, wrapOperationError(ctx, "listing the compute instances", opts, err)
+		}
+
+		for _, instance := range pair.Value.GetInstances() {
+			if isManagedResource(instance.GetLabels(), instance.GetName()) {
+				instances = append(instances, instance)
+			}
+		}
+	}
+
+	disks := []*computepb.Disk{}
+	diskIt := disksClient.AggregatedList(ctx, &computepb.AggregatedListDisksRequest{
+		Project: opts.ProjectID,
+	})
+	for {
+		pair, err := diskIt.Next()
+		if errors.Is(err, iterator.Done) {
+			break
+		}
+		if err != nil {
+			return nil, nil, wrapOperationError(ctx, "listing the disks", opts, err)
+		}
+
+		for _, disk := range pair.Value.GetDisks() {
+			if isManagedResource(disk.GetLabels(), disk.GetName()) {
+				disks = append(disks, disk)
+			}
+		}
+	}
+
+	return instances, disks, nil
+}
+
+func isManagedResource(labels map[string]string, name string) bool {
+	if strings.HasPrefix(name, getResourceName(getImageBuilderId(""))) {
+		return false
+	}
+
+	return labels[managedByLabel] == managedByValue || strings.HasPrefix(name, getResourceName(""))
+}
+
+// getOrphanWorkspaceId returns the workspace of a managed resource, from the workspace ID label or the daytona-<id> name.
+func getOrphanWorkspaceId(labels map[string]string, name string) string {
+	if workspaceId, ok := labels[workspaceIdLabel]; ok {
+		return workspaceId
+	}
+
+	return strings.TrimPrefix(name, getResourceName(""))
+}
+
+// getDiskKey identifies a disk by the zones/<zone>/disks/<disk> end of its URL, which is the same in every URL format.
+func getDiskKey(diskUrl string) string {
+	parts := strings.Split(diskUrl, "/")
+	if len(parts) < 4 {
+		return diskUrl
+	}
+
+	return strings.Join(parts[len(parts)-4:], "/")
+}
+
+func deleteOrphan(ctx context.Context, clients *ClientManager, opts *types.TargetOptions, orphan *OrphanedResource) error {
+	ctx, cancel := withOperationTimeout(ctx, opts)
+	defer cancel()
+
+	if orphan.Kind == OrphanKindFirewall {
+		client, err := clients.FirewallsClient(opts)
+		if err != nil {
+			return err
+		}
+
+		op, err := client.Delete(ctx, &computepb.DeleteFirewallRequest{
+			Project:  orphan.Project,
+			Firewall: orphan.Name,
+		})
+		if err == nil {
+			err = op.Wait(ctx)
+		}
+
+		return wrapOperationError(ctx, "deleting the unused firewall rule", opts, err)
+	}
+
+	if orphan.Kind == OrphanKindInstance {
+		client, err := clients.InstancesClient(opts)
+		if err != nil {
+			return err
+		}
+
+		op, err := client.Delete(ctx, &computepb.DeleteInstanceRequest{
+			Project:  orphan.Project,
+			Zone:     orphan.Zone,
+			Instance: orphan.Name,
+		})
+		if err == nil {
+			err = op.Wait(ctx)
+		}
+
+		return wrapOperationError(ctx, "deleting the orphaned instance", opts, err)
+	}
+
+	client, err := clients.DisksClient(opts)
+	if err != nil {
+		return err
+	}
+
+	op, err := client.Delete(ctx, &computepb.DeleteDiskRequest{
+		Project: orphan.Project,
+		Zone:    orphan.Zone,
+		Disk:    orphan.Name,
+	})
+	if err == nil {
+		err = op.Wait(ctx)
+	}
+
+	return wrapOperationError(ctx, "deleting the orphaned disk", opts, err)
+}
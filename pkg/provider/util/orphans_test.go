@@ -0,0 +1,150 @@
+package util
+
+import (
+	"bytes"
+	"context"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/compute/apiv1/computepb"
+	"github.com/daytonaio/daytona-provider-gcp/internal/fakecompute"
+	"github.com/daytonaio/daytona-provider-gcp/pkg/types"
+	"google.golang.org/protobuf/proto"
+)
+
+func newOrphanServer() *fakecompute.Server {
+	server := fakecompute.NewServer()
+
+	old := proto.String(time.Now().Add(-48 * time.Hour).Format(time.RFC3339))
+	managed := func(workspaceId string) map[string]string {
+		return map[string]string{managedByLabel: managedByValue, workspaceIdLabel: workspaceId}
+	}
+
+	server.AddDisk("project", "us-central1-a", &computepb.Disk{Name: proto.String("daytona-lost"), CreationTimestamp: old, Labels: managed("lost")})
+	server.AddDisk("project", "us-central1-a", &computepb.Disk{Name: proto.String("daytona-gone"), CreationTimestamp: old, Labels: managed("gone")})
+	server.AddDisk("project", "us-central1-a", &computepb.Disk{Name: proto.String("daytona-gone-data"), CreationTimestamp: old, Labels: managed("gone")})
+	server.AddDisk("project", "us-central1-a", &computepb.Disk{Name: proto.String("daytona-known-data"), CreationTimestamp: old, Labels: managed("known")})
+
+	server.SetInstance("project", "us-central1-a", &computepb.Instance{Name: proto.String("daytona-known"), CreationTimestamp: old, Labels: managed("known")})
+	server.SetInstance("project", "us-central1-a", &computepb.Instance{
+		Name:              proto.String("daytona-lost"),
+		CreationTimestamp: old,
+		Labels:            managed("lost"),
+		Disks: []*computepb.AttachedDisk{
+			{Boot: proto.Bool(true), AutoDelete: proto.Bool(true), Source: proto.String("projects/project/zones/us-central1-a/disks/daytona-lost")},
+		},
+	})
+	// Instances created before the labels are only recognized by their name
+	server.SetInstance("project", "us-east1-b", &computepb.Instance{Name: proto.String("daytona-legacy"), CreationTimestamp: old})
+	server.SetInstance("project", "us-central1-a", &computepb.Instance{
+		Name:              proto.String("daytona-creating"),
+		CreationTimestamp: proto.String(time.Now().Format(time.RFC3339)),
+		Labels:            managed("creating"),
+	})
+	server.SetInstance("project", "us-central1-a", &computepb.Instance{Name: proto.String("daytona-image-builder-workspaces"), CreationTimestamp: old})
+	server.SetInstance("project", "us-central1-a", &computepb.Instance{Name: proto.String("database"), CreationTimestamp: old})
+
+	return server
+}
+
+func getOrphanNames(summary *OrphanSummary) []string {
+	names := []string{}
+	for _, orphan := range summary.Orphans {
+		names = append(names, orphan.Kind+" "+orphan.Zone+"/"+orphan.Name)
+	}
+	slices.Sort(names)
+
+	return names
+}
+
+func TestCollectOrphans(t *testing.T) {
+	server := newOrphanServer()
+	defer server.Close()
+
+	clients := NewClientManager(server.ClientOptions()...)
+	defer clients.Close()
+
+	ctx := context.Background()
+	opts := &types.TargetOptions{
+		AuthMode:  types.AuthModeApplicationDefault,
+		ProjectID: "project",
+		Zone:      "us-central1-a",
+	}
+	orphanOpts := OrphanOptions{
+		KnownWorkspaceIds: []string{"known"},
+		MinAge:            time.Hour,
+		DryRun:            true,
+	}
+	wantOrphans := []string{
+		"disk us-central1-a/daytona-gone",
+		"instance us-central1-a/daytona-lost",
+	}
+
+	logWriter := &bytes.Buffer{}
+	summary, err := CollectOrphans(ctx, clients, opts, orphanOpts, logWriter)
+	if err != nil {
+		t.Fatalf("Error collecting orphans: %s", err)
+	}
+	if names := getOrphanNames(summary); !reflect.DeepEqual(names, wantOrphans) {
+		t.Errorf("Expected orphans %v, got %v", wantOrphans, names)
+	}
+	if summary.TooYoung != 1 || summary.RetainedDataDisks != 1 {
+		t.Errorf("Expected 1 young orphan and 1 retained data disk, got %d and %d", summary.TooYoung, summary.RetainedDataDisks)
+	}
+	if len(summary.Unlabeled) != 1 || summary.Unlabeled[0].Name != "daytona-legacy" {
+		t.Errorf("Expected the unlabeled instance daytona-legacy to be reported, got %v", summary.Unlabeled)
+	}
+	if server.GetInstance("project", "us-central1-a", "daytona-lost") == nil || server.GetDisk("project", "us-central1-a", "daytona-gone") == nil {
+		t.Errorf("Expected a dry run to delete nothing")
+	}
+	if !strings.Contains(logWriter.String(), "daytona-lost in us-central1-a of workspace lost") || !strings.Contains(logWriter.String(), "Found 2 orphaned resources: 0 deleted") {
+		t.Errorf("Expected a summary of the dry run, got:\n%s", logWriter.String())
+	}
+	if !strings.Contains(logWriter.String(), "Unlabeled instance daytona-legacy in us-east1-b of workspace legacy") {
+		t.Errorf("Expected the unlabeled instance to be reported, got:\n%s", logWriter.String())
+	}
+
+	orphanOpts.DryRun = false
+	summary, err = CollectOrphans(ctx, clients, opts, orphanOpts, &bytes.Buffer{})
+	if err != nil {
+		t.Fatalf("Error collecting orphans: %s", err)
+	}
+	for _, orphan := range summary.Orphans {
+		if !orphan.Deleted {
+			t.Errorf("Expected orphaned %s %s to be deleted: %v", orphan.Kind, orphan.Name, orphan.Err)
+		}
+	}
+	for _, name := range []string{"daytona-lost", "daytona-gone"} {
+		if server.GetDisk("project", "us-central1-a", name) != nil {
+			t.Errorf("Expected disk %s to be deleted", name)
+		}
+	}
+	if server.GetInstance("project", "us-east1-b", "daytona-legacy") == nil {
+		t.Errorf("Expected the unlabeled instance daytona-legacy to be kept")
+	}
+	for _, name := range []string{"daytona-known", "daytona-creating", "daytona-image-builder-workspaces", "database"} {
+		if server.GetInstance("project", "us-central1-a", name) == nil {
+			t.Errorf("Expected instance %s to be kept", name)
+		}
+	}
+	for _, name := range []string{"daytona-gone-data", "daytona-known-data"} {
+		if server.GetDisk("project", "us-central1-a", name) == nil {
+			t.Errorf("Expected disk %s to be kept", name)
+		}
+	}
+
+	orphanOpts.DeleteRetainedDataDisks = true
+	summary, err = CollectOrphans(ctx, clients, opts, orphanOpts, &bytes.Buffer{})
+	if err != nil {
+		t.Fatalf("Error collecting orphans: %s", err)
+	}
+	if names := getOrphanNames(summary); !reflect.DeepEqual(names, []string{"disk us-central1-a/daytona-gone-data"}) {
+		t.Errorf("Expected only the retained data disk of the unknown workspace, got %v", names)
+	}
+	if server.GetDisk("project", "us-central1-a", "daytona-known-data") == nil {
+		t.Errorf("Expected the data disk of a known workspace to be kept")
+	}
+}
@@ -0,0 +1,191 @@
+package util
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"path"
+	"regexp"
+	"strings"
+	"time"
+	// The time zones are checked against the embedded tz database, which doesn't depend on the host
+	_ "time/tzdata"
+
+	"cloud.google.com/go/compute/apiv1/computepb"
+	"github.com/daytonaio/daytona-provider-gcp/pkg/types"
+)
+
+// cronFieldPattern matches a field of a unix-cron schedule, e.g. *, */15, 8, 1-5, MON-FRI or 1,3,5.
+var cronFieldPattern = regexp.MustCompile(`^(\*|[0-9A-Za-z]+(-[0-9A-Za-z]+)?)(/[0-9]+)?(,(\*|[0-9A-Za-z]+(-[0-9A-Za-z]+)?)(/[0-9]+)?)*$`)
+
+// getSchedulePolicyName returns the name of the instance schedule of a workspace. Instance schedules are regional
+// resources, so every workspace gets its own.
+func getSchedulePolicyName(workspaceId string) string {
+	return getResourceName(workspaceId) + "-schedule"
+}
+
+// getInstanceSchedulePolicy returns the instance schedule resource policy of the start and stop schedules.
+func getInstanceSchedulePolicy(opts *types.TargetOptions) *computepb.ResourcePolicyInstanceSchedulePolicy {
+	policy := &computepb.ResourcePolicyInstanceSchedulePolicy{
+		TimeZone: toPtr(opts.GetTimeZone()),
+	}
+	if opts.StartSchedule != "" {
+		policy.VmStartSchedule = &computepb.ResourcePolicyInstanceSchedulePolicySchedule{
+			Schedule: toPtr(opts.StartSchedule),
+		}
+	}
+	if opts.StopSchedule != "" {
+		policy.VmStopSchedule = &computepb.ResourcePolicyInstanceSchedulePolicySchedule{
+			Schedule: toPtr(opts.StopSchedule),
+		}
+	}
+
+	return policy
+}
+
+// createSchedulePolicy creates the instance schedule of a workspace and returns its URL, which is attached to the
+// instance. A schedule left behind by an instance of the workspace that no longer exists is replaced.
+func createSchedulePolicy(ctx context.Context, clients *ClientManager, workspaceId string, opts *types.TargetOptions, logWriter io.Writer) (string, error) {
+	client, err := clients.ResourcePoliciesClient(opts)
+	if err != nil {
+		return "", err
+	}
+
+	name := getSchedulePolicyName(workspaceId)
+	policy := &computepb.ResourcePolicy{
+		Name:                   toPtr(name),
+		Description:            toPtr("Managed by Daytona. Start and stop schedule of workspace " + workspaceId + "."),
+		InstanceSchedulePolicy: getInstanceSchedulePolicy(opts),
+	}
+
+	op, err := client.Insert(ctx, &computepb.InsertResourcePolicyRequest{
+		Project:                opts.ProjectID,
+		Region:                 getRegion(opts.Zone),
+		ResourcePolicyResource: policy,
+	})
+	if isConflict(err) {
+		err = deleteSchedulePolicy(ctx, clients, workspaceId, opts, logWriter)
+		if err != nil {
+			return "", err
+		}
+
+		op, err = client.Insert(ctx, &computepb.InsertResourcePolicyRequest{
+			Project:                opts.ProjectID,
+			Region:                 getRegion(opts.Zone),
+			ResourcePolicyResource: policy,
+		})
+	}
+	if err != nil {
+		return "", err
+	}
+
+	err = waitForOperation(ctx, op, logWriter, "Creating GCP instance schedule "+name, "GCP instance schedule "+name+" created")
+	if err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("projects/%s/regions/%s/resourcePolicies/%s", opts.ProjectID, getRegion(opts.Zone), name), nil
+}
+
+// deleteSchedulePolicy deletes the instance schedule of a workspace. It can only be deleted once the instance is gone.
+func deleteSchedulePolicy(ctx context.Context, clients *ClientManager, workspaceId string, opts *types.TargetOptions, logWriter io.Writer) error {
+	client, err := clients.ResourcePoliciesClient(opts)
+	if err != nil {
+		return err
+	}
+
+	name := getSchedulePolicyName(workspaceId)
+	op, err := client.Delete(ctx, &computepb.DeleteResourcePolicyRequest{
+		Project:        opts.ProjectID,
+		Region:         getRegion(opts.Zone),
+		ResourcePolicy: name,
+	})
+	if isNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	return waitForOperation(ctx, op, logWriter, "Deleting GCP instance schedule "+name, "GCP instance schedule "+name+" deleted")
+}
+
+// getInstanceSchedule returns the schedules of the instance schedule attached to the instance.
+func getInstanceSchedule(ctx context.Context, clients *ClientManager, opts *types.TargetOptions, instance *computepb.Instance) (types.InstanceSchedule, error) {
+	schedule := types.InstanceSchedule{}
+
+	for _, policyUrl := range instance.GetResourcePolicies() {
+		if !strings.HasSuffix(path.Base(policyUrl), "-schedule") {
+			continue
+		}
+
+		client, err := clients.ResourcePoliciesClient(opts)
+		if err != nil {
+			return schedule, err
+		}
+
+		policy, err := client.Get(ctx, &computepb.GetResourcePolicyRequest{
+			Project:        opts.ProjectID,
+			Region:         path.Base(path.Dir(path.Dir(policyUrl))),
+			ResourcePolicy: path.Base(policyUrl),
+		})
+		if isNotFound(err) {
+			continue
+		}
+		if err != nil {
+			return schedule, err
+		}
+
+		instanceSchedule := policy.GetInstanceSchedulePolicy()
+		if instanceSchedule == nil {
+			continue
+		}
+
+		schedule.Start = instanceSchedule.GetVmStartSchedule().GetSchedule()
+		schedule.Stop = instanceSchedule.GetVmStopSchedule().GetSchedule()
+		schedule.TimeZone = instanceSchedule.GetTimeZone()
+		return schedule, nil
+	}
+
+	return schedule, nil
+}
+
+func validateSchedule(opts *types.TargetOptions, validationErr *ValidationError) {
+	for _, schedule := range []struct {
+		field string
+		value string
+	}{
+		{field: "Start Schedule", value: opts.StartSchedule},
+		{field: "Stop Schedule", value: opts.StopSchedule},
+	} {
+		if schedule.value != "" && !isValidCronSchedule(schedule.value) {
+			validationErr.add(schedule.field, "%q is not a cron schedule of minute, hour, day of month, month and day of week", schedule.value)
+		}
+	}
+
+	if opts.TimeZone != "" {
+		_, err := time.LoadLocation(opts.TimeZone)
+		if err != nil || opts.TimeZone == "Local" {
+			validationErr.add("Time Zone", "unknown time zone %q, e.g. Europe/Berlin", opts.TimeZone)
+		} else if !opts.HasSchedule() && opts.GetTimeZone() != types.DefaultTimeZone {
+			validationErr.add("Time Zone", "a time zone requires a start or stop schedule")
+		}
+	}
+}
+
+// isValidCronSchedule checks that a schedule has the five fields of a unix-cron schedule. The values of the fields are
+// checked by GCP.
+func isValidCronSchedule(schedule string) bool {
+	fields := strings.Fields(schedule)
+	if len(fields) != 5 {
+		return false
+	}
+
+	for _, field := range fields {
+		if !cronFieldPattern.MatchString(field) {
+			return false
+		}
+	}
+
+	return true
+}
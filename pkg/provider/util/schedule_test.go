@@ -0,0 +1,124 @@
+package util
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/daytonaio/daytona-provider-gcp/internal/fakecompute"
+	"github.com/daytonaio/daytona-provider-gcp/pkg/types"
+	"github.com/daytonaio/daytona/pkg/workspace"
+)
+
+func TestScheduledWorkspace(t *testing.T) {
+	server := fakecompute.NewServer()
+	defer server.Close()
+
+	clients := NewClientManager(server.ClientOptions()...)
+	defer clients.Close()
+
+	ctx := context.Background()
+	logWriter := &bytes.Buffer{}
+	opts := &types.TargetOptions{
+		AuthMode:      types.AuthModeApplicationDefault,
+		ProjectID:     "project",
+		Zone:          "us-central1-a",
+		StartSchedule: "0 8 * * 1-5",
+		StopSchedule:  "0 20 * * 1-5",
+		TimeZone:      "Europe/Berlin",
+	}
+	ws := &workspace.Workspace{Id: "123", EnvVars: map[string]string{}}
+
+	tx := NewTransaction()
+	err := CreateWorkspace(ctx, clients, ws, opts, "", tx, logWriter)
+	if err != nil {
+		t.Fatalf("Error creating workspace: %s", err)
+	}
+
+	policy := server.GetResourcePolicy("project", "us-central1", "daytona-123-schedule")
+	if policy == nil {
+		t.Fatalf("Expected the instance schedule to be created")
+	}
+	schedule := policy.GetInstanceSchedulePolicy()
+	if schedule.GetVmStartSchedule().GetSchedule() != "0 8 * * 1-5" || schedule.GetVmStopSchedule().GetSchedule() != "0 20 * * 1-5" || schedule.GetTimeZone() != "Europe/Berlin" {
+		t.Errorf("Expected the start and stop schedules in Europe/Berlin, got %v", schedule)
+	}
+
+	instance := server.GetInstance("project", "us-central1-a", "daytona-123")
+	if len(instance.GetResourcePolicies()) != 1 || instance.GetResourcePolicies()[0] != policy.GetSelfLink() {
+		t.Errorf("Expected the instance schedule to be attached, got %v", instance.GetResourcePolicies())
+	}
+	if resources := tx.Resources(); len(resources) != 2 || resources[0] != "instance schedule daytona-123-schedule" {
+		t.Errorf("Expected the instance schedule to be recorded before the instance, got %v", resources)
+	}
+
+	metadata, err := GetWorkspaceMetadata(ctx, clients, ws, opts)
+	if err != nil {
+		t.Fatalf("Error getting the workspace metadata: %s", err)
+	}
+	want := types.InstanceSchedule{Start: "0 8 * * 1-5", Stop: "0 20 * * 1-5", TimeZone: "Europe/Berlin"}
+	if metadata.Schedule != want {
+		t.Errorf("Expected schedule %+v in the metadata, got %+v", want, metadata.Schedule)
+	}
+
+	err = DeleteWorkspace(ctx, clients, ws, opts, logWriter)
+	if err != nil {
+		t.Fatalf("Error deleting workspace: %s", err)
+	}
+	if server.GetResourcePolicy("project", "us-central1", "daytona-123-schedule") != nil {
+		t.Errorf("Expected the instance schedule to be deleted with the workspace")
+	}
+}
+
+func TestScheduledWorkspaceReplacesLeftoverSchedule(t *testing.T) {
+	server := fakecompute.NewServer()
+	defer server.Close()
+
+	clients := NewClientManager(server.ClientOptions()...)
+	defer clients.Close()
+
+	ctx := context.Background()
+	opts := &types.TargetOptions{
+		AuthMode:     types.AuthModeApplicationDefault,
+		ProjectID:    "project",
+		Zone:         "us-central1-a",
+		StopSchedule: "0 20 * * *",
+	}
+
+	// A schedule left behind by an earlier instance of the workspace, e.g. a spot VM deleted on preemption
+	_, err := createSchedulePolicy(ctx, clients, "123", &types.TargetOptions{ProjectID: "project", Zone: "us-central1-a", StopSchedule: "0 18 * * *"}, &bytes.Buffer{})
+	if err != nil {
+		t.Fatalf("Error creating the instance schedule: %s", err)
+	}
+
+	err = CreateWorkspace(ctx, clients, &workspace.Workspace{Id: "123", EnvVars: map[string]string{}}, opts, "", nil, &bytes.Buffer{})
+	if err != nil {
+		t.Fatalf("Error creating workspace: %s", err)
+	}
+
+	schedule := server.GetResourcePolicy("project", "us-central1", "daytona-123-schedule").GetInstanceSchedulePolicy()
+	if schedule.GetVmStopSchedule().GetSchedule() != "0 20 * * *" || schedule.GetVmStartSchedule() != nil || schedule.GetTimeZone() != "UTC" {
+		t.Errorf("Expected the leftover schedule to be replaced, got %v", schedule)
+	}
+}
+
+func TestIsValidCronSchedule(t *testing.T) {
+	tests := []struct {
+		schedule string
+		want     bool
+	}{
+		{schedule: "0 8 * * 1-5", want: true},
+		{schedule: "*/15 8-20 * * MON-FRI", want: true},
+		{schedule: "30 7 1,15 * *", want: true},
+		{schedule: "0 8 * *", want: false},
+		{schedule: "0 8 * * 1-5 2024", want: false},
+		{schedule: "@daily", want: false},
+		{schedule: "0 8 ? * 1-5", want: false},
+	}
+
+	for _, tt := range tests {
+		if got := isValidCronSchedule(tt.schedule); got != tt.want {
+			t.Errorf("Expected isValidCronSchedule(%q) to be %t", tt.schedule, tt.want)
+		}
+	}
+}
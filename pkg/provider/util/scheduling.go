@@ -0,0 +1,88 @@
+package util
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/compute/apiv1/computepb"
+	"github.com/daytonaio/daytona-provider-gcp/pkg/types"
+	"google.golang.org/api/iterator"
+)
+
+// preemptedOperationType is the type of the operation GCP records when it preempts a spot or preemptible VM.
+const preemptedOperationType = "compute.instances.preempted"
+
+// getScheduling returns the scheduling of the VM, nil for standard VMs without GPUs that aren't Confidential VMs.
+// Spot and preemptible VMs, VMs with GPUs and Confidential VMs can't be live migrated, so they are terminated on host maintenance.
+func getScheduling(opts *types.TargetOptions) *computepb.Scheduling {
+	switch opts.GetProvisioningModel() {
+	case types.ProvisioningModelSpot:
+		return &computepb.Scheduling{
+			ProvisioningModel:         toPtr(computepb.Scheduling_SPOT.String()),
+			InstanceTerminationAction: toPtr(opts.GetTerminationAction()),
+			AutomaticRestart:          toPtr(false),
+			OnHostMaintenance:         toPtr(computepb.Scheduling_TERMINATE.String()),
+		}
+	case types.ProvisioningModelPreemptible:
+		return &computepb.Scheduling{
+			Preemptible:       toPtr(true),
+			AutomaticRestart:  toPtr(false),
+			OnHostMaintenance: toPtr(computepb.Scheduling_TERMINATE.String()),
+		}
+	}
+
+	if hasGPUs(opts) || opts.ConfidentialVM {
+		return &computepb.Scheduling{
+			AutomaticRestart:  toPtr(true),
+			OnHostMaintenance: toPtr(computepb.Scheduling_TERMINATE.String()),
+		}
+	}
+
+	return nil
+}
+
+// wasPreempted checks whether GCP preempted the instance after it was last started.
+// An empty lastStart matches any preemption, e.g. of an instance that was deleted on preemption.
+func wasPreempted(ctx context.Context, clients *ClientManager, opts *types.TargetOptions, instanceName string, lastStart string) (bool, error) {
+	client, err := clients.ZoneOperationsClient(opts)
+	if err != nil {
+		return false, err
+	}
+
+	var since time.Time
+	if lastStart != "" {
+		since, err = time.Parse(time.RFC3339, lastStart)
+		if err != nil {
+			return false, err
+		}
+	}
+
+	it := client.List(ctx, &computepb.ListZoneOperationsRequest{
+		Project: opts.ProjectID,
+		Zone:    opts.Zone,
+		Filter:  toPtr(`operationType = "` + preemptedOperationType + `"`),
+	})
+	for {
+		operation, err := it.Next()
+		if errors.Is(err, iterator.Done) {
+			return false, nil
+		}
+		if err != nil {
+			return false, err
+		}
+
+		if operation.GetOperationType() != preemptedOperationType || !strings.HasSuffix(operation.GetTargetLink(), "/instances/"+instanceName) {
+			continue
+		}
+
+		preemptedAt, err := time.Parse(time.RFC3339, operation.GetInsertTime())
+		if err != nil {
+			return false, err
+		}
+		if !preemptedAt.Before(since) {
+			return true, nil
+		}
+	}
+}
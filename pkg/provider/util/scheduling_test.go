@@ -0,0 +1,115 @@
+package util
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"cloud.google.com/go/compute/apiv1/computepb"
+	"github.com/daytonaio/daytona-provider-gcp/internal/fakecompute"
+	"github.com/daytonaio/daytona-provider-gcp/pkg/types"
+	"github.com/daytonaio/daytona/pkg/workspace"
+)
+
+func TestPreemptionRecovery(t *testing.T) {
+	tests := []struct {
+		name              string
+		provisioningModel string
+		terminationAction string
+		wantStartErr      bool
+	}{
+		{
+			name:              "Spot instance stopped on preemption",
+			provisioningModel: types.ProvisioningModelSpot,
+			terminationAction: types.TerminationActionStop,
+		},
+		{
+			name:              "Spot instance deleted on preemption",
+			provisioningModel: types.ProvisioningModelSpot,
+			terminationAction: types.TerminationActionDelete,
+			wantStartErr:      true,
+		},
+		{
+			name:              "Preemptible instance",
+			provisioningModel: types.ProvisioningModelPreemptible,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := fakecompute.NewServer()
+			defer server.Close()
+
+			clients := NewClientManager(server.ClientOptions()...)
+			defer clients.Close()
+
+			ctx := context.Background()
+			logWriter := &bytes.Buffer{}
+			opts := &types.TargetOptions{
+				AuthMode:          types.AuthModeApplicationDefault,
+				ProjectID:         "project",
+				Zone:              "us-central1-a",
+				MachineType:       "n1-standard-1",
+				DiskType:          "pd-standard",
+				DiskSize:          20,
+				VMImage:           "projects/ubuntu-os-cloud/global/images/family/ubuntu-2204-lts",
+				ProvisioningModel: tt.provisioningModel,
+				TerminationAction: tt.terminationAction,
+			}
+			ws := &workspace.Workspace{Id: "123", EnvVars: map[string]string{}}
+
+			err := CreateWorkspace(ctx, clients, ws, opts, "echo init", nil, logWriter)
+			if err != nil {
+				t.Fatalf("Error creating workspace: %s", err)
+			}
+
+			metadata, err := GetWorkspaceMetadata(ctx, clients, ws, opts)
+			if err != nil {
+				t.Fatalf("Error getting workspace metadata: %s", err)
+			}
+			if metadata.ProvisioningModel != tt.provisioningModel || metadata.Preempted {
+				t.Errorf("Expected a running %s instance, got %+v", tt.provisioningModel, metadata)
+			}
+
+			server.Preempt("project", "us-central1-a", "daytona-123", opts.IsDeletedOnPreemption())
+
+			metadata, err = GetWorkspaceMetadata(ctx, clients, ws, opts)
+			if err != nil {
+				t.Fatalf("Error getting workspace metadata: %s", err)
+			}
+			if !metadata.Preempted {
+				t.Errorf("Expected the workspace to be reported as preempted, got %+v", metadata)
+			}
+
+			err = StartWorkspace(ctx, clients, ws, opts, logWriter)
+			if tt.wantStartErr {
+				// The projects were lost with the boot disk, so only recreating the workspace restores it
+				if err == nil || !strings.Contains(err.Error(), "recreate the workspace") {
+					t.Errorf("Expected an error asking to recreate the workspace, got %v", err)
+				}
+				if server.GetInstance("project", "us-central1-a", "daytona-123") != nil {
+					t.Errorf("Expected the deleted instance not to be recreated")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Error starting workspace: %s", err)
+			}
+
+			instance := server.GetInstance("project", "us-central1-a", "daytona-123")
+			if instance.GetStatus() != computepb.Instance_RUNNING.String() {
+				t.Errorf("Expected instance status RUNNING, got %s", instance.GetStatus())
+			}
+			if instance.GetScheduling().GetAutomaticRestart() || instance.GetScheduling().GetOnHostMaintenance() != computepb.Scheduling_TERMINATE.String() {
+				t.Errorf("Expected a scheduling without automatic restart or live migration, got %v", instance.GetScheduling())
+			}
+		})
+	}
+}
+
+func TestGetSchedulingStandard(t *testing.T) {
+	if scheduling := getScheduling(&types.TargetOptions{}); scheduling != nil {
+		t.Errorf("Expected no scheduling for standard instances, got %v", scheduling)
+	}
+}
@@ -0,0 +1,61 @@
+package util
+
+import (
+	"context"
+	"strings"
+
+	"cloud.google.com/go/compute/apiv1/computepb"
+	"github.com/daytonaio/daytona-provider-gcp/pkg/startupscript"
+	"github.com/daytonaio/daytona-provider-gcp/pkg/types"
+	"github.com/daytonaio/daytona/pkg/workspace"
+)
+
+// RemoveWorkspaceSecrets deletes the secret metadata items of the workspace compute instance. The startup script keeps
+// them on the VM after the first boot, so once the agent connected they only leak to anyone who can read the metadata.
+func RemoveWorkspaceSecrets(ctx context.Context, clients *ClientManager, workspace *workspace.Workspace, opts *types.TargetOptions) error {
+	client, err := clients.InstancesClient(opts)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := withOperationTimeout(ctx, opts)
+	defer cancel()
+
+	instanceName := getResourceName(workspace.Id)
+	instance, err := client.Get(ctx, &computepb.GetInstanceRequest{
+		Project:  opts.ProjectID,
+		Zone:     opts.Zone,
+		Instance: instanceName,
+	})
+	if err != nil {
+		return wrapOperationError(ctx, "getting the compute instance", opts, err)
+	}
+
+	metadata := instance.GetMetadata()
+	items := []*computepb.Items{}
+	for _, item := range metadata.GetItems() {
+		if !strings.HasPrefix(item.GetKey(), startupscript.SecretsMetadataPrefix) {
+			items = append(items, item)
+		}
+	}
+	if len(items) == len(metadata.GetItems()) {
+		return nil
+	}
+
+	// The fingerprint makes GCP reject the update if the metadata changed since it was read
+	operation, err := client.SetMetadata(ctx, &computepb.SetMetadataInstanceRequest{
+		Project:  opts.ProjectID,
+		Zone:     opts.Zone,
+		Instance: instanceName,
+		MetadataResource: &computepb.Metadata{
+			Fingerprint: metadata.Fingerprint,
+			Items:       items,
+		},
+	})
+	if err != nil {
+		return wrapOperationError(ctx, "removing the workspace secrets", opts, err)
+	}
+
+	err = operation.Wait(ctx)
+	return wrapOperationError(ctx, "removing the workspace secrets", opts, err)
+}
@@ -0,0 +1,70 @@
+package util
+
+import (
+	"slices"
+	"strings"
+
+	"cloud.google.com/go/compute/apiv1/computepb"
+	"github.com/daytonaio/daytona-provider-gcp/pkg/types"
+)
+
+// confidentialMachineFamilies are the machine families that support Confidential VMs with AMD SEV.
+var confidentialMachineFamilies = []string{"n2d", "c2d"}
+
+// getShieldedInstanceConfig returns the Shielded VM features of the VM, nil if none is enabled.
+// GCP enables vTPM and integrity monitoring by default, so every feature is set explicitly.
+func getShieldedInstanceConfig(opts *types.TargetOptions) *computepb.ShieldedInstanceConfig {
+	if !opts.IsShieldedVM() {
+		return nil
+	}
+
+	return &computepb.ShieldedInstanceConfig{
+		EnableSecureBoot:          toPtr(opts.SecureBoot),
+		EnableVtpm:                toPtr(opts.VTPM),
+		EnableIntegrityMonitoring: toPtr(opts.IntegrityMonitoring),
+	}
+}
+
+// getConfidentialInstanceConfig returns the Confidential VM config of the VM, nil for a regular VM.
+func getConfidentialInstanceConfig(opts *types.TargetOptions) *computepb.ConfidentialInstanceConfig {
+	if !opts.ConfidentialVM {
+		return nil
+	}
+
+	return &computepb.ConfidentialInstanceConfig{
+		EnableConfidentialCompute: toPtr(true),
+	}
+}
+
+// validateSecurity checks the Shielded VM and Confidential VM options against each other and the machine type.
+func validateSecurity(opts *types.TargetOptions, validationErr *ValidationError) {
+	if opts.IntegrityMonitoring && !opts.VTPM {
+		validationErr.add("Integrity Monitoring", "integrity monitoring requires vTPM")
+	}
+
+	// The drivers are built as unsigned DKMS modules, which the kernel refuses to load with Secure Boot
+	if opts.SecureBoot && opts.InstallGPUDrivers {
+		validationErr.add("Secure Boot", "the NVIDIA drivers installed by Install GPU Drivers don't load with Secure Boot")
+	}
+
+	if opts.ConfidentialVM && !slices.Contains(confidentialMachineFamilies, getMachineFamily(opts.MachineType)) {
+		validationErr.add("Confidential VM", "machine type %q doesn't support Confidential VMs, use one of the %s machine families",
+			opts.MachineType, strings.Join(confidentialMachineFamilies, ", "))
+	}
+}
+
+// validateImageSecurity checks that the VM image supports the Shielded VM and Confidential VM options.
+func validateImageSecurity(opts *types.TargetOptions, image *computepb.Image, validationErr *ValidationError) {
+	features := []string{}
+	for _, feature := range image.GetGuestOsFeatures() {
+		features = append(features, feature.GetType())
+	}
+
+	if opts.IsShieldedVM() && !slices.Contains(features, computepb.GuestOsFeature_UEFI_COMPATIBLE.String()) {
+		validationErr.add("VM Image", "image %q doesn't support Shielded VM features, it isn't UEFI compatible", opts.VMImage)
+	}
+
+	if opts.ConfidentialVM && !slices.Contains(features, computepb.GuestOsFeature_SEV_CAPABLE.String()) {
+		validationErr.add("VM Image", "image %q doesn't support Confidential VMs, it isn't SEV capable", opts.VMImage)
+	}
+}
@@ -0,0 +1,69 @@
+package util
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"cloud.google.com/go/compute/apiv1/computepb"
+	"github.com/daytonaio/daytona-provider-gcp/internal/fakecompute"
+	"github.com/daytonaio/daytona-provider-gcp/pkg/types"
+	"github.com/daytonaio/daytona/pkg/workspace"
+)
+
+func TestCreateShieldedConfidentialWorkspace(t *testing.T) {
+	server := fakecompute.NewServer()
+	defer server.Close()
+
+	clients := NewClientManager(server.ClientOptions()...)
+	defer clients.Close()
+
+	ctx := context.Background()
+	opts := &types.TargetOptions{
+		AuthMode:       types.AuthModeApplicationDefault,
+		ProjectID:      "project",
+		Zone:           "us-central1-a",
+		MachineType:    "n2d-standard-2",
+		SecureBoot:     true,
+		VTPM:           true,
+		ConfidentialVM: true,
+	}
+	ws := &workspace.Workspace{Id: "123", EnvVars: map[string]string{}}
+
+	err := CreateWorkspace(ctx, clients, ws, opts, "", nil, &bytes.Buffer{})
+	if err != nil {
+		t.Fatalf("Error creating workspace: %s", err)
+	}
+
+	instance := server.GetInstance("project", "us-central1-a", "daytona-123")
+	shielded := instance.GetShieldedInstanceConfig()
+	if !shielded.GetEnableSecureBoot() || !shielded.GetEnableVtpm() || shielded.EnableIntegrityMonitoring == nil || shielded.GetEnableIntegrityMonitoring() {
+		t.Errorf("Expected Secure Boot and vTPM without integrity monitoring, got %v", shielded)
+	}
+	if !instance.GetConfidentialInstanceConfig().GetEnableConfidentialCompute() {
+		t.Errorf("Expected a Confidential VM")
+	}
+	if instance.GetScheduling().GetOnHostMaintenance() != computepb.Scheduling_TERMINATE.String() {
+		t.Errorf("Expected the Confidential VM to be terminated on host maintenance, got %v", instance.GetScheduling())
+	}
+
+	metadata, err := GetWorkspaceMetadata(ctx, clients, ws, opts)
+	if err != nil {
+		t.Fatalf("Error getting the workspace metadata: %s", err)
+	}
+	want := types.SecurityPosture{SecureBoot: true, VTPM: true, ConfidentialVM: true}
+	if metadata.Security != want {
+		t.Errorf("Expected security posture %+v, got %+v", want, metadata.Security)
+	}
+}
+
+func TestGetSecurityConfigsDisabled(t *testing.T) {
+	opts := &types.TargetOptions{MachineType: "n1-standard-1"}
+
+	if config := getShieldedInstanceConfig(opts); config != nil {
+		t.Errorf("Expected no Shielded VM config, got %v", config)
+	}
+	if config := getConfidentialInstanceConfig(opts); config != nil {
+		t.Errorf("Expected no Confidential VM config, got %v", config)
+	}
+}
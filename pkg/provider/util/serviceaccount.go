@@ -0,0 +1,58 @@
+package util
+
+import (
+	"context"
+	"strings"
+
+	"cloud.google.com/go/compute/apiv1/computepb"
+	"github.com/daytonaio/daytona-provider-gcp/pkg/types"
+)
+
+// getServiceAccounts returns the service account attached to the VM, none if the VM runs without one.
+func getServiceAccounts(opts *types.TargetOptions) []*computepb.ServiceAccount {
+	email := opts.GetServiceAccount()
+	if email == types.ServiceAccountNone {
+		return nil
+	}
+
+	return []*computepb.ServiceAccount{
+		{
+			Email:  toPtr(email),
+			Scopes: opts.GetServiceAccountScopes(),
+		},
+	}
+}
+
+// validateServiceAccount checks that a service account given by email exists and is enabled. Attaching it to the VM
+// needs roles/iam.serviceAccountUser, which also grants reading it, so a permission error is returned as it is.
+func validateServiceAccount(ctx context.Context, clients *ClientManager, opts *types.TargetOptions, validationErr *ValidationError) error {
+	email := opts.GetServiceAccount()
+	if email == types.ServiceAccountDefault || email == types.ServiceAccountNone {
+		return nil
+	}
+	if !strings.Contains(email, "@") {
+		validationErr.add("Service Account", "%q must be a service account email, %s or %s", email, types.ServiceAccountDefault, types.ServiceAccountNone)
+		return nil
+	}
+
+	service, err := clients.IAMService(opts)
+	if err != nil {
+		return err
+	}
+
+	// The - wildcard lets GCP find the project of the service account, which can differ from the project of the VM
+	serviceAccount, err := service.Projects.ServiceAccounts.Get("projects/-/serviceAccounts/" + email).Context(ctx).Do()
+	if isNotFound(err) {
+		validationErr.add("Service Account", "service account %q does not exist", email)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if serviceAccount.Disabled {
+		validationErr.add("Service Account", "service account %q is disabled", email)
+	}
+
+	return nil
+}
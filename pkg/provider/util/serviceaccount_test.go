@@ -0,0 +1,91 @@
+package util
+
+import (
+	"bytes"
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/daytonaio/daytona-provider-gcp/internal/fakecompute"
+	"github.com/daytonaio/daytona-provider-gcp/pkg/types"
+	"github.com/daytonaio/daytona/pkg/workspace"
+)
+
+func TestCreateWorkspaceServiceAccount(t *testing.T) {
+	tests := []struct {
+		name           string
+		serviceAccount string
+		scopes         string
+		wantEmail      string
+		wantScopes     []string
+	}{
+		{
+			name:       "Default service account",
+			wantEmail:  types.ServiceAccountDefault,
+			wantScopes: []string{types.DefaultServiceAccountScopes},
+		},
+		{
+			name:           "Least privilege service account",
+			serviceAccount: "registry-reader@project.iam.gserviceaccount.com",
+			scopes:         "devstorage.read_only,logging.write",
+			wantEmail:      "registry-reader@project.iam.gserviceaccount.com",
+			wantScopes:     []string{"https://www.googleapis.com/auth/devstorage.read_only", "https://www.googleapis.com/auth/logging.write"},
+		},
+		{
+			name:           "No service account",
+			serviceAccount: types.ServiceAccountNone,
+			scopes:         "cloud-platform",
+			wantEmail:      types.ServiceAccountNone,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := fakecompute.NewServer()
+			defer server.Close()
+
+			clients := NewClientManager(server.ClientOptions()...)
+			defer clients.Close()
+
+			ctx := context.Background()
+			opts := &types.TargetOptions{
+				AuthMode:             types.AuthModeApplicationDefault,
+				ProjectID:            "project",
+				Zone:                 "us-central1-a",
+				ServiceAccount:       tt.serviceAccount,
+				ServiceAccountScopes: tt.scopes,
+			}
+			ws := &workspace.Workspace{Id: "123", EnvVars: map[string]string{}}
+
+			err := CreateWorkspace(ctx, clients, ws, opts, "", nil, &bytes.Buffer{})
+			if err != nil {
+				t.Fatalf("Error creating workspace: %s", err)
+			}
+
+			serviceAccounts := server.GetInstance("project", "us-central1-a", "daytona-123").GetServiceAccounts()
+			if tt.wantScopes == nil {
+				if len(serviceAccounts) != 0 {
+					t.Fatalf("Expected no service account, got %v", serviceAccounts)
+				}
+			} else {
+				if len(serviceAccounts) != 1 {
+					t.Fatalf("Expected a single service account, got %v", serviceAccounts)
+				}
+				if serviceAccounts[0].GetEmail() != tt.wantEmail {
+					t.Errorf("Expected service account %s, got %s", tt.wantEmail, serviceAccounts[0].GetEmail())
+				}
+				if !reflect.DeepEqual(serviceAccounts[0].GetScopes(), tt.wantScopes) {
+					t.Errorf("Expected scopes %v, got %v", tt.wantScopes, serviceAccounts[0].GetScopes())
+				}
+			}
+
+			metadata, err := GetWorkspaceMetadata(ctx, clients, ws, opts)
+			if err != nil {
+				t.Fatalf("Error getting the workspace metadata: %s", err)
+			}
+			if metadata.ServiceAccount != tt.wantEmail {
+				t.Errorf("Expected service account %s in the metadata, got %s", tt.wantEmail, metadata.ServiceAccount)
+			}
+		})
+	}
+}
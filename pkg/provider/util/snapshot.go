@@ -0,0 +1,175 @@
+package util
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/compute/apiv1/computepb"
+	"github.com/daytonaio/daytona-provider-gcp/pkg/startupscript"
+	"github.com/daytonaio/daytona-provider-gcp/pkg/types"
+	"github.com/daytonaio/daytona/pkg/workspace"
+	"google.golang.org/api/iterator"
+)
+
+// snapshotDiskLabel tells the boot disk snapshot of a workspace from the data disk snapshot.
+const snapshotDiskLabel = "daytona-disk"
+
+// SnapshotWorkspace snapshots the boot and data disks of the workspace compute instance and returns the snapshot names.
+// The snapshots of a running instance are crash consistent, stop the workspace first for consistent snapshots. The boot
+// disk holds the workspace secrets, including the Daytona API key of the workspace, which the provider can't remove from
+// a disk, so the boot disk snapshot says so in its description.
+func SnapshotWorkspace(ctx context.Context, clients *ClientManager, workspace *workspace.Workspace, opts *types.TargetOptions, logWriter io.Writer) ([]string, error) {
+	instance, err := GetComputeInstance(ctx, clients, workspace, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	client, err := clients.SnapshotsClient(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := withOperationTimeout(ctx, opts)
+	defer cancel()
+
+	timestamp := time.Now().UTC().Format("20060102150405")
+	snapshots := []string{}
+	for _, disk := range instance.GetDisks() {
+		kind := "boot"
+		description := "Boot disk of Daytona workspace " + workspace.Id + ". Contains the workspace secrets in " +
+			startupscript.SecretsDir + ", including the Daytona API key of the workspace."
+		if !disk.GetBoot() {
+			if disk.GetDeviceName() != types.DataDiskDeviceName {
+				continue
+			}
+			kind = "data"
+			description = "Data disk of Daytona workspace " + workspace.Id + "."
+		}
+
+		name := fmt.Sprintf("%s-%s-%s", getResourceName(workspace.Id), kind, timestamp)
+		labels := getWorkspaceLabels(workspace, opts)
+		labels[snapshotDiskLabel] = kind
+		op, err := client.Insert(ctx, &computepb.InsertSnapshotRequest{
+			Project: opts.ProjectID,
+			SnapshotResource: &computepb.Snapshot{
+				Name:        toPtr(name),
+				Description: toPtr(description),
+				SourceDisk:  toPtr(disk.GetSource()),
+				Labels:      labels,
+			},
+		})
+		if err != nil {
+			return snapshots, wrapOperationError(ctx, "creating the snapshot", opts, err)
+		}
+
+		err = waitForOperation(ctx, op, logWriter, "Creating GCP snapshot "+name, "GCP snapshot "+name+" created")
+		if err != nil {
+			return snapshots, wrapOperationError(ctx, "creating the snapshot", opts, err)
+		}
+		snapshots = append(snapshots, name)
+	}
+
+	return snapshots, nil
+}
+
+// ListWorkspaceSnapshots lists the snapshots created for the workspace.
+func ListWorkspaceSnapshots(ctx context.Context, clients *ClientManager, workspaceId string, opts *types.TargetOptions) ([]*computepb.Snapshot, error) {
+	client, err := clients.SnapshotsClient(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := withOperationTimeout(ctx, opts)
+	defer cancel()
+
+	it := client.List(ctx, &computepb.ListSnapshotsRequest{
+		Project: opts.ProjectID,
+		Filter:  toPtr(fmt.Sprintf("labels.%s = %q", workspaceIdLabel, toLabelValue(workspaceId))),
+	})
+
+	snapshots := []*computepb.Snapshot{}
+	for {
+		snapshot, err := it.Next()
+		if errors.Is(err, iterator.Done) {
+			return snapshots, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		snapshots = append(snapshots, snapshot)
+	}
+}
+
+// DeleteSnapshot deletes a snapshot of the project.
+func DeleteSnapshot(ctx context.Context, clients *ClientManager, name string, opts *types.TargetOptions, logWriter io.Writer) error {
+	client, err := clients.SnapshotsClient(opts)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := withOperationTimeout(ctx, opts)
+	defer cancel()
+
+	op, err := client.Delete(ctx, &computepb.DeleteSnapshotRequest{
+		Project:  opts.ProjectID,
+		Snapshot: name,
+	})
+	if err != nil {
+		return wrapOperationError(ctx, "deleting the snapshot", opts, err)
+	}
+
+	err = waitForOperation(ctx, op, logWriter, "Deleting GCP snapshot "+name, "GCP snapshot "+name+" deleted")
+	return wrapOperationError(ctx, "deleting the snapshot", opts, err)
+}
+
+// getSnapshotPath returns the path of a snapshot given by name or path.
+func getSnapshotPath(snapshot string, opts *types.TargetOptions) string {
+	if strings.Contains(snapshot, "/") {
+		return strings.TrimPrefix(snapshot, "https://www.googleapis.com/compute/v1/")
+	}
+
+	return fmt.Sprintf("projects/%s/global/snapshots/%s", opts.ProjectID, snapshot)
+}
+
+// validateSnapshot checks that the snapshot a disk is restored from is ready and fits into a disk of the given size.
+func validateSnapshot(ctx context.Context, clients *ClientManager, opts *types.TargetOptions, field, snapshot, sizeField string, diskSize int, validationErr *ValidationError) error {
+	if snapshot == "" {
+		return nil
+	}
+
+	project, _, name, err := parseResourcePath(getSnapshotPath(snapshot, opts))
+	if err != nil {
+		validationErr.add(field, "%s", err.Error())
+		return nil
+	}
+
+	client, err := clients.SnapshotsClient(opts)
+	if err != nil {
+		return err
+	}
+
+	result, err := client.Get(ctx, &computepb.GetSnapshotRequest{
+		Project:  project,
+		Snapshot: name,
+	})
+	if isNotFound(err) {
+		validationErr.add(field, "snapshot %q does not exist in project %q", name, project)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if result.GetStatus() != computepb.Snapshot_READY.String() {
+		validationErr.add(field, "snapshot %q is %s", name, result.GetStatus())
+	}
+	if diskSize > 0 && int64(diskSize) < result.GetDiskSizeGb() {
+		validationErr.add(sizeField, "disk size %d GB is smaller than the %d GB of snapshot %q", diskSize, result.GetDiskSizeGb(), name)
+	}
+
+	return nil
+}
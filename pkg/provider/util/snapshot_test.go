@@ -0,0 +1,110 @@
+package util
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/daytonaio/daytona-provider-gcp/internal/fakecompute"
+	"github.com/daytonaio/daytona-provider-gcp/pkg/startupscript"
+	"github.com/daytonaio/daytona-provider-gcp/pkg/types"
+	"github.com/daytonaio/daytona/pkg/workspace"
+)
+
+func TestSnapshotAndRestoreWorkspace(t *testing.T) {
+	server := fakecompute.NewServer()
+	defer server.Close()
+
+	clients := NewClientManager(server.ClientOptions()...)
+	defer clients.Close()
+
+	ctx := context.Background()
+	logWriter := &bytes.Buffer{}
+	opts := &types.TargetOptions{
+		AuthMode:     types.AuthModeApplicationDefault,
+		ProjectID:    "project",
+		Zone:         "us-central1-a",
+		MachineType:  "n1-standard-1",
+		DiskType:     "pd-standard",
+		DiskSize:     20,
+		VMImage:      "projects/ubuntu-os-cloud/global/images/family/ubuntu-2204-lts",
+		DataDiskSize: 100,
+	}
+	ws := &workspace.Workspace{Id: "123", Name: "my-workspace", EnvVars: map[string]string{}}
+
+	err := CreateWorkspace(ctx, clients, ws, opts, "echo init", nil, logWriter)
+	if err != nil {
+		t.Fatalf("Error creating workspace: %s", err)
+	}
+
+	snapshots, err := SnapshotWorkspace(ctx, clients, ws, opts, logWriter)
+	if err != nil {
+		t.Fatalf("Error snapshotting workspace: %s", err)
+	}
+	if len(snapshots) != 2 || !strings.HasPrefix(snapshots[0], "daytona-123-boot-") || !strings.HasPrefix(snapshots[1], "daytona-123-data-") {
+		t.Fatalf("Expected a boot and a data disk snapshot, got %v", snapshots)
+	}
+
+	listed, err := ListWorkspaceSnapshots(ctx, clients, ws.Id, opts)
+	if err != nil {
+		t.Fatalf("Error listing snapshots: %s", err)
+	}
+	if len(listed) != 2 || listed[0].GetLabels()[snapshotDiskLabel] != "boot" || listed[1].GetDiskSizeGb() != 100 {
+		t.Errorf("Expected the snapshots of the workspace to be listed, got %v", listed)
+	}
+	if !strings.Contains(listed[0].GetDescription(), startupscript.SecretsDir) {
+		t.Errorf("Expected the boot disk snapshot to say that it contains the workspace secrets, got %q", listed[0].GetDescription())
+	}
+
+	other, err := ListWorkspaceSnapshots(ctx, clients, "456", opts)
+	if err != nil {
+		t.Fatalf("Error listing snapshots: %s", err)
+	}
+	if len(other) != 0 {
+		t.Errorf("Expected no snapshots of another workspace, got %v", other)
+	}
+
+	// A new workspace restores both disks from the snapshots
+	restoreOpts := *opts
+	restoreOpts.SourceSnapshot = snapshots[0]
+	restoreOpts.SourceDataSnapshot = snapshots[1]
+	restored := &workspace.Workspace{Id: "456", Name: "restored", EnvVars: map[string]string{}}
+
+	err = CreateWorkspace(ctx, clients, restored, &restoreOpts, "echo init", nil, logWriter)
+	if err != nil {
+		t.Fatalf("Error creating workspace from snapshots: %s", err)
+	}
+
+	bootDisk := server.GetDisk("project", "us-central1-a", "daytona-456")
+	if bootDisk.GetSourceSnapshot() != "projects/project/global/snapshots/"+snapshots[0] || bootDisk.GetSourceImage() != "" {
+		t.Errorf("Expected the boot disk to be restored from %s, got %v", snapshots[0], bootDisk)
+	}
+	dataDisk := server.GetDisk("project", "us-central1-a", "daytona-restored-data")
+	if dataDisk.GetSourceSnapshot() != "projects/project/global/snapshots/"+snapshots[1] {
+		t.Errorf("Expected the data disk to be restored from %s, got %v", snapshots[1], dataDisk)
+	}
+
+	metadata, err := GetWorkspaceMetadata(ctx, clients, restored, &restoreOpts)
+	if err != nil {
+		t.Fatalf("Error getting workspace metadata: %s", err)
+	}
+	if metadata.SourceSnapshot != snapshots[0] {
+		t.Errorf("Expected source snapshot %s in the metadata, got %q", snapshots[0], metadata.SourceSnapshot)
+	}
+
+	for _, snapshot := range snapshots {
+		err = DeleteSnapshot(ctx, clients, snapshot, opts, logWriter)
+		if err != nil {
+			t.Fatalf("Error deleting snapshot %s: %s", snapshot, err)
+		}
+	}
+
+	listed, err = ListWorkspaceSnapshots(ctx, clients, ws.Id, opts)
+	if err != nil {
+		t.Fatalf("Error listing snapshots: %s", err)
+	}
+	if len(listed) != 0 {
+		t.Errorf("Expected the snapshots to be deleted, got %v", listed)
+	}
+}
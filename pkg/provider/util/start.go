@@ -0,0 +1,134 @@
+package util
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"time"
+
+	compute "cloud.google.com/go/compute/apiv1"
+	"cloud.google.com/go/compute/apiv1/computepb"
+	"github.com/daytonaio/daytona-provider-gcp/pkg/types"
+	"github.com/daytonaio/daytona/pkg/workspace"
+)
+
+type startAction int
+
+const (
+	startActionDone startAction = iota
+	startActionStart
+	startActionResume
+	startActionWait
+)
+
+// statusPollInterval is the interval between instance status checks while waiting for GCP to finish a transition.
+var statusPollInterval = 5 * time.Second
+
+// getStartAction returns the next step needed to bring an instance with the given status to RUNNING.
+func getStartAction(status string) (startAction, error) {
+	switch status {
+	case computepb.Instance_RUNNING.String():
+		return startActionDone, nil
+	case computepb.Instance_TERMINATED.String(), computepb.Instance_STOPPED.String():
+		return startActionStart, nil
+	case computepb.Instance_SUSPENDED.String():
+		return startActionResume, nil
+	case computepb.Instance_PROVISIONING.String(),
+		computepb.Instance_STAGING.String(),
+		computepb.Instance_REPAIRING.String(),
+		computepb.Instance_STOPPING.String(),
+		computepb.Instance_SUSPENDING.String(),
+		computepb.Instance_DEPROVISIONING.String():
+		return startActionWait, nil
+	default:
+		return startActionDone, fmt.Errorf("unexpected compute instance status %q", status)
+	}
+}
+
+// StartWorkspace brings the workspace compute instance to the RUNNING state. Stopped instances are started,
+// suspended instances are resumed and instances in a transitional state are waited on until GCP settles them.
+// Spot instances that GCP deleted on preemption can't be started, because their projects were lost with the boot disk.
+func StartWorkspace(ctx context.Context, clients *ClientManager, workspace *workspace.Workspace, opts *types.TargetOptions, logWriter io.Writer) error {
+	client, err := clients.InstancesClient(opts)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := withOperationTimeout(ctx, opts)
+	defer cancel()
+
+	instanceName := getResourceName(workspace.Id)
+	err = startComputeInstance(ctx, client, instanceName, opts, logWriter)
+	if isNotFound(err) && opts.IsDeletedOnPreemption() {
+		return fmt.Errorf("compute instance %s was deleted on preemption together with its boot disk and can't be started, "+
+			"recreate the workspace under the same name to continue: %w", instanceName, err)
+	}
+
+	return wrapOperationError(ctx, "starting the compute instance", opts, err)
+}
+
+func startComputeInstance(ctx context.Context, client *compute.InstancesClient, instanceName string, opts *types.TargetOptions, logWriter io.Writer) error {
+	lastStatus := ""
+	for {
+		instance, err := client.Get(ctx, &computepb.GetInstanceRequest{
+			Project:  opts.ProjectID,
+			Zone:     opts.Zone,
+			Instance: instanceName,
+		})
+		if err != nil {
+			return err
+		}
+
+		status := instance.GetStatus()
+		action, err := getStartAction(status)
+		if err != nil {
+			return err
+		}
+
+		switch action {
+		case startActionDone:
+			logWriter.Write([]byte("GCP compute instance is running\n"))
+			return nil
+		case startActionStart:
+			op, err := client.Start(ctx, &computepb.StartInstanceRequest{
+				Project:  opts.ProjectID,
+				Zone:     opts.Zone,
+				Instance: instanceName,
+			})
+			if err != nil {
+				return err
+			}
+
+			err = waitForOperation(ctx, op, logWriter, "Starting GCP compute instance", "GCP compute instance started")
+			if err != nil {
+				return err
+			}
+		case startActionResume:
+			op, err := client.Resume(ctx, &computepb.ResumeInstanceRequest{
+				Project:  opts.ProjectID,
+				Zone:     opts.Zone,
+				Instance: instanceName,
+			})
+			if err != nil {
+				return err
+			}
+
+			err = waitForOperation(ctx, op, logWriter, "Resuming GCP compute instance", "GCP compute instance resumed")
+			if err != nil {
+				return err
+			}
+		case startActionWait:
+			if status != lastStatus {
+				logWriter.Write([]byte(fmt.Sprintf("GCP compute instance is %s, waiting for it to settle\n", status)))
+			}
+
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(statusPollInterval):
+			}
+		}
+
+		lastStatus = status
+	}
+}
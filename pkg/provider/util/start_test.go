@@ -0,0 +1,128 @@
+package util
+
+import (
+	"bytes"
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/compute/apiv1/computepb"
+	"github.com/daytonaio/daytona-provider-gcp/internal/fakecompute"
+	"github.com/daytonaio/daytona-provider-gcp/pkg/types"
+	"github.com/daytonaio/daytona/pkg/workspace"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestStartWorkspace(t *testing.T) {
+	statusPollInterval = time.Millisecond
+
+	tests := []struct {
+		name         string
+		status       computepb.Instance_Status
+		queued       []computepb.Instance_Status
+		wantRequests []string
+		wantErr      bool
+	}{
+		{
+			name:         "Running instance is left alone",
+			status:       computepb.Instance_RUNNING,
+			wantRequests: []string{"get daytona-123"},
+		},
+		{
+			name:         "Terminated instance is started",
+			status:       computepb.Instance_TERMINATED,
+			wantRequests: []string{"get daytona-123", "start daytona-123", "get daytona-123"},
+		},
+		{
+			name:         "Suspended instance is resumed",
+			status:       computepb.Instance_SUSPENDED,
+			wantRequests: []string{"get daytona-123", "resume daytona-123", "get daytona-123"},
+		},
+		{
+			name:         "Stopping instance is started once it is terminated",
+			status:       computepb.Instance_STOPPING,
+			queued:       []computepb.Instance_Status{computepb.Instance_STOPPING, computepb.Instance_STOPPING, computepb.Instance_TERMINATED},
+			wantRequests: []string{"get daytona-123", "get daytona-123", "get daytona-123", "start daytona-123", "get daytona-123"},
+		},
+		{
+			name:         "Provisioning instance is waited on until it is running",
+			status:       computepb.Instance_PROVISIONING,
+			queued:       []computepb.Instance_Status{computepb.Instance_PROVISIONING, computepb.Instance_STAGING, computepb.Instance_RUNNING},
+			wantRequests: []string{"get daytona-123", "get daytona-123", "get daytona-123"},
+		},
+		{
+			name:    "Unknown status fails",
+			status:  computepb.Instance_UNDEFINED_STATUS,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := fakecompute.NewServer()
+			defer server.Close()
+
+			clients := NewClientManager(server.ClientOptions()...)
+			defer clients.Close()
+
+			opts := &types.TargetOptions{
+				AuthMode:  types.AuthModeApplicationDefault,
+				ProjectID: "project",
+				Zone:      "us-central1-a",
+			}
+
+			server.SetInstance(opts.ProjectID, opts.Zone, &computepb.Instance{
+				Name:   proto.String("daytona-123"),
+				Status: proto.String(tt.status.String()),
+			})
+			server.QueueStatuses(opts.ProjectID, opts.Zone, "daytona-123", tt.queued...)
+
+			err := StartWorkspace(context.Background(), clients, &workspace.Workspace{Id: "123"}, opts, &bytes.Buffer{})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("StartWorkspace() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+
+			if got := server.Requests(); !reflect.DeepEqual(got, tt.wantRequests) {
+				t.Errorf("StartWorkspace() requests = %v, want %v", got, tt.wantRequests)
+			}
+
+			instance := server.GetInstance(opts.ProjectID, opts.Zone, "daytona-123")
+			if instance.GetStatus() != computepb.Instance_RUNNING.String() {
+				t.Errorf("Expected instance to be RUNNING, got %s", instance.GetStatus())
+			}
+		})
+	}
+}
+
+func TestStartWorkspaceTimeout(t *testing.T) {
+	statusPollInterval = time.Millisecond
+
+	server := fakecompute.NewServer()
+	defer server.Close()
+
+	clients := NewClientManager(server.ClientOptions()...)
+	defer clients.Close()
+
+	opts := &types.TargetOptions{
+		AuthMode:  types.AuthModeApplicationDefault,
+		ProjectID: "project",
+		Zone:      "us-central1-a",
+	}
+
+	server.SetInstance(opts.ProjectID, opts.Zone, &computepb.Instance{
+		Name:   proto.String("daytona-123"),
+		Status: proto.String(computepb.Instance_STOPPING.String()),
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	err := StartWorkspace(ctx, clients, &workspace.Workspace{Id: "123"}, opts, &bytes.Buffer{})
+	if err == nil {
+		t.Fatalf("Expected an error for an instance stuck in STOPPING")
+	}
+}
@@ -0,0 +1,77 @@
+package util
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Transaction records the resources created for a workspace, so that they can be deleted if the creation of the
+// workspace fails. Recording on a nil transaction is a no-op.
+type Transaction struct {
+	resources []*createdResource
+}
+
+type createdResource struct {
+	name   string
+	delete func(ctx context.Context, logWriter io.Writer) error
+}
+
+// NewTransaction creates a transaction without recorded resources.
+func NewTransaction() *Transaction {
+	return &Transaction{}
+}
+
+// Record adds a created resource, described by name, e.g. "compute instance daytona-123", and the function that deletes it.
+func (t *Transaction) Record(name string, delete func(ctx context.Context, logWriter io.Writer) error) {
+	if t == nil {
+		return
+	}
+
+	t.resources = append(t.resources, &createdResource{
+		name:   name,
+		delete: delete,
+	})
+}
+
+// Resources returns the names of the recorded resources in the order they were created.
+func (t *Transaction) Resources() []string {
+	if t == nil {
+		return nil
+	}
+
+	names := []string{}
+	for _, resource := range t.resources {
+		names = append(names, resource.name)
+	}
+
+	return names
+}
+
+// Rollback deletes the recorded resources in the reverse order of their creation. A failed deletion doesn't stop the
+// rollback. The resources that couldn't be deleted are kept recorded and their errors are returned.
+func (t *Transaction) Rollback(ctx context.Context, logWriter io.Writer) error {
+	if t == nil {
+		return nil
+	}
+
+	failed := []*createdResource{}
+	errs := []error{}
+	for i := len(t.resources) - 1; i >= 0; i-- {
+		resource := t.resources[i]
+
+		err := resource.delete(ctx, logWriter)
+		if err != nil {
+			logWriter.Write([]byte("Failed to delete " + resource.name + ": " + err.Error() + "\n"))
+			failed = append([]*createdResource{resource}, failed...)
+			errs = append(errs, fmt.Errorf("deleting %s: %w", resource.name, err))
+			continue
+		}
+
+		logWriter.Write([]byte("Deleted " + resource.name + "\n"))
+	}
+	t.resources = failed
+
+	return errors.Join(errs...)
+}
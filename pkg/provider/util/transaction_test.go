@@ -0,0 +1,156 @@
+package util
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/daytonaio/daytona-provider-gcp/internal/fakecompute"
+	"github.com/daytonaio/daytona-provider-gcp/pkg/types"
+	"github.com/daytonaio/daytona/pkg/workspace"
+)
+
+func TestCreateWorkspaceRollback(t *testing.T) {
+	server := fakecompute.NewServer()
+	defer server.Close()
+
+	clients := NewClientManager(server.ClientOptions()...)
+	defer clients.Close()
+
+	ctx := context.Background()
+	logWriter := &bytes.Buffer{}
+	opts := &types.TargetOptions{
+		AuthMode:        types.AuthModeApplicationDefault,
+		ProjectID:       "project",
+		Zone:            "us-central1-a",
+		MachineType:     "n1-standard-1",
+		DiskType:        "pd-standard",
+		DiskSize:        20,
+		VMImage:         "projects/ubuntu-os-cloud/global/images/family/ubuntu-2204-lts",
+		DataDiskSize:    100,
+		RetainDataDisk:  true,
+		ManagedFirewall: true,
+	}
+	ws := &workspace.Workspace{Id: "123", Name: "my-workspace", EnvVars: map[string]string{}}
+
+	tx := NewTransaction()
+	err := CreateWorkspace(ctx, clients, ws, opts, "", tx, logWriter)
+	if err != nil {
+		t.Fatalf("Error creating workspace: %s", err)
+	}
+
+	want := []string{"data disk daytona-my-workspace-data", "compute instance daytona-123"}
+	if !reflect.DeepEqual(tx.Resources(), want) {
+		t.Errorf("Expected recorded resources %v, got %v", want, tx.Resources())
+	}
+
+	err = tx.Rollback(ctx, logWriter)
+	if err != nil {
+		t.Fatalf("Error rolling back: %s", err)
+	}
+	if server.GetInstance("project", "us-central1-a", "daytona-123") != nil {
+		t.Errorf("Expected the instance to be deleted")
+	}
+	if server.GetDisk("project", "us-central1-a", "daytona-my-workspace-data") != nil {
+		t.Errorf("Expected the retained data disk to be deleted")
+	}
+	if server.GetFirewall("project", "daytona-deny-ingress-default") == nil {
+		t.Errorf("Expected the shared managed firewall rule to be kept")
+	}
+	if len(tx.Resources()) != 0 {
+		t.Errorf("Expected no resources left after the rollback, got %v", tx.Resources())
+	}
+}
+
+func TestCreateWorkspaceRollbackKeepsExistingResources(t *testing.T) {
+	server := fakecompute.NewServer()
+	defer server.Close()
+
+	clients := NewClientManager(server.ClientOptions()...)
+	defer clients.Close()
+
+	ctx := context.Background()
+	opts := &types.TargetOptions{
+		AuthMode:        types.AuthModeApplicationDefault,
+		ProjectID:       "project",
+		Zone:            "us-central1-a",
+		MachineType:     "n1-standard-1",
+		DiskType:        "pd-standard",
+		DiskSize:        20,
+		VMImage:         "projects/ubuntu-os-cloud/global/images/family/ubuntu-2204-lts",
+		DataDiskSize:    100,
+		RetainDataDisk:  true,
+		ManagedFirewall: true,
+	}
+
+	// The first workspace creates the firewall rule and the retained data disk is left by a destroyed workspace
+	err := CreateWorkspace(ctx, clients, &workspace.Workspace{Id: "other", Name: "other", EnvVars: map[string]string{}}, opts, "", nil, &bytes.Buffer{})
+	if err != nil {
+		t.Fatalf("Error creating workspace: %s", err)
+	}
+	ws := &workspace.Workspace{Id: "123", Name: "my-workspace", EnvVars: map[string]string{}}
+	err = CreateWorkspace(ctx, clients, ws, opts, "", nil, &bytes.Buffer{})
+	if err != nil {
+		t.Fatalf("Error creating workspace: %s", err)
+	}
+	err = DeleteWorkspace(ctx, clients, ws, opts, &bytes.Buffer{})
+	if err != nil {
+		t.Fatalf("Error deleting workspace: %s", err)
+	}
+
+	tx := NewTransaction()
+	err = CreateWorkspace(ctx, clients, ws, opts, "", tx, &bytes.Buffer{})
+	if err != nil {
+		t.Fatalf("Error creating workspace: %s", err)
+	}
+	if !reflect.DeepEqual(tx.Resources(), []string{"compute instance daytona-123"}) {
+		t.Errorf("Expected only the instance to be recorded, got %v", tx.Resources())
+	}
+
+	err = tx.Rollback(ctx, &bytes.Buffer{})
+	if err != nil {
+		t.Fatalf("Error rolling back: %s", err)
+	}
+	if server.GetDisk("project", "us-central1-a", "daytona-my-workspace-data") == nil {
+		t.Errorf("Expected the reattached data disk to be kept")
+	}
+	if server.GetFirewall("project", "daytona-deny-ingress-default") == nil {
+		t.Errorf("Expected the existing firewall rule to be kept")
+	}
+}
+
+func TestTransactionRollbackFailure(t *testing.T) {
+	deleted := []string{}
+	deleteFunc := func(name string, err error) func(context.Context, io.Writer) error {
+		return func(ctx context.Context, logWriter io.Writer) error {
+			if err == nil {
+				deleted = append(deleted, name)
+			}
+			return err
+		}
+	}
+
+	tx := NewTransaction()
+	tx.Record("first", deleteFunc("first", nil))
+	tx.Record("second", deleteFunc("second", errors.New("permission denied")))
+	tx.Record("third", deleteFunc("third", nil))
+
+	logWriter := &bytes.Buffer{}
+	err := tx.Rollback(context.Background(), logWriter)
+	if err == nil || !strings.Contains(err.Error(), "deleting second: permission denied") {
+		t.Errorf("Expected the failed deletion to be returned, got %v", err)
+	}
+	if !reflect.DeepEqual(deleted, []string{"third", "first"}) {
+		t.Errorf("Expected the other resources to be deleted in reverse order, got %v", deleted)
+	}
+	if !reflect.DeepEqual(tx.Resources(), []string{"second"}) {
+		t.Errorf("Expected the failed resource to stay recorded, got %v", tx.Resources())
+	}
+	if !strings.Contains(logWriter.String(), "Failed to delete second: permission denied") {
+		t.Errorf("Expected the failure to be logged, got:\n%s", logWriter.String())
+	}
+}
@@ -0,0 +1,382 @@
+package util
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"path"
+	"strings"
+
+	"cloud.google.com/go/compute/apiv1/computepb"
+	"github.com/daytonaio/daytona-provider-gcp/pkg/types"
+	"google.golang.org/api/googleapi"
+	"google.golang.org/api/iterator"
+)
+
+// FieldError is a problem with a single target option.
+type FieldError struct {
+	Field   string
+	Message string
+}
+
+func (e *FieldError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Field, e.Message)
+}
+
+// ValidationError holds every problem found in the target options.
+type ValidationError struct {
+	Errors []*FieldError
+}
+
+func (e *ValidationError) Error() string {
+	messages := make([]string, 0, len(e.Errors))
+	for _, fieldErr := range e.Errors {
+		messages = append(messages, fieldErr.Error())
+	}
+
+	return "invalid target options: " + strings.Join(messages, "; ")
+}
+
+func (e *ValidationError) Unwrap() []error {
+	errs := make([]error, 0, len(e.Errors))
+	for _, fieldErr := range e.Errors {
+		errs = append(errs, fieldErr)
+	}
+
+	return errs
+}
+
+func (e *ValidationError) add(field, format string, args ...any) {
+	e.Errors = append(e.Errors, &FieldError{
+		Field:   field,
+		Message: fmt.Sprintf(format, args...),
+	})
+}
+
+// ValidateTargetOptions checks the target options against the catalogue of the project before anything is provisioned.
+// Problems with the target options are returned together as a *ValidationError. Other errors, e.g. missing
+// permissions, are returned as they are.
+func ValidateTargetOptions(ctx context.Context, clients *ClientManager, opts *types.TargetOptions) error {
+	ctx, cancel := withOperationTimeout(ctx, opts)
+	defer cancel()
+
+	validationErr := &ValidationError{}
+
+	zoneExists, err := validateZone(ctx, clients, opts, validationErr)
+	if err != nil {
+		return err
+	}
+
+	// Machine, disk and accelerator types are zonal so they can only be checked in an existing zone
+	if zoneExists {
+		err = validateMachineType(ctx, clients, opts, validationErr)
+		if err != nil {
+			return err
+		}
+
+		err = validateDiskType(ctx, clients, opts, validationErr)
+		if err != nil {
+			return err
+		}
+
+		err = validateAccelerator(ctx, clients, opts, validationErr)
+		if err != nil {
+			return err
+		}
+
+		err = validateDataDisk(ctx, clients, opts, validationErr)
+		if err != nil {
+			return err
+		}
+	}
+
+	// The VM image isn't used when the boot disk is restored from a snapshot
+	if opts.SourceSnapshot == "" {
+		err = validateImage(ctx, clients, opts, validationErr)
+	} else {
+		err = validateSnapshot(ctx, clients, opts, "Source Snapshot", opts.SourceSnapshot, "Disk Size", opts.DiskSize, validationErr)
+	}
+	if err != nil {
+		return err
+	}
+
+	if opts.SourceDataSnapshot != "" && opts.DataDiskSize <= 0 {
+		validationErr.add("Source Data Snapshot", "a source data snapshot requires a data disk size")
+	} else {
+		err = validateSnapshot(ctx, clients, opts, "Source Data Snapshot", opts.SourceDataSnapshot, "Data Disk Size", opts.DataDiskSize, validationErr)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = validateNetwork(ctx, clients, opts, validationErr)
+	if err != nil {
+		return err
+	}
+
+	err = validateExternalIPAddress(ctx, clients, opts, validationErr)
+	if err != nil {
+		return err
+	}
+
+	err = validateServiceAccount(ctx, clients, opts, validationErr)
+	if err != nil {
+		return err
+	}
+
+	validateSecurity(opts, validationErr)
+	validateNetworkTags(opts, validationErr)
+	validateLabels(opts, validationErr)
+	validateImageFamily(opts, validationErr)
+	validateIdleTimeout(opts, validationErr)
+	validateSchedule(opts, validationErr)
+
+	if len(validationErr.Errors) > 0 {
+		return validationErr
+	}
+
+	return nil
+}
+
+func validateZone(ctx context.Context, clients *ClientManager, opts *types.TargetOptions, validationErr *ValidationError) (bool, error) {
+	client, err := clients.ZonesClient(opts)
+	if err != nil {
+		return false, err
+	}
+
+	zone, err := client.Get(ctx, &computepb.GetZoneRequest{
+		Project: opts.ProjectID,
+		Zone:    opts.Zone,
+	})
+	if isNotFound(err) {
+		validationErr.add("Zone", "zone %q does not exist", opts.Zone)
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	if zone.GetStatus() != computepb.Zone_UP.String() {
+		validationErr.add("Zone", "zone %q is %s", opts.Zone, zone.GetStatus())
+	}
+
+	return true, nil
+}
+
+func validateMachineType(ctx context.Context, clients *ClientManager, opts *types.TargetOptions, validationErr *ValidationError) error {
+	client, err := clients.MachineTypesClient(opts)
+	if err != nil {
+		return err
+	}
+
+	machineType, err := client.Get(ctx, &computepb.GetMachineTypeRequest{
+		Project:     opts.ProjectID,
+		Zone:        opts.Zone,
+		MachineType: opts.MachineType,
+	})
+	if isNotFound(err) {
+		validationErr.add("Machine Type", "machine type %q is not available in zone %q", opts.MachineType, opts.Zone)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if isDeprecated(machineType.GetDeprecated()) {
+		validationErr.add("Machine Type", "machine type %q is %s", opts.MachineType, machineType.GetDeprecated().GetState())
+	}
+
+	return nil
+}
+
+func validateDiskType(ctx context.Context, clients *ClientManager, opts *types.TargetOptions, validationErr *ValidationError) error {
+	client, err := clients.DiskTypesClient(opts)
+	if err != nil {
+		return err
+	}
+
+	_, err = client.Get(ctx, &computepb.GetDiskTypeRequest{
+		Project:  opts.ProjectID,
+		Zone:     opts.Zone,
+		DiskType: opts.DiskType,
+	})
+	if isNotFound(err) {
+		validationErr.add("Disk Type", "disk type %q is not available in zone %q", opts.DiskType, opts.Zone)
+		return nil
+	}
+
+	return err
+}
+
+func validateImage(ctx context.Context, clients *ClientManager, opts *types.TargetOptions, validationErr *ValidationError) error {
+	if opts.DiskSize < 0 {
+		validationErr.add("Disk Size", "disk size must not be negative")
+	}
+
+	image, err := getImage(ctx, clients, opts, opts.VMImage)
+	if isNotFound(err) {
+		validationErr.add("VM Image", "image %q does not exist", opts.VMImage)
+		return nil
+	}
+	var imageErr *invalidImageError
+	if errors.As(err, &imageErr) {
+		validationErr.add("VM Image", "%s", imageErr.Error())
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if isDeprecated(image.GetDeprecated()) && image.GetDeprecated().GetState() != computepb.DeprecationStatus_DEPRECATED.String() {
+		validationErr.add("VM Image", "image %q is %s", opts.VMImage, image.GetDeprecated().GetState())
+	}
+
+	if opts.DiskSize > 0 && int64(opts.DiskSize) < image.GetDiskSizeGb() {
+		validationErr.add("Disk Size", "disk size %d GB is smaller than the %d GB required by image %q", opts.DiskSize, image.GetDiskSizeGb(), opts.VMImage)
+	}
+
+	validateImageSecurity(opts, image, validationErr)
+
+	return nil
+}
+
+func validateNetwork(ctx context.Context, clients *ClientManager, opts *types.TargetOptions, validationErr *ValidationError) error {
+	if opts.Network != "" {
+		project, _, name, err := parseResourcePath(getNetworkPath(opts))
+		if err != nil {
+			validationErr.add("Network", "%s", err.Error())
+			return nil
+		}
+
+		client, err := clients.NetworksClient(opts)
+		if err != nil {
+			return err
+		}
+
+		_, err = client.Get(ctx, &computepb.GetNetworkRequest{
+			Project: project,
+			Network: name,
+		})
+		if isNotFound(err) {
+			validationErr.add("Network", "network %q does not exist in project %q", name, project)
+		} else if err != nil {
+			return err
+		}
+	}
+
+	if opts.Subnetwork == "" {
+		return nil
+	}
+
+	project, region, name, err := parseResourcePath(getSubnetworkPath(opts))
+	if err != nil {
+		validationErr.add("Subnetwork", "%s", err.Error())
+		return nil
+	}
+
+	if region != getRegion(opts.Zone) {
+		validationErr.add("Subnetwork", "subnetwork %q is not in the region of zone %q", opts.Subnetwork, opts.Zone)
+		return nil
+	}
+
+	client, err := clients.SubnetworksClient(opts)
+	if err != nil {
+		return err
+	}
+
+	subnetwork, err := client.Get(ctx, &computepb.GetSubnetworkRequest{
+		Project:    project,
+		Region:     region,
+		Subnetwork: name,
+	})
+	if isNotFound(err) {
+		validationErr.add("Subnetwork", "subnetwork %q does not exist in project %q", name, project)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if opts.Network != "" && !strings.HasSuffix(subnetwork.GetNetwork(), "/networks/"+path.Base(opts.Network)) {
+		validationErr.add("Subnetwork", "subnetwork %q is not part of network %q", opts.Subnetwork, opts.Network)
+	}
+
+	return nil
+}
+
+func validateExternalIPAddress(ctx context.Context, clients *ClientManager, opts *types.TargetOptions, validationErr *ValidationError) error {
+	if opts.ExternalIPAddress == "" {
+		return nil
+	}
+
+	if !opts.GetAssignExternalIP() {
+		validationErr.add("External IP Address", "a static external IP address can't be used when no external IP is assigned")
+		return nil
+	}
+
+	address, err := getExternalIPAddress(ctx, clients, opts)
+	if isNotFound(err) || errors.Is(err, iterator.Done) {
+		validationErr.add("External IP Address", "address %q is not reserved in region %q", opts.ExternalIPAddress, getRegion(opts.Zone))
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if address.GetAddressType() != computepb.Address_EXTERNAL.String() {
+		validationErr.add("External IP Address", "address %q is not an external address", opts.ExternalIPAddress)
+	} else if address.GetStatus() != computepb.Address_RESERVED.String() {
+		validationErr.add("External IP Address", "address %q is %s", opts.ExternalIPAddress, address.GetStatus())
+	}
+
+	return nil
+}
+
+type invalidImageError struct {
+	image string
+}
+
+func (e *invalidImageError) Error() string {
+	return fmt.Sprintf("image %q must be in the projects/<project>/global/images/<image> or projects/<project>/global/images/family/<family> format", e.image)
+}
+
+// getImage resolves an image or image family reference as accepted by the instance source image.
+func getImage(ctx context.Context, clients *ClientManager, opts *types.TargetOptions, image string) (*computepb.Image, error) {
+	client, err := clients.ImagesClient(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	project := opts.ProjectID
+	imagePath := strings.TrimPrefix(image, "https://www.googleapis.com/compute/v1/")
+	if strings.HasPrefix(imagePath, "projects/") {
+		parts := strings.SplitN(imagePath, "/", 3)
+		if len(parts) != 3 {
+			return nil, &invalidImageError{image: image}
+		}
+		project, imagePath = parts[1], parts[2]
+	}
+	imagePath = strings.TrimPrefix(imagePath, "global/images/")
+
+	if family, ok := strings.CutPrefix(imagePath, "family/"); ok {
+		return client.GetFromFamily(ctx, &computepb.GetFromFamilyImageRequest{
+			Project: project,
+			Family:  family,
+		})
+	}
+
+	if imagePath == "" || strings.Contains(imagePath, "/") {
+		return nil, &invalidImageError{image: image}
+	}
+
+	return client.Get(ctx, &computepb.GetImageRequest{
+		Project: project,
+		Image:   imagePath,
+	})
+}
+
+func isNotFound(err error) bool {
+	var apiErr *googleapi.Error
+	return errors.As(err, &apiErr) && apiErr.Code == http.StatusNotFound
+}
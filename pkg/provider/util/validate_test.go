@@ -0,0 +1,578 @@
+package util
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strconv"
+	"testing"
+
+	"cloud.google.com/go/compute/apiv1/computepb"
+	"github.com/daytonaio/daytona-provider-gcp/internal/fakecompute"
+	"github.com/daytonaio/daytona-provider-gcp/pkg/types"
+	"github.com/daytonaio/daytona/pkg/provider"
+	iam "google.golang.org/api/iam/v1"
+	"google.golang.org/protobuf/proto"
+)
+
+func newValidationServer() *fakecompute.Server {
+	server := fakecompute.NewServer()
+
+	server.AddZone(&computepb.Zone{Name: proto.String("us-central1-a"), Status: proto.String(computepb.Zone_UP.String())})
+	server.AddZone(&computepb.Zone{Name: proto.String("us-east1-a"), Status: proto.String(computepb.Zone_DOWN.String())})
+	server.AddMachineType("us-central1-a", &computepb.MachineType{Name: proto.String("n1-standard-1")})
+	server.AddMachineType("us-central1-a", &computepb.MachineType{Name: proto.String("n2d-standard-2")})
+	server.AddDiskType("us-central1-a", &computepb.DiskType{Name: proto.String("pd-standard")})
+	server.AddAcceleratorType("project", "us-central1-a", &computepb.AcceleratorType{
+		Name:                    proto.String("nvidia-tesla-t4"),
+		MaximumCardsPerInstance: proto.Int32(4),
+	})
+	server.AddImage("ubuntu-os-cloud", &computepb.Image{
+		Name:       proto.String("ubuntu-2204-v1"),
+		Family:     proto.String("ubuntu-2204-lts"),
+		DiskSizeGb: proto.Int64(10),
+	})
+	server.AddImage("ubuntu-os-cloud", &computepb.Image{
+		Name:       proto.String("ubuntu-2204-sev-v1"),
+		Family:     proto.String("ubuntu-2204-lts-sev"),
+		DiskSizeGb: proto.Int64(10),
+		GuestOsFeatures: []*computepb.GuestOsFeature{
+			{Type: proto.String(computepb.GuestOsFeature_UEFI_COMPATIBLE.String())},
+			{Type: proto.String(computepb.GuestOsFeature_SEV_CAPABLE.String())},
+		},
+	})
+	server.AddImage("cos-cloud", &computepb.Image{
+		Name:       proto.String("cos-stable-v1"),
+		Family:     proto.String("cos-stable"),
+		DiskSizeGb: proto.Int64(10),
+	})
+	server.AddSnapshot("project", &computepb.Snapshot{
+		Name:       proto.String("workspace-boot"),
+		DiskSizeGb: proto.Int64(20),
+		Status:     proto.String(computepb.Snapshot_READY.String()),
+	})
+	server.AddSnapshot("project", &computepb.Snapshot{
+		Name:       proto.String("workspace-data"),
+		DiskSizeGb: proto.Int64(100),
+		Status:     proto.String(computepb.Snapshot_CREATING.String()),
+	})
+	server.AddServiceAccount("project", &iam.ServiceAccount{Email: "registry-reader@project.iam.gserviceaccount.com"})
+	server.AddServiceAccount("project", &iam.ServiceAccount{Email: "retired@project.iam.gserviceaccount.com", Disabled: true})
+
+	return server
+}
+
+func TestValidateTargetOptions(t *testing.T) {
+	validOpts := types.TargetOptions{
+		AuthMode:    types.AuthModeApplicationDefault,
+		ProjectID:   "project",
+		Zone:        "us-central1-a",
+		MachineType: "n1-standard-1",
+		DiskType:    "pd-standard",
+		DiskSize:    20,
+		VMImage:     "projects/ubuntu-os-cloud/global/images/family/ubuntu-2204-lts",
+	}
+
+	tests := []struct {
+		name       string
+		modify     func(opts *types.TargetOptions)
+		wantFields []string
+	}{
+		{
+			name:   "Valid target options",
+			modify: func(opts *types.TargetOptions) {},
+		},
+		{
+			name: "Image by name",
+			modify: func(opts *types.TargetOptions) {
+				opts.VMImage = "projects/ubuntu-os-cloud/global/images/ubuntu-2204-v1"
+			},
+		},
+		{
+			name: "Unknown zone skips the zonal checks",
+			modify: func(opts *types.TargetOptions) {
+				opts.Zone = "us-central1-z"
+				opts.MachineType = "n9-standard-1"
+			},
+			wantFields: []string{"Zone"},
+		},
+		{
+			name: "Zone that is down",
+			modify: func(opts *types.TargetOptions) {
+				opts.Zone = "us-east1-a"
+			},
+			wantFields: []string{"Zone", "Machine Type", "Disk Type"},
+		},
+		{
+			name: "Attached GPU",
+			modify: func(opts *types.TargetOptions) {
+				opts.AcceleratorType = "nvidia-tesla-t4"
+				opts.AcceleratorCount = 4
+				opts.InstallGPUDrivers = true
+			},
+		},
+		{
+			name: "Too many GPUs",
+			modify: func(opts *types.TargetOptions) {
+				opts.AcceleratorType = "nvidia-tesla-t4"
+				opts.AcceleratorCount = 8
+			},
+			wantFields: []string{"Accelerator Count"},
+		},
+		{
+			name: "Unknown accelerator type",
+			modify: func(opts *types.TargetOptions) {
+				opts.AcceleratorType = "nvidia-tesla-k9000"
+			},
+			wantFields: []string{"Accelerator Type"},
+		},
+		{
+			name: "GPU drivers without GPUs",
+			modify: func(opts *types.TargetOptions) {
+				opts.InstallGPUDrivers = true
+				opts.AcceleratorCount = 1
+			},
+			wantFields: []string{"Install GPU Drivers"},
+		},
+		{
+			name: "GPU drivers on Container-Optimized OS",
+			modify: func(opts *types.TargetOptions) {
+				opts.AcceleratorType = "nvidia-tesla-t4"
+				opts.InstallGPUDrivers = true
+				opts.VMImage = "projects/cos-cloud/global/images/family/cos-stable"
+			},
+			wantFields: []string{"Install GPU Drivers"},
+		},
+		{
+			name: "Default accelerator count without an accelerator type",
+			modify: func(opts *types.TargetOptions) {
+				opts.AcceleratorCount = 1
+			},
+		},
+		{
+			name: "Boot disk from a snapshot skips the image",
+			modify: func(opts *types.TargetOptions) {
+				opts.SourceSnapshot = "workspace-boot"
+				opts.VMImage = "projects/ubuntu-os-cloud/global/images/family/ubuntu-9999-lts"
+			},
+		},
+		{
+			name: "Disk smaller than the snapshot",
+			modify: func(opts *types.TargetOptions) {
+				opts.SourceSnapshot = "projects/project/global/snapshots/workspace-boot"
+				opts.DiskSize = 10
+			},
+			wantFields: []string{"Disk Size"},
+		},
+		{
+			name: "Unknown snapshot",
+			modify: func(opts *types.TargetOptions) {
+				opts.SourceSnapshot = "workspace-old"
+			},
+			wantFields: []string{"Source Snapshot"},
+		},
+		{
+			name: "Data snapshot that isn't ready",
+			modify: func(opts *types.TargetOptions) {
+				opts.DataDiskSize = 50
+				opts.SourceDataSnapshot = "workspace-data"
+			},
+			wantFields: []string{"Source Data Snapshot", "Data Disk Size"},
+		},
+		{
+			name: "Data snapshot without a data disk",
+			modify: func(opts *types.TargetOptions) {
+				opts.SourceDataSnapshot = "workspace-data"
+			},
+			wantFields: []string{"Source Data Snapshot"},
+		},
+		{
+			name: "Invalid image family",
+			modify: func(opts *types.TargetOptions) {
+				opts.ImageFamily = "Daytona_Workspace"
+			},
+			wantFields: []string{"Image Family"},
+		},
+		{
+			name: "Service account",
+			modify: func(opts *types.TargetOptions) {
+				opts.ServiceAccount = "registry-reader@project.iam.gserviceaccount.com"
+			},
+		},
+		{
+			name: "No service account",
+			modify: func(opts *types.TargetOptions) {
+				opts.ServiceAccount = types.ServiceAccountNone
+			},
+		},
+		{
+			name: "Unknown service account",
+			modify: func(opts *types.TargetOptions) {
+				opts.ServiceAccount = "missing@project.iam.gserviceaccount.com"
+			},
+			wantFields: []string{"Service Account"},
+		},
+		{
+			name: "Disabled service account",
+			modify: func(opts *types.TargetOptions) {
+				opts.ServiceAccount = "retired@project.iam.gserviceaccount.com"
+			},
+			wantFields: []string{"Service Account"},
+		},
+		{
+			name: "Malformed service account",
+			modify: func(opts *types.TargetOptions) {
+				opts.ServiceAccount = "registry-reader"
+			},
+			wantFields: []string{"Service Account"},
+		},
+		{
+			name: "Shielded Confidential VM",
+			modify: func(opts *types.TargetOptions) {
+				opts.MachineType = "n2d-standard-2"
+				opts.VMImage = "projects/ubuntu-os-cloud/global/images/family/ubuntu-2204-lts-sev"
+				opts.SecureBoot = true
+				opts.VTPM = true
+				opts.IntegrityMonitoring = true
+				opts.ConfidentialVM = true
+			},
+		},
+		{
+			name: "Shielded VM on an image without UEFI",
+			modify: func(opts *types.TargetOptions) {
+				opts.VTPM = true
+			},
+			wantFields: []string{"VM Image"},
+		},
+		{
+			name: "Confidential VM on an unsupported machine type",
+			modify: func(opts *types.TargetOptions) {
+				opts.VMImage = "projects/ubuntu-os-cloud/global/images/family/ubuntu-2204-lts-sev"
+				opts.ConfidentialVM = true
+			},
+			wantFields: []string{"Confidential VM"},
+		},
+		{
+			name: "Integrity monitoring without vTPM",
+			modify: func(opts *types.TargetOptions) {
+				opts.VMImage = "projects/ubuntu-os-cloud/global/images/family/ubuntu-2204-lts-sev"
+				opts.IntegrityMonitoring = true
+			},
+			wantFields: []string{"Integrity Monitoring"},
+		},
+		{
+			name: "Secure Boot with GPU drivers",
+			modify: func(opts *types.TargetOptions) {
+				opts.VMImage = "projects/ubuntu-os-cloud/global/images/family/ubuntu-2204-lts-sev"
+				opts.SecureBoot = true
+				opts.AcceleratorType = "nvidia-tesla-t4"
+				opts.InstallGPUDrivers = true
+			},
+			wantFields: []string{"Secure Boot"},
+		},
+		{
+			name: "Labels",
+			modify: func(opts *types.TargetOptions) {
+				opts.Labels = "team=platform,cost-center=1234"
+			},
+		},
+		{
+			name: "Label keys without a letter",
+			modify: func(opts *types.TargetOptions) {
+				opts.Labels = "1234=cost-center"
+			},
+			wantFields: []string{"Labels"},
+		},
+		{
+			name: "Label keys set by the provider",
+			modify: func(opts *types.TargetOptions) {
+				opts.Labels = "managed-by=terraform"
+			},
+			wantFields: []string{"Labels"},
+		},
+		{
+			name: "Negative idle timeout",
+			modify: func(opts *types.TargetOptions) {
+				opts.IdleTimeout = -1
+			},
+			wantFields: []string{"Idle Timeout"},
+		},
+		{
+			name: "Start and stop schedule",
+			modify: func(opts *types.TargetOptions) {
+				opts.StartSchedule = "0 8 * * 1-5"
+				opts.StopSchedule = "0 20 * * 1-5"
+				opts.TimeZone = "America/New_York"
+			},
+		},
+		{
+			name: "Invalid schedules",
+			modify: func(opts *types.TargetOptions) {
+				opts.StartSchedule = "8:00"
+				opts.StopSchedule = "@daily"
+				opts.TimeZone = "Mars/Olympus_Mons"
+			},
+			wantFields: []string{"Start Schedule", "Stop Schedule", "Time Zone"},
+		},
+		{
+			name: "Time zone without a schedule",
+			modify: func(opts *types.TargetOptions) {
+				opts.TimeZone = "Europe/Berlin"
+			},
+			wantFields: []string{"Time Zone"},
+		},
+		{
+			name: "Default time zone without a schedule",
+			modify: func(opts *types.TargetOptions) {
+				opts.TimeZone = "UTC"
+			},
+		},
+		{
+			name: "Every field is reported",
+			modify: func(opts *types.TargetOptions) {
+				opts.MachineType = "a2-highgpu-1g"
+				opts.DiskType = "pd-extreme"
+				opts.VMImage = "projects/ubuntu-os-cloud/global/images/family/ubuntu-9999-lts"
+			},
+			wantFields: []string{"Machine Type", "Disk Type", "VM Image"},
+		},
+		{
+			name: "Disk smaller than the image",
+			modify: func(opts *types.TargetOptions) {
+				opts.DiskSize = 5
+			},
+			wantFields: []string{"Disk Size"},
+		},
+		{
+			name: "Malformed image",
+			modify: func(opts *types.TargetOptions) {
+				opts.VMImage = "projects/ubuntu-os-cloud"
+			},
+			wantFields: []string{"VM Image"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newValidationServer()
+			defer server.Close()
+
+			clients := NewClientManager(server.ClientOptions()...)
+			defer clients.Close()
+
+			opts := validOpts
+			tt.modify(&opts)
+
+			err := ValidateTargetOptions(context.Background(), clients, &opts)
+			if len(tt.wantFields) == 0 {
+				if err != nil {
+					t.Fatalf("Expected no error, got %s", err)
+				}
+				return
+			}
+
+			var validationErr *ValidationError
+			if !errors.As(err, &validationErr) {
+				t.Fatalf("Expected a validation error, got %v", err)
+			}
+
+			fields := []string{}
+			for _, fieldErr := range validationErr.Errors {
+				fields = append(fields, fieldErr.Field)
+			}
+			if !reflect.DeepEqual(fields, tt.wantFields) {
+				t.Errorf("Expected problems with %v, got %v", tt.wantFields, validationErr.Errors)
+			}
+		})
+	}
+}
+
+func TestValidateNetworkTargetOptions(t *testing.T) {
+	validOpts := types.TargetOptions{
+		AuthMode:    types.AuthModeApplicationDefault,
+		ProjectID:   "project",
+		Zone:        "us-central1-a",
+		MachineType: "n1-standard-1",
+		DiskType:    "pd-standard",
+		DiskSize:    20,
+		VMImage:     "projects/ubuntu-os-cloud/global/images/family/ubuntu-2204-lts",
+	}
+
+	tests := []struct {
+		name       string
+		modify     func(opts *types.TargetOptions)
+		wantFields []string
+	}{
+		{
+			name: "Shared VPC subnetwork",
+			modify: func(opts *types.TargetOptions) {
+				opts.Network = "shared"
+				opts.Subnetwork = "workspaces"
+				opts.NetworkProjectID = "host-project"
+			},
+		},
+		{
+			name: "Subnetwork path",
+			modify: func(opts *types.TargetOptions) {
+				opts.Subnetwork = "projects/host-project/regions/us-central1/subnetworks/workspaces"
+			},
+		},
+		{
+			name: "Unknown network",
+			modify: func(opts *types.TargetOptions) {
+				opts.Network = "missing"
+			},
+			wantFields: []string{"Network"},
+		},
+		{
+			name: "Subnetwork in another region",
+			modify: func(opts *types.TargetOptions) {
+				opts.Subnetwork = "projects/host-project/regions/europe-west1/subnetworks/workspaces"
+			},
+			wantFields: []string{"Subnetwork"},
+		},
+		{
+			name: "Subnetwork of another network",
+			modify: func(opts *types.TargetOptions) {
+				opts.Network = "default"
+				opts.Subnetwork = "projects/host-project/regions/us-central1/subnetworks/workspaces"
+			},
+			wantFields: []string{"Subnetwork"},
+		},
+		{
+			name: "Reserved address by name",
+			modify: func(opts *types.TargetOptions) {
+				opts.ExternalIPAddress = "workspace-ip"
+			},
+		},
+		{
+			name: "Reserved address by IP",
+			modify: func(opts *types.TargetOptions) {
+				opts.ExternalIPAddress = "203.0.113.10"
+			},
+		},
+		{
+			name: "Address that is in use",
+			modify: func(opts *types.TargetOptions) {
+				opts.ExternalIPAddress = "used-ip"
+			},
+			wantFields: []string{"External IP Address"},
+		},
+		{
+			name: "Invalid network tags",
+			modify: func(opts *types.TargetOptions) {
+				opts.NetworkTags = "dev, Allow_SSH"
+			},
+			wantFields: []string{"Network Tags"},
+		},
+		{
+			name: "Static address without an external IP",
+			modify: func(opts *types.TargetOptions) {
+				opts.AssignExternalIP = proto.Bool(false)
+				opts.ExternalIPAddress = "workspace-ip"
+			},
+			wantFields: []string{"External IP Address"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newValidationServer()
+			defer server.Close()
+
+			server.AddNetwork("project", &computepb.Network{Name: proto.String("default")})
+			server.AddNetwork("host-project", &computepb.Network{Name: proto.String("shared")})
+			server.AddSubnetwork("host-project", "us-central1", &computepb.Subnetwork{
+				Name:    proto.String("workspaces"),
+				Network: proto.String("projects/host-project/global/networks/shared"),
+			})
+			server.AddAddress("project", "us-central1", &computepb.Address{
+				Name:        proto.String("workspace-ip"),
+				Address:     proto.String("203.0.113.10"),
+				AddressType: proto.String(computepb.Address_EXTERNAL.String()),
+				Status:      proto.String(computepb.Address_RESERVED.String()),
+			})
+			server.AddAddress("project", "us-central1", &computepb.Address{
+				Name:        proto.String("used-ip"),
+				Address:     proto.String("203.0.113.11"),
+				AddressType: proto.String(computepb.Address_EXTERNAL.String()),
+				Status:      proto.String(computepb.Address_IN_USE.String()),
+			})
+
+			clients := NewClientManager(server.ClientOptions()...)
+			defer clients.Close()
+
+			opts := validOpts
+			tt.modify(&opts)
+
+			err := ValidateTargetOptions(context.Background(), clients, &opts)
+			if len(tt.wantFields) == 0 {
+				if err != nil {
+					t.Fatalf("Expected no error, got %s", err)
+				}
+				return
+			}
+
+			var validationErr *ValidationError
+			if !errors.As(err, &validationErr) {
+				t.Fatalf("Expected a validation error, got %v", err)
+			}
+
+			fields := []string{}
+			for _, fieldErr := range validationErr.Errors {
+				fields = append(fields, fieldErr.Field)
+			}
+			if !reflect.DeepEqual(fields, tt.wantFields) {
+				t.Errorf("Expected problems with %v, got %v", tt.wantFields, validationErr.Errors)
+			}
+		})
+	}
+}
+
+func TestValidateDefaultTargetOptions(t *testing.T) {
+	server := newValidationServer()
+	defer server.Close()
+	server.AddNetwork("project", &computepb.Network{Name: proto.String("default")})
+
+	clients := NewClientManager(server.ClientOptions()...)
+	defer clients.Close()
+
+	defaults := map[string]interface{}{}
+	for name, property := range *types.GetTargetManifest() {
+		if property.DefaultValue == "" {
+			continue
+		}
+
+		switch property.Type {
+		case provider.ProviderTargetPropertyTypeInt:
+			value, err := strconv.Atoi(property.DefaultValue)
+			if err != nil {
+				t.Fatalf("Expected an int default value of %s, got %q", name, property.DefaultValue)
+			}
+			defaults[name] = value
+		case provider.ProviderTargetPropertyTypeBoolean:
+			defaults[name] = property.DefaultValue == "true"
+		default:
+			defaults[name] = property.DefaultValue
+		}
+	}
+
+	defaultsJson, err := json.Marshal(defaults)
+	if err != nil {
+		t.Fatalf("Error marshalling the default values: %s", err)
+	}
+
+	opts := types.TargetOptions{}
+	err = json.Unmarshal(defaultsJson, &opts)
+	if err != nil {
+		t.Fatalf("Error unmarshalling the default values: %s", err)
+	}
+	opts.AuthMode = types.AuthModeApplicationDefault
+	opts.ProjectID = "project"
+
+	err = ValidateTargetOptions(context.Background(), clients, &opts)
+	if err != nil {
+		t.Errorf("Expected the default target options to be valid, got %s", err)
+	}
+}
@@ -0,0 +1,61 @@
+package startupscript
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+var envVarNamePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// systemdEscaper escapes the characters that are special inside a double quoted value of a systemd EnvironmentFile.
+var systemdEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "`", "\\`", `$`, `\$`)
+
+// shellQuote quotes a value as a single quoted shell word. Nothing is special inside single quotes, so a single quote
+// in the value only needs to close the quoting, be escaped with a backslash and reopen the quoting.
+func shellQuote(value string) string {
+	return "'" + strings.ReplaceAll(value, "'", `'\''`) + "'"
+}
+
+// systemdQuote quotes a value for a systemd EnvironmentFile. Double quoted values keep newlines and only \, ", ` and $
+// are escaped with a backslash.
+func systemdQuote(value string) string {
+	return `"` + systemdEscaper.Replace(value) + `"`
+}
+
+// validateEnvVar checks that an environment variable can be passed through a shell and a systemd EnvironmentFile.
+// Neither can carry NUL bytes and systemd ignores values that aren't valid UTF-8.
+func validateEnvVar(name, value string) error {
+	if !envVarNamePattern.MatchString(name) {
+		return fmt.Errorf("invalid environment variable name %q", name)
+	}
+	if strings.ContainsRune(value, 0) {
+		return fmt.Errorf("environment variable %s contains a NUL byte", name)
+	}
+	if !utf8.ValidString(value) {
+		return fmt.Errorf("environment variable %s is not valid UTF-8", name)
+	}
+
+	return nil
+}
+
+// renderEnvScript renders the environment variables as a shell script that exports them.
+func renderEnvScript(envVars []envVar) string {
+	content := &strings.Builder{}
+	for _, envVar := range envVars {
+		content.WriteString("export " + envVar.Name + "=" + shellQuote(envVar.Value) + "\n")
+	}
+
+	return content.String()
+}
+
+// renderEnvFile renders the environment variables as the content of a systemd EnvironmentFile.
+func renderEnvFile(envVars []envVar) string {
+	content := &strings.Builder{}
+	for _, envVar := range envVars {
+		content.WriteString(envVar.Name + "=" + systemdQuote(envVar.Value) + "\n")
+	}
+
+	return content.String()
+}
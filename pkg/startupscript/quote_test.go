@@ -0,0 +1,164 @@
+package startupscript
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+var quoteSeeds = []string{
+	"",
+	"plain",
+	"with spaces",
+	"it's",
+	`"double" quotes`,
+	"$HOME ${PATH} $(id) `id`",
+	`back\slash\`,
+	"multi\nline\ntoken\n",
+	"'; rm -rf / #",
+	"\"\nEnvironment=INJECTED=1\n",
+	"trailing backslash\\",
+	"tab\tand\rcarriage return",
+	"ünïcödé ✓",
+	"\xff\xfe invalid utf-8",
+}
+
+// runBash runs the script with bash and returns its stdout.
+func runBash(t *testing.T, script string) string {
+	bash, err := exec.LookPath("bash")
+	if err != nil {
+		t.Skip("bash is not available")
+	}
+
+	output, err := exec.Command(bash, "-c", script).Output()
+	if err != nil {
+		t.Fatalf("Error running %q: %s", script, err)
+	}
+
+	return string(output)
+}
+
+func FuzzShellQuote(f *testing.F) {
+	for _, seed := range quoteSeeds {
+		f.Add(seed)
+	}
+
+	f.Fuzz(func(t *testing.T, value string) {
+		// Shell words can't hold NUL bytes
+		if strings.ContainsRune(value, 0) {
+			t.Skip()
+		}
+
+		output := runBash(t, "printf '%s' "+shellQuote(value))
+		if output != value {
+			t.Errorf("Expected %q to round trip through bash, got %q", value, output)
+		}
+	})
+}
+
+// systemdQuoteFixtures pairs values with EnvironmentFile lines that systemd parses back into them. Inside double quotes
+// parse_env_file of systemd drops a backslash before \, ", ` and $, joins lines on a backslash before a newline and
+// keeps any other character, newlines included, as is.
+var systemdQuoteFixtures = []struct {
+	value string
+	line  string
+}{
+	{"", `TOKEN=""`},
+	{"plain", `TOKEN="plain"`},
+	{"with spaces", `TOKEN="with spaces"`},
+	{"it's", `TOKEN="it's"`},
+	{`"double" quotes`, `TOKEN="\"double\" quotes"`},
+	{"$HOME ${PATH} $(id) `id`", "TOKEN=\"\\$HOME \\${PATH} \\$(id) \\`id\\`\""},
+	{`back\slash\`, `TOKEN="back\\slash\\"`},
+	{"multi\nline\ntoken\n", "TOKEN=\"multi\nline\ntoken\n\""},
+	{"'; rm -rf / #", `TOKEN="'; rm -rf / #"`},
+	{"\"\nEnvironment=INJECTED=1\n", "TOKEN=\"\\\"\nEnvironment=INJECTED=1\n\""},
+	{"trailing backslash\\", `TOKEN="trailing backslash\\"`},
+	{"tab\tand\rcarriage return", "TOKEN=\"tab\tand\rcarriage return\""},
+	{"ünïcödé ✓", `TOKEN="ünïcödé ✓"`},
+}
+
+func TestSystemdQuote(t *testing.T) {
+	for _, fixture := range systemdQuoteFixtures {
+		envFile := renderEnvFile([]envVar{{Name: "TOKEN", Value: fixture.value}})
+		if envFile != fixture.line+"\n" {
+			t.Errorf("Expected %q to be rendered as %q, got %q", fixture.value, fixture.line+"\n", envFile)
+		}
+	}
+}
+
+// runSystemd runs env in a transient systemd service that reads the EnvironmentFile and returns its output.
+func runSystemd(t *testing.T, envFile string) string {
+	systemdRun, err := exec.LookPath("systemd-run")
+	if err != nil {
+		t.Skip("systemd-run is not available")
+	}
+	if output, _ := exec.Command("systemctl", "is-system-running").Output(); strings.TrimSpace(string(output)) == "offline" {
+		t.Skip("systemd is not running")
+	}
+
+	path := filepath.Join(t.TempDir(), "env")
+	if err := os.WriteFile(path, []byte(envFile), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	output, err := exec.Command(systemdRun, "--quiet", "--pipe", "--wait", "--property=EnvironmentFile="+path, "env", "-0").Output()
+	if err != nil {
+		t.Skipf("Error running systemd-run: %s", err)
+	}
+
+	return string(output)
+}
+
+func FuzzSystemdQuote(f *testing.F) {
+	for _, seed := range quoteSeeds {
+		f.Add("TOKEN", seed)
+	}
+
+	f.Fuzz(func(t *testing.T, name, value string) {
+		if validateEnvVar(name, value) != nil {
+			t.Skip()
+		}
+
+		envFile := renderEnvFile([]envVar{{Name: "BEFORE", Value: "1"}, {Name: name, Value: value}, {Name: "AFTER", Value: "2"}})
+		output := runSystemd(t, envFile)
+
+		for _, want := range []string{"BEFORE=1", name + "=" + value, "AFTER=2"} {
+			if !slices.Contains(strings.Split(output, "\x00"), want) {
+				t.Errorf("Expected systemd to parse %q from %q, got %q", want, envFile, output)
+			}
+		}
+	})
+}
+
+func FuzzBuildEnv(f *testing.F) {
+	for _, seed := range quoteSeeds {
+		f.Add("", seed)
+	}
+	f.Add("; id", "value")
+	f.Add("-X", "value")
+	f.Add("", "a\x00b")
+
+	f.Fuzz(func(t *testing.T, suffix, value string) {
+		// The prefix keeps clear of the variables bash sets itself, like _ or RANDOM
+		name := "DAYTONA_TEST" + suffix
+		script, err := NewBuilder(Config{Distro: DistroApt, EnvVars: map[string]string{name: value}}).Enable(StageEnv).Build()
+		if validateEnvVar(name, value) != nil {
+			if err == nil {
+				t.Errorf("Expected environment variable %q=%q to be rejected", name, value)
+			}
+			return
+		}
+		if err != nil {
+			t.Fatalf("Error building the script: %s", err)
+		}
+
+		output := runBash(t, script.Secrets[SecretsMetadataPrefix+"env"]+`printf '%s' "$`+name+`"`)
+		if output != value {
+			t.Errorf("Expected %q to be exported as %q, got %q", name, value, output)
+		}
+	})
+}
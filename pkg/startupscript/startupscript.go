@@ -0,0 +1,298 @@
+// Package startupscript renders the startup script that sets up workspace VMs. The script is made of stages that can be
+// enabled one by one and are rendered from templates for the package manager of the VM image.
+package startupscript
+
+import (
+	"embed"
+	"fmt"
+	"maps"
+	"slices"
+	"sort"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// Distro is the family of the VM image, which decides how packages are installed.
+type Distro string
+
+const (
+	DistroApt Distro = "apt"
+	DistroDnf Distro = "dnf"
+	// DistroCOS is Container-Optimized OS, which ships with Docker and has no package manager.
+	DistroCOS Distro = "cos"
+)
+
+// Stage is a step of the startup script.
+type Stage string
+
+const (
+	// StageMountDataDisk formats the data disk on first use and mounts it at /home/daytona.
+	StageMountDataDisk Stage = "mount-data-disk"
+	// StageSetup creates the daytona user and installs and configures Docker, unless the VM runs a prebaked image.
+	StageSetup Stage = "setup"
+	// StageOwnDataDisk hands the data disk to the daytona user, whose ID can change when the disk is reattached to a new VM.
+	StageOwnDataDisk Stage = "own-data-disk"
+	// StageGPUDrivers installs the NVIDIA drivers and makes the GPUs available to Docker.
+	StageGPUDrivers Stage = "gpu-drivers"
+	// StageSecrets fetches the secrets from the instance metadata on the first boot of the instance. It is enabled with any
+	// stage that uses the secrets.
+	StageSecrets Stage = "secrets"
+	// StageEnv exports the workspace environment variables for the init script.
+	StageEnv Stage = "env"
+	// StageInit runs the init script, which installs the Daytona binary.
+	StageInit Stage = "init"
+	// StageAgent installs and starts the Daytona agent service.
+	StageAgent Stage = "agent"
+	// StageIdleWatchdog stops the VM once it has been idle for the idle timeout.
+	StageIdleWatchdog Stage = "idle-watchdog"
+	// StageBakeImage marks the VM as prebaked and shuts it down, so that its boot disk can be imaged.
+	StageBakeImage Stage = "bake-image"
+)
+
+// stages are all the stages, in the order they run in.
+var stages = []Stage{
+	StageMountDataDisk,
+	StageSetup,
+	StageOwnDataDisk,
+	StageGPUDrivers,
+	StageSecrets,
+	StageEnv,
+	StageInit,
+	StageAgent,
+	StageIdleWatchdog,
+	StageBakeImage,
+}
+
+// BakedImageMarker is created on prebaked images, which makes the setup stage a no-op.
+const BakedImageMarker = "/var/lib/daytona/image-baked"
+
+// SecretsMetadataPrefix is the prefix of the instance metadata items that hold the secrets. They are only needed on the
+// first boot of the instance, so they should be deleted once the agent connected.
+const SecretsMetadataPrefix = "daytona-secret-"
+
+// LastActivityGuestAttribute is the guest attribute the idle watchdog publishes the Unix time of the last activity on
+// the VM in, as <namespace>/<key>.
+const LastActivityGuestAttribute = "daytona/last-activity"
+
+// SetupFailedGuestAttribute is the guest attribute a script with ExitOnError publishes the exit status of the failed
+// command in, as <namespace>/<key>.
+const SetupFailedGuestAttribute = "daytona/setup-failed"
+
+// idleDir keeps the state of the idle watchdog.
+const idleDir = "/var/lib/daytona/idle"
+
+// idleCPUThreshold is the CPU usage, in percent of all CPUs, that counts as activity.
+const idleCPUThreshold = 10
+
+// SecretsDir is the root-only directory the secrets are kept in after the first boot.
+const SecretsDir = "/var/lib/daytona/secrets"
+
+const (
+	envFile        = "env.sh"
+	agentEnvFile   = "agent.env"
+	initScriptFile = "init.sh"
+)
+
+type secretFile struct {
+	Key  string
+	File string
+}
+
+// secretFiles maps the secret metadata items to the files they are kept in.
+var secretFiles = []secretFile{
+	{Key: SecretsMetadataPrefix + "env", File: envFile},
+	{Key: SecretsMetadataPrefix + "agent-env", File: agentEnvFile},
+	{Key: SecretsMetadataPrefix + "init-script", File: initScriptFile},
+}
+
+//go:embed templates/*.sh.tmpl
+var templateFS embed.FS
+
+var templates = template.Must(template.New("").Funcs(template.FuncMap{
+	"shellQuote": shellQuote,
+}).ParseFS(templateFS, "templates/*.sh.tmpl"))
+
+// Config holds the values the stages are rendered with.
+type Config struct {
+	Distro Distro
+	// ExitOnError makes the script stop at the first failing command and publish its exit status in the
+	// SetupFailedGuestAttribute guest attribute.
+	ExitOnError bool
+	// DataDiskDevice is the device name of the data disk, used by the data disk stages.
+	DataDiskDevice string
+	// EnvVars are the workspace environment variables, used by the env and agent stages. Names must be valid shell
+	// variable names and values valid UTF-8 without NUL bytes. They are delivered as secrets.
+	EnvVars map[string]string
+	// InitScript is the script run by the init stage. It is delivered as a secret.
+	InitScript string
+	// IdleTimeout is the time without activity after which the idle watchdog stage stops the VM.
+	IdleTimeout time.Duration
+}
+
+type envVar struct {
+	Name  string
+	Value string
+}
+
+type templateData struct {
+	Distro           Distro
+	DataDiskDevice   string
+	EnvVars          []envVar
+	BakedImageMarker string
+	SecretsDir       string
+	SecretFiles      []secretFile
+	EnvFile          string
+	AgentEnvFile     string
+	InitScriptFile   string
+
+	IdleTimeoutSeconds         int
+	IdleDir                    string
+	IdleCPUThreshold           int
+	LastActivityGuestAttribute string
+	SetupFailedGuestAttribute  string
+}
+
+// Script is a rendered startup script.
+type Script struct {
+	// StartupScript is the startup-script metadata item. It holds no secrets.
+	StartupScript string
+	// Secrets are the metadata items the startup script fetches the secrets from, keyed by metadata key.
+	Secrets map[string]string
+	// GuestAttributes tells whether the script writes guest attributes, which have to be enabled on the VM.
+	GuestAttributes bool
+}
+
+// Builder renders a startup script from its enabled stages.
+type Builder struct {
+	config  Config
+	enabled map[Stage]bool
+}
+
+// NewBuilder creates a builder without any enabled stages.
+func NewBuilder(config Config) *Builder {
+	return &Builder{
+		config:  config,
+		enabled: map[Stage]bool{},
+	}
+}
+
+// Enable enables the stages. Stages always run in the same order, no matter the order they are enabled in.
+func (b *Builder) Enable(stages ...Stage) *Builder {
+	for _, stage := range stages {
+		b.enabled[stage] = true
+	}
+
+	return b
+}
+
+// Disable disables the stages.
+func (b *Builder) Disable(stages ...Stage) *Builder {
+	for _, stage := range stages {
+		delete(b.enabled, stage)
+	}
+
+	return b
+}
+
+// Build renders the startup script and the secrets it fetches.
+func (b *Builder) Build() (*Script, error) {
+	if !slices.Contains([]Distro{DistroApt, DistroDnf, DistroCOS}, b.config.Distro) {
+		return nil, fmt.Errorf("unsupported distro %q", b.config.Distro)
+	}
+
+	envVars := []envVar{}
+	for name, value := range b.config.EnvVars {
+		err := validateEnvVar(name, value)
+		if err != nil {
+			return nil, err
+		}
+		envVars = append(envVars, envVar{Name: name, Value: value})
+	}
+	sort.Slice(envVars, func(i, j int) bool {
+		return envVars[i].Name < envVars[j].Name
+	})
+
+	enabled := maps.Clone(b.enabled)
+	if enabled[StageEnv] || enabled[StageInit] || enabled[StageAgent] {
+		enabled[StageSecrets] = true
+	}
+
+	// Container-Optimized OS has no NVIDIA Container Toolkit to make the GPUs available to Docker
+	if enabled[StageGPUDrivers] && b.config.Distro == DistroCOS {
+		return nil, fmt.Errorf("the GPU drivers can't be installed on %s", DistroCOS)
+	}
+
+	if enabled[StageIdleWatchdog] && b.config.IdleTimeout < time.Minute {
+		return nil, fmt.Errorf("the idle watchdog requires an idle timeout of at least a minute, got %s", b.config.IdleTimeout)
+	}
+
+	result := &Script{
+		GuestAttributes: enabled[StageIdleWatchdog] || b.config.ExitOnError,
+	}
+	if enabled[StageSecrets] {
+		result.Secrets = map[string]string{
+			SecretsMetadataPrefix + "env":         renderEnvScript(envVars),
+			SecretsMetadataPrefix + "agent-env":   renderEnvFile(envVars),
+			SecretsMetadataPrefix + "init-script": b.config.InitScript,
+		}
+	}
+
+	data := templateData{
+		Distro:           b.config.Distro,
+		DataDiskDevice:   b.config.DataDiskDevice,
+		BakedImageMarker: BakedImageMarker,
+		SecretsDir:       SecretsDir,
+		SecretFiles:      secretFiles,
+		EnvFile:          envFile,
+		AgentEnvFile:     agentEnvFile,
+		InitScriptFile:   initScriptFile,
+
+		IdleTimeoutSeconds:         int(b.config.IdleTimeout.Seconds()),
+		IdleDir:                    idleDir,
+		IdleCPUThreshold:           idleCPUThreshold,
+		LastActivityGuestAttribute: LastActivityGuestAttribute,
+		SetupFailedGuestAttribute:  SetupFailedGuestAttribute,
+	}
+
+	script := &strings.Builder{}
+	script.WriteString("#!/bin/bash\n")
+	if b.config.ExitOnError {
+		err := templates.ExecuteTemplate(script, "exit-on-error.sh.tmpl", data)
+		if err != nil {
+			return nil, fmt.Errorf("failed to render the error handling: %w", err)
+		}
+	}
+
+	for _, stage := range stages {
+		if !enabled[stage] {
+			continue
+		}
+
+		err := templates.ExecuteTemplate(script, string(stage)+".sh.tmpl", data)
+		if err != nil {
+			return nil, fmt.Errorf("failed to render the %s stage: %w", stage, err)
+		}
+	}
+	result.StartupScript = script.String()
+
+	return result, nil
+}
+
+// DistroForImage guesses the distro of a VM image from its project and name, e.g. projects/cos-cloud/global/images/family/cos-stable.
+// Images that aren't recognized are assumed to be Debian based.
+func DistroForImage(image string) Distro {
+	image = strings.ToLower(image)
+	switch {
+	case strings.Contains(image, "cos-cloud/"):
+		return DistroCOS
+	case strings.Contains(image, "rhel"),
+		strings.Contains(image, "rocky"),
+		strings.Contains(image, "centos"),
+		strings.Contains(image, "almalinux"),
+		strings.Contains(image, "fedora"):
+		return DistroDnf
+	default:
+		return DistroApt
+	}
+}
@@ -0,0 +1,259 @@
+package startupscript
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+var update = flag.Bool("update", false, "update the golden files in testdata")
+
+var workspaceStages = []Stage{StageMountDataDisk, StageSetup, StageOwnDataDisk, StageGPUDrivers, StageSecrets, StageEnv, StageInit, StageAgent}
+
+func TestBuildGolden(t *testing.T) {
+	envVars := map[string]string{
+		"DAYTONA_WORKSPACE_ID":        "123",
+		"DAYTONA_AGENT_LOG_FILE_PATH": "/home/daytona/.daytona-agent.log",
+	}
+	initScript := `curl -sfL -H "Authorization: Bearer api-key" https://download.daytona.io/daytona/install.sh | bash`
+
+	tests := []struct {
+		name   string
+		config Config
+		stages []Stage
+	}{
+		{
+			name:   "apt-workspace",
+			config: Config{Distro: DistroApt, DataDiskDevice: "daytona-data", EnvVars: envVars, InitScript: initScript},
+			stages: workspaceStages,
+		},
+		{
+			name:   "apt-minimal",
+			config: Config{Distro: DistroApt, EnvVars: envVars, InitScript: initScript},
+			stages: []Stage{StageSetup, StageEnv, StageInit, StageAgent},
+		},
+		{
+			name:   "dnf-workspace",
+			config: Config{Distro: DistroDnf, DataDiskDevice: "daytona-data", EnvVars: envVars, InitScript: initScript},
+			stages: workspaceStages,
+		},
+		{
+			name:   "cos-workspace",
+			config: Config{Distro: DistroCOS, DataDiskDevice: "daytona-data", EnvVars: envVars, InitScript: initScript},
+			stages: slices.DeleteFunc(slices.Clone(workspaceStages), func(stage Stage) bool { return stage == StageGPUDrivers }),
+		},
+		{
+			name:   "apt-idle-watchdog",
+			config: Config{Distro: DistroApt, EnvVars: envVars, InitScript: initScript, IdleTimeout: 30 * time.Minute},
+			stages: []Stage{StageSetup, StageEnv, StageInit, StageAgent, StageIdleWatchdog},
+		},
+		{
+			name:   "apt-image-builder",
+			config: Config{Distro: DistroApt, ExitOnError: true},
+			stages: []Stage{StageSetup, StageBakeImage},
+		},
+		{
+			name:   "dnf-image-builder",
+			config: Config{Distro: DistroDnf, ExitOnError: true},
+			stages: []Stage{StageSetup, StageBakeImage},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			script, err := NewBuilder(tt.config).Enable(tt.stages...).Build()
+			if err != nil {
+				t.Fatalf("Error building the script: %s", err)
+			}
+
+			golden := filepath.Join("testdata", tt.name+".golden")
+			if *update {
+				err = os.WriteFile(golden, []byte(script.StartupScript), 0644)
+				if err != nil {
+					t.Fatalf("Error updating %s: %s", golden, err)
+				}
+			}
+
+			want, err := os.ReadFile(golden)
+			if err != nil {
+				t.Fatalf("Error reading %s: %s", golden, err)
+			}
+			if script.StartupScript != string(want) {
+				t.Errorf("Script differs from %s, run the tests with -update to review the changes:\n%s", golden, script.StartupScript)
+			}
+		})
+	}
+}
+
+func TestBuildSecrets(t *testing.T) {
+	config := Config{
+		Distro:     DistroApt,
+		EnvVars:    map[string]string{"DAYTONA_SERVER_API_KEY": "secret-api-key"},
+		InitScript: "echo init-script-token",
+	}
+
+	script, err := NewBuilder(config).Enable(StageSetup, StageAgent).Build()
+	if err != nil {
+		t.Fatalf("Error building the script: %s", err)
+	}
+
+	for _, secret := range []string{"secret-api-key", "init-script-token"} {
+		if strings.Contains(script.StartupScript, secret) {
+			t.Errorf("Expected the startup script not to contain %q", secret)
+		}
+	}
+	if !strings.Contains(script.StartupScript, "instance/attributes/"+SecretsMetadataPrefix+"agent-env") {
+		t.Errorf("Expected the agent stage to enable the secrets stage")
+	}
+
+	expected := map[string]string{
+		SecretsMetadataPrefix + "env":         "export DAYTONA_SERVER_API_KEY='secret-api-key'\n",
+		SecretsMetadataPrefix + "agent-env":   "DAYTONA_SERVER_API_KEY=\"secret-api-key\"\n",
+		SecretsMetadataPrefix + "init-script": "echo init-script-token",
+	}
+	if len(script.Secrets) != len(expected) {
+		t.Errorf("Expected %d secrets, got %d", len(expected), len(script.Secrets))
+	}
+	for key, value := range expected {
+		if script.Secrets[key] != value {
+			t.Errorf("Expected secret %s to be %q, got %q", key, value, script.Secrets[key])
+		}
+	}
+
+	setupOnly, err := NewBuilder(config).Enable(StageSetup).Build()
+	if err != nil {
+		t.Fatalf("Error building the script: %s", err)
+	}
+	if setupOnly.Secrets != nil || strings.Contains(setupOnly.StartupScript, SecretsMetadataPrefix) {
+		t.Errorf("Expected no secrets without a stage that uses them")
+	}
+}
+
+func TestBuildStageOrder(t *testing.T) {
+	config := Config{Distro: DistroApt, InitScript: "echo init"}
+
+	reversed := make([]Stage, len(workspaceStages))
+	for i, stage := range workspaceStages {
+		reversed[len(workspaceStages)-1-i] = stage
+	}
+
+	want, err := NewBuilder(config).Enable(workspaceStages...).Build()
+	if err != nil {
+		t.Fatalf("Error building the script: %s", err)
+	}
+	got, err := NewBuilder(config).Enable(reversed...).Build()
+	if err != nil {
+		t.Fatalf("Error building the script: %s", err)
+	}
+	if got.StartupScript != want.StartupScript {
+		t.Errorf("Expected the stages to run in the same order no matter the order they are enabled in")
+	}
+
+	disabled, err := NewBuilder(config).Enable(workspaceStages...).Disable(workspaceStages...).Build()
+	if err != nil {
+		t.Fatalf("Error building the script: %s", err)
+	}
+	if disabled.StartupScript != "#!/bin/bash\n" || disabled.Secrets != nil {
+		t.Errorf("Expected only the shebang without stages, got:\n%s", disabled.StartupScript)
+	}
+}
+
+func TestBuildIdleWatchdogWithoutTimeout(t *testing.T) {
+	_, err := NewBuilder(Config{Distro: DistroApt}).Enable(StageIdleWatchdog).Build()
+	if err == nil {
+		t.Fatalf("Expected the idle watchdog without an idle timeout to fail")
+	}
+}
+
+func TestIdleWatchdogDaytonaSessions(t *testing.T) {
+	script, err := NewBuilder(Config{Distro: DistroApt, IdleTimeout: 30 * time.Minute}).Enable(StageIdleWatchdog).Build()
+	if err != nil {
+		t.Fatalf("Error building the script: %s", err)
+	}
+
+	start := strings.Index(script.StartupScript, "has_daytona_session() {")
+	end := strings.Index(script.StartupScript[start:], "\n}\n")
+	if start < 0 || end < 0 {
+		t.Fatalf("Expected the watchdog to check for Daytona sessions, got:\n%s", script.StartupScript)
+	}
+	hasDaytonaSession := script.StartupScript[start : start+end+3]
+
+	tests := []struct {
+		name      string
+		processes string
+		want      bool
+	}{
+		{
+			name:      "Idle agents",
+			processes: "1 0 systemd\n812 1 daytona\n2301 2280 daytona\n",
+		},
+		{
+			name:      "Shell of an SSH session",
+			processes: "1 0 systemd\n812 1 daytona\n2301 2280 daytona\n2417 2301 bash\n",
+			want:      true,
+		},
+		{
+			name:      "IDE server",
+			processes: "1 0 systemd\n812 1 daytona\n2301 2280 daytona\n2533 2301 node\n",
+			want:      true,
+		},
+		{
+			name:      "Agent started by an agent",
+			processes: "1 0 systemd\n812 1 daytona\n2301 812 daytona\n",
+		},
+		{
+			name:      "Without agents",
+			processes: "1 0 systemd\n2417 1 bash\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// ps is replaced by a function printing the processes as pid, ppid and command
+			output := runBash(t, "ps() { printf '"+tt.processes+"'; }\n"+hasDaytonaSession+"has_daytona_session && echo active || echo idle")
+			if got := output == "active\n"; got != tt.want {
+				t.Errorf("Expected a Daytona session to be %v, got %q", tt.want, output)
+			}
+		})
+	}
+}
+
+func TestBuildGPUDriversOnCOS(t *testing.T) {
+	_, err := NewBuilder(Config{Distro: DistroCOS}).Enable(StageGPUDrivers).Build()
+	if err == nil {
+		t.Fatalf("Expected the GPU drivers on Container-Optimized OS to fail")
+	}
+}
+
+func TestBuildUnsupportedDistro(t *testing.T) {
+	_, err := NewBuilder(Config{Distro: "pacman"}).Enable(StageSetup).Build()
+	if err == nil {
+		t.Fatalf("Expected an unsupported distro to fail")
+	}
+}
+
+func TestDistroForImage(t *testing.T) {
+	tests := []struct {
+		image string
+		want  Distro
+	}{
+		{image: "projects/ubuntu-os-cloud/global/images/family/ubuntu-2204-lts", want: DistroApt},
+		{image: "projects/debian-cloud/global/images/family/debian-12", want: DistroApt},
+		{image: "projects/rocky-linux-cloud/global/images/family/rocky-linux-9", want: DistroDnf},
+		{image: "projects/rhel-cloud/global/images/family/rhel-9", want: DistroDnf},
+		{image: "projects/cos-cloud/global/images/family/cos-stable", want: DistroCOS},
+		{image: "projects/my-project/global/images/custom", want: DistroApt},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.image, func(t *testing.T) {
+			if got := DistroForImage(tt.image); got != tt.want {
+				t.Errorf("DistroForImage(%q) = %q, want %q", tt.image, got, tt.want)
+			}
+		})
+	}
+}
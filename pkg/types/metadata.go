@@ -1,15 +1,54 @@
 package types
 
 import (
+	"path"
+	"strconv"
+
 	"cloud.google.com/go/compute/apiv1/computepb"
 )
 
+// SourceSnapshotMetadataKey is the instance metadata item that records the snapshot the boot disk was restored from.
+const SourceSnapshotMetadataKey = "daytona-source-snapshot"
+
+// IdleTimeoutMetadataKey is the instance metadata item that records the idle timeout of the VM in minutes.
+const IdleTimeoutMetadataKey = "daytona-idle-timeout"
+
+// DataDiskDeviceName is the device name of the data disk, which the startup script finds at /dev/disk/by-id/google-<device name>.
+const DataDiskDeviceName = "daytona-data"
+
 type WorkspaceMetadata struct {
 	VirtualMachineId   uint64
 	VirtualMachineName string
 	Platform           string
 	Location           string
 	Created            string
+	ProvisioningModel  string
+	Preempted          bool
+	DataDisk           string
+	SourceSnapshot     string
+	ServiceAccount     string
+	Labels             map[string]string
+	Security           SecurityPosture
+	// IdleTimeout is the idle timeout of the VM in minutes, 0 if it isn't stopped when idle.
+	IdleTimeout int
+	// AutoStopAt is the time the VM will be stopped at if it stays idle, empty if it isn't running or the time is unknown.
+	AutoStopAt string
+	Schedule   InstanceSchedule
+}
+
+// InstanceSchedule reports the cron schedules the instance is started and stopped on, which are empty if it has none.
+type InstanceSchedule struct {
+	Start    string
+	Stop     string
+	TimeZone string
+}
+
+// SecurityPosture reports the Shielded VM and Confidential VM features of the instance.
+type SecurityPosture struct {
+	SecureBoot          bool
+	VTPM                bool
+	IntegrityMonitoring bool
+	ConfidentialVM      bool
 }
 
 // ToWorkspaceMetadata converts and maps values from an *computepb.Instance to a WorkspaceMetadata.
@@ -20,5 +59,69 @@ func ToWorkspaceMetadata(vm *computepb.Instance) WorkspaceMetadata {
 		Platform:           vm.GetCpuPlatform(),
 		Location:           vm.GetZone(),
 		Created:            vm.GetCreationTimestamp(),
+		ProvisioningModel:  GetInstanceProvisioningModel(vm),
+		DataDisk:           getDataDisk(vm),
+		SourceSnapshot:     getMetadataItem(vm, SourceSnapshotMetadataKey),
+		ServiceAccount:     getServiceAccount(vm),
+		Labels:             vm.GetLabels(),
+		Security: SecurityPosture{
+			SecureBoot:          vm.GetShieldedInstanceConfig().GetEnableSecureBoot(),
+			VTPM:                vm.GetShieldedInstanceConfig().GetEnableVtpm(),
+			IntegrityMonitoring: vm.GetShieldedInstanceConfig().GetEnableIntegrityMonitoring(),
+			ConfidentialVM:      vm.GetConfidentialInstanceConfig().GetEnableConfidentialCompute(),
+		},
+		IdleTimeout: getIdleTimeout(vm),
+	}
+}
+
+// getIdleTimeout returns the idle timeout recorded in the instance metadata.
+func getIdleTimeout(vm *computepb.Instance) int {
+	idleTimeout, err := strconv.Atoi(getMetadataItem(vm, IdleTimeoutMetadataKey))
+	if err != nil {
+		return 0
+	}
+
+	return idleTimeout
+}
+
+// getServiceAccount returns the email of the service account attached to the instance, none if it has none.
+func getServiceAccount(vm *computepb.Instance) string {
+	if len(vm.GetServiceAccounts()) == 0 {
+		return ServiceAccountNone
+	}
+
+	return vm.GetServiceAccounts()[0].GetEmail()
+}
+
+func getMetadataItem(vm *computepb.Instance, key string) string {
+	for _, item := range vm.GetMetadata().GetItems() {
+		if item.GetKey() == key {
+			return item.GetValue()
+		}
+	}
+
+	return ""
+}
+
+// getDataDisk returns the name of the data disk attached to the instance, if any.
+func getDataDisk(vm *computepb.Instance) string {
+	for _, disk := range vm.GetDisks() {
+		if disk.GetDeviceName() == DataDiskDeviceName {
+			return path.Base(disk.GetSource())
+		}
+	}
+
+	return ""
+}
+
+// GetInstanceProvisioningModel returns the provisioning model of the instance as one of the Provisioning Model options.
+func GetInstanceProvisioningModel(vm *computepb.Instance) string {
+	switch {
+	case vm.GetScheduling().GetProvisioningModel() == computepb.Scheduling_SPOT.String():
+		return ProvisioningModelSpot
+	case vm.GetScheduling().GetPreemptible():
+		return ProvisioningModelPreemptible
+	default:
+		return ProvisioningModelStandard
 	}
 }
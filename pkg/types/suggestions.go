@@ -6,3 +6,24 @@ var (
 	diskTypes    = []string{"hyperdisk-balanced-high-availability", "pd-balanced", "pd-ssd", "pd-standard", "hyperdisk-balanced", "hyperdisk-extreme", "hyperdisk-ml", "hyperdisk-throughput", "local-ss"}
 	vmImages     = []string{"projects/centos-cloud/global/images/family/centos-stream-9", "projects/cos-cloud/global/images/family/cos-101-lts", "projects/cos-cloud/global/images/family/cos-105-lts", "projects/cos-cloud/global/images/family/cos-109-lts", "projects/cos-cloud/global/images/family/cos-113-lts", "projects/debian-cloud/global/images/family/debian-11", "projects/debian-cloud/global/images/family/debian-12-arm64", "projects/debian-cloud/global/images/family/debian-12", "projects/opensuse-cloud/global/images/family/opensuse-leap-arm64", "projects/opensuse-cloud/global/images/family/opensuse-leap", "projects/opensuse-cloud/global/images/family/opensuse-leap-arm64", "projects/opensuse-cloud/global/images/family/opensuse-leap", "projects/rhel-cloud/global/images/family/rhel-8", "projects/rhel-cloud/global/images/family/rhel-9-arm64", "projects/rhel-cloud/global/images/family/rhel-9", "projects/rhel-sap-cloud/global/images/family/rhel-8-10-sap-ha", "projects/rhel-sap-cloud/global/images/family/rhel-8-4-sap-ha", "projects/rhel-sap-cloud/global/images/family/rhel-8-6-sap-ha", "projects/rhel-sap-cloud/global/images/family/rhel-8-8-sap-ha", "projects/rhel-sap-cloud/global/images/family/rhel-9-0-sap-ha", "projects/rhel-sap-cloud/global/images/family/rhel-9-2-sap-ha", "projects/rhel-sap-cloud/global/images/family/rhel-9-4-sap-ha", "projects/rocky-linux-cloud/global/images/family/rocky-linux-8-optimized-gcp-arm64", "projects/rocky-linux-cloud/global/images/family/rocky-linux-8-optimized-gcp", "projects/rocky-linux-cloud/global/images/family/rocky-linux-8", "projects/rocky-linux-cloud/global/images/family/rocky-linux-9-arm64", "projects/rocky-linux-cloud/global/images/family/rocky-linux-9-optimized-gcp-arm64", "projects/rocky-linux-cloud/global/images/family/rocky-linux-9-optimized-gcp", "projects/rocky-linux-cloud/global/images/family/rocky-linux-9", "projects/suse-cloud/global/images/family/sles-12", "projects/suse-cloud/global/images/family/sles-15-sp5-arm64", "projects/suse-cloud/global/images/family/sles-15-sp5", "projects/suse-cloud/global/images/family/sles-15-arm64", "projects/suse-cloud/global/images/family/sles-15", "projects/suse-sap-cloud/global/images/family/sles-12-sp5-sap", "projects/suse-sap-cloud/global/images/family/sles-15-sp2-sap", "projects/suse-sap-cloud/global/images/family/sles-15-sp3-sap", "projects/suse-sap-cloud/global/images/family/sles-15-sp4-sap", "projects/suse-sap-cloud/global/images/family/sles-15-sp5-sap", "projects/suse-sap-cloud/global/images/family/sles-15-sp6-sap", "projects/suse-sap-cloud/global/images/family/sles-sap-15-sp4-hardened", "projects/suse-sap-cloud/global/images/family/sles-sap-15-sp5-hardened", "projects/suse-sap-cloud/global/images/family/sles-sap-15-sp6-hardened", "projects/ubuntu-os-pro-cloud/global/images/family/ubuntu-pro-1604-lts", "projects/ubuntu-os-pro-cloud/global/images/family/ubuntu-pro-1804-lts-arm64", "projects/ubuntu-os-pro-cloud/global/images/family/ubuntu-pro-1804-lts", "projects/ubuntu-os-pro-cloud/global/images/family/ubuntu-pro-2004-lts-arm64", "projects/ubuntu-os-pro-cloud/global/images/family/ubuntu-pro-2004-lts", "projects/ubuntu-os-pro-cloud/global/images/family/ubuntu-pro-2204-lts-arm64", "projects/ubuntu-os-pro-cloud/global/images/family/ubuntu-pro-2204-lts", "projects/ubuntu-os-pro-cloud/global/images/family/ubuntu-pro-2404-lts-amd64", "projects/ubuntu-os-pro-cloud/global/images/family/ubuntu-pro-2404-lts-arm64", "projects/ubuntu-os-pro-cloud/global/images/family/ubuntu-pro-fips-1804-lts", "projects/cos-cloud/global/images/family/cos-arm64-101-lts", "projects/cos-cloud/global/images/family/cos-arm64-105-lts", "projects/cos-cloud/global/images/family/cos-arm64-109-lts", "projects/cos-cloud/global/images/family/cos-arm64-113-lts", "projects/cos-cloud/global/images/family/cos-arm64-beta", "projects/cos-cloud/global/images/family/cos-arm64-dev", "projects/ubuntu-os-cloud/global/images/family/ubuntu-2004-lts-arm64", "projects/ubuntu-os-cloud/global/images/family/ubuntu-2004-lts", "projects/ubuntu-os-cloud/global/images/family/ubuntu-2204-lts-arm64", "projects/ubuntu-os-cloud/global/images/family/ubuntu-2204-lts", "projects/ubuntu-os-pro-cloud/global/images/family/ubuntu-pro-fips-2004-lts", "projects/cos-cloud/global/images/family/cos-arm64-stable", "projects/cos-cloud/global/images/family/cos-beta", "projects/cos-cloud/global/images/family/cos-dev", "projects/ubuntu-os-cloud/global/images/family/ubuntu-2404-lts-amd64", "projects/ubuntu-os-cloud/global/images/family/ubuntu-2404-lts-arm64", "projects/ubuntu-os-cloud/global/images/family/ubuntu-minimal-2004-lts-arm64", "projects/cos-cloud/global/images/family/cos-stable", "projects/ubuntu-os-cloud/global/images/family/ubuntu-minimal-2004-lts", "projects/ubuntu-os-cloud/global/images/family/ubuntu-minimal-2204-lts-arm64", "projects/ubuntu-os-cloud/global/images/family/ubuntu-minimal-2204-lts", "projects/ubuntu-os-cloud/global/images/family/ubuntu-minimal-2404-lts-amd64", "projects/ubuntu-os-cloud/global/images/family/ubuntu-minimal-2404-lts-arm64", "projects/fedora-coreos-cloud/global/images/family/fedora-coreos-stable-arm64", "projects/fedora-coreos-cloud/global/images/family/fedora-coreos-stable", "projects/fedora-coreos-cloud/global/images/family/fedora-coreos-testing-arm64", "projects/fedora-coreos-cloud/global/images/family/fedora-coreos-testing", "projects/fedora-coreos-cloud/global/images/family/fedora-coreos-next-arm64", "projects/fedora-coreos-cloud/global/images/family/fedora-coreos-next"}
 )
+
+// Suggestions are the auto-complete values offered for the target options.
+type Suggestions struct {
+	Zones        []string
+	MachineTypes []string
+	DiskTypes    []string
+	VMImages     []string
+}
+
+// GetStaticSuggestions returns the built-in suggestions used when the project catalogue can't be queried.
+func GetStaticSuggestions() Suggestions {
+	return Suggestions{
+		Zones:        zones,
+		MachineTypes: machineTypes,
+		DiskTypes:    diskTypes,
+		VMImages:     vmImages,
+	}
+}
+
+// acceleratorTypes are the GPUs that can be attached to N1 machine types.
+var acceleratorTypes = []string{"nvidia-tesla-t4", "nvidia-tesla-v100", "nvidia-tesla-p100", "nvidia-tesla-p4"}
@@ -4,28 +4,313 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
+	"slices"
+	"strings"
+	"time"
 
 	"github.com/daytonaio/daytona/pkg/provider"
 )
 
+const (
+	AuthModeApplicationDefault = "application-default"
+	AuthModeCredentialFile     = "credential-file"
+	AuthModeCredentialJson     = "credential-json"
+	AuthModeImpersonate        = "impersonate"
+)
+
+var authModes = []string{AuthModeApplicationDefault, AuthModeCredentialFile, AuthModeCredentialJson, AuthModeImpersonate}
+
+const (
+	ProvisioningModelStandard    = "standard"
+	ProvisioningModelSpot        = "spot"
+	ProvisioningModelPreemptible = "preemptible"
+)
+
+var provisioningModels = []string{ProvisioningModelStandard, ProvisioningModelSpot, ProvisioningModelPreemptible}
+
+const (
+	TerminationActionStop   = "STOP"
+	TerminationActionDelete = "DELETE"
+)
+
+var terminationActions = []string{TerminationActionStop, TerminationActionDelete}
+
+const (
+	// ServiceAccountDefault attaches the default compute service account of the project to the VM.
+	ServiceAccountDefault = "default"
+	// ServiceAccountNone creates the VM without a service account.
+	ServiceAccountNone = "none"
+)
+
+// DefaultServiceAccountScopes leaves access control to the IAM roles of the service account.
+const DefaultServiceAccountScopes = "https://www.googleapis.com/auth/cloud-platform"
+
+const scopePrefix = "https://www.googleapis.com/auth/"
+
 type TargetOptions struct {
-	CredentialFile string `json:"Credential File"`
-	ProjectID      string `json:"Project Id"`
-	Zone           string `json:"Zone"`
-	MachineType    string `json:"Machine Type"`
-	DiskType       string `json:"Disk Type"`
-	DiskSize       int    `json:"Disk Size"`
-	VMImage        string `json:"VM Image"`
+	AuthMode                  string `json:"Auth Mode"`
+	CredentialFile            string `json:"Credential File"`
+	CredentialJson            string `json:"Credential JSON"`
+	ImpersonateServiceAccount string `json:"Impersonate Service Account"`
+	ProjectID                 string `json:"Project Id"`
+	Zone                      string `json:"Zone"`
+	MachineType               string `json:"Machine Type"`
+	DiskType                  string `json:"Disk Type"`
+	DiskSize                  int    `json:"Disk Size"`
+	VMImage                   string `json:"VM Image"`
+	OperationTimeout          int    `json:"Operation Timeout"`
+	Network                   string `json:"Network"`
+	Subnetwork                string `json:"Subnetwork"`
+	NetworkProjectID          string `json:"Network Project Id"`
+	AssignExternalIP          *bool  `json:"Assign External IP"`
+	ExternalIPAddress         string `json:"External IP Address"`
+	NetworkTags               string `json:"Network Tags"`
+	ManagedFirewall           bool   `json:"Managed Firewall"`
+	ProvisioningModel         string `json:"Provisioning Model"`
+	TerminationAction         string `json:"Termination Action"`
+	AcceleratorType           string `json:"Accelerator Type"`
+	AcceleratorCount          int    `json:"Accelerator Count"`
+	InstallGPUDrivers         bool   `json:"Install GPU Drivers"`
+	DataDiskSize              int    `json:"Data Disk Size"`
+	DataDiskType              string `json:"Data Disk Type"`
+	RetainDataDisk            bool   `json:"Retain Data Disk"`
+	SourceSnapshot            string `json:"Source Snapshot"`
+	SourceDataSnapshot        string `json:"Source Data Snapshot"`
+	ImageFamily               string `json:"Image Family"`
+	ServiceAccount            string `json:"Service Account"`
+	ServiceAccountScopes      string `json:"Service Account Scopes"`
+	SecureBoot                bool   `json:"Secure Boot"`
+	VTPM                      bool   `json:"vTPM"`
+	IntegrityMonitoring       bool   `json:"Integrity Monitoring"`
+	ConfidentialVM            bool   `json:"Confidential VM"`
+	Labels                    string `json:"Labels"`
+	KeepOnFailure             bool   `json:"Keep On Failure"`
+	IdleTimeout               int    `json:"Idle Timeout"`
+	StartSchedule             string `json:"Start Schedule"`
+	StopSchedule              string `json:"Stop Schedule"`
+	TimeZone                  string `json:"Time Zone"`
+}
+
+const defaultOperationTimeout = 10 * time.Minute
+
+// DefaultTimeZone is the time zone of the start and stop schedules if none is set.
+const DefaultTimeZone = "UTC"
+
+// GetAuthMode returns the configured auth mode. Targets created before the auth mode
+// was introduced fall back to the credential file if one is set and to application
+// default credentials otherwise.
+func (o *TargetOptions) GetAuthMode() string {
+	if o.AuthMode != "" {
+		return o.AuthMode
+	}
+
+	switch {
+	case o.CredentialFile != "":
+		return AuthModeCredentialFile
+	case o.CredentialJson != "":
+		return AuthModeCredentialJson
+	default:
+		return AuthModeApplicationDefault
+	}
+}
+
+// GetOperationTimeout returns the deadline of a single lifecycle operation, e.g. creating or deleting the VM.
+func (o *TargetOptions) GetOperationTimeout() time.Duration {
+	if o.OperationTimeout <= 0 {
+		return defaultOperationTimeout
+	}
+
+	return time.Duration(o.OperationTimeout) * time.Minute
+}
+
+// GetIdleTimeout returns the time without activity after which the workspace VM is stopped, 0 if it is never stopped.
+func (o *TargetOptions) GetIdleTimeout() time.Duration {
+	if o.IdleTimeout <= 0 {
+		return 0
+	}
+
+	return time.Duration(o.IdleTimeout) * time.Minute
+}
+
+// HasSchedule returns whether the VM is started or stopped on a schedule.
+func (o *TargetOptions) HasSchedule() bool {
+	return o.StartSchedule != "" || o.StopSchedule != ""
+}
+
+// GetTimeZone returns the time zone of the start and stop schedules, UTC if none is set.
+func (o *TargetOptions) GetTimeZone() string {
+	if o.TimeZone == "" {
+		return DefaultTimeZone
+	}
+
+	return o.TimeZone
+}
+
+// GetNetworkProjectID returns the project of the network, which differs from the project
+// of the VM when a Shared VPC host project is used.
+func (o *TargetOptions) GetNetworkProjectID() string {
+	if o.NetworkProjectID != "" {
+		return o.NetworkProjectID
+	}
+
+	return o.ProjectID
+}
+
+// GetAssignExternalIP returns whether the VM gets an external IP. Targets created before
+// the option was introduced always got one.
+func (o *TargetOptions) GetAssignExternalIP() bool {
+	return o.AssignExternalIP == nil || *o.AssignExternalIP
+}
+
+// GetNetworkTags returns the comma separated network tags as a list.
+func (o *TargetOptions) GetNetworkTags() []string {
+	tags := []string{}
+	for _, tag := range strings.Split(o.NetworkTags, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag != "" {
+			tags = append(tags, tag)
+		}
+	}
+
+	return tags
+}
+
+// GetLabels returns the comma separated key=value labels as a map. A label without a value has an empty value.
+func (o *TargetOptions) GetLabels() map[string]string {
+	labels := map[string]string{}
+	for _, label := range strings.Split(o.Labels, ",") {
+		key, value, _ := strings.Cut(label, "=")
+		key = strings.TrimSpace(key)
+		if key != "" {
+			labels[key] = strings.TrimSpace(value)
+		}
+	}
+
+	return labels
+}
+
+// GetProvisioningModel returns the provisioning model of the VM, standard if none is set.
+func (o *TargetOptions) GetProvisioningModel() string {
+	if o.ProvisioningModel == "" {
+		return ProvisioningModelStandard
+	}
+
+	return o.ProvisioningModel
+}
+
+// GetTerminationAction returns what GCP does with a spot VM when it is preempted, STOP if none is set.
+func (o *TargetOptions) GetTerminationAction() string {
+	if o.TerminationAction == "" {
+		return TerminationActionStop
+	}
+
+	return o.TerminationAction
+}
+
+// IsDeletedOnPreemption returns whether GCP deletes the VM when it is preempted, in which case it has to be recreated to start the workspace.
+func (o *TargetOptions) IsDeletedOnPreemption() bool {
+	return o.GetProvisioningModel() == ProvisioningModelSpot && o.GetTerminationAction() == TerminationActionDelete
+}
+
+// GetAcceleratorCount returns the number of attached GPUs, one if an accelerator type is set without a count.
+func (o *TargetOptions) GetAcceleratorCount() int {
+	if o.AcceleratorType != "" && o.AcceleratorCount <= 0 {
+		return 1
+	}
+
+	return o.AcceleratorCount
+}
+
+// GetDataDiskType returns the type of the data disk, the boot disk type if none is set.
+func (o *TargetOptions) GetDataDiskType() string {
+	if o.DataDiskType == "" {
+		return o.DiskType
+	}
+
+	return o.DataDiskType
+}
+
+// GetBootImage returns the image the boot disk is created from, the latest image of the prebaked image family if one is set.
+func (o *TargetOptions) GetBootImage() string {
+	if o.ImageFamily != "" {
+		return fmt.Sprintf("projects/%s/global/images/family/%s", o.ProjectID, o.ImageFamily)
+	}
+
+	return o.VMImage
+}
+
+// IsShieldedVM returns whether any of the Shielded VM features is enabled.
+func (o *TargetOptions) IsShieldedVM() bool {
+	return o.SecureBoot || o.VTPM || o.IntegrityMonitoring
+}
+
+// GetServiceAccount returns the email of the service account attached to the VM, the default compute service account if
+// none is set.
+func (o *TargetOptions) GetServiceAccount() string {
+	if o.ServiceAccount == "" {
+		return ServiceAccountDefault
+	}
+
+	return o.ServiceAccount
+}
+
+// GetServiceAccountScopes returns the comma separated OAuth scopes of the VM service account as a list of scope URLs.
+// Scopes can be given without the https://www.googleapis.com/auth/ prefix, e.g. cloud-platform.
+func (o *TargetOptions) GetServiceAccountScopes() []string {
+	serviceAccountScopes := o.ServiceAccountScopes
+	if strings.TrimSpace(serviceAccountScopes) == "" {
+		serviceAccountScopes = DefaultServiceAccountScopes
+	}
+
+	scopes := []string{}
+	for _, scope := range strings.Split(serviceAccountScopes, ",") {
+		scope = strings.TrimSpace(scope)
+		if scope == "" {
+			continue
+		}
+		if !strings.Contains(scope, "://") {
+			scope = scopePrefix + scope
+		}
+		scopes = append(scopes, scope)
+	}
+
+	return scopes
 }
 
 func GetTargetManifest() *provider.ProviderTargetManifest {
+	return GetTargetManifestWithSuggestions(GetStaticSuggestions())
+}
+
+// GetTargetManifestWithSuggestions returns the target manifest with the given auto-complete values,
+// e.g. the ones discovered from the configured project.
+func GetTargetManifestWithSuggestions(suggestions Suggestions) *provider.ProviderTargetManifest {
 	return &provider.ProviderTargetManifest{
+		"Auth Mode": provider.ProviderTargetProperty{
+			Type: provider.ProviderTargetPropertyTypeOption,
+			Description: "How the provider authenticates with GCP.\n" +
+				"application-default: Application Default Credentials, e.g. the GCE/GKE metadata server or gcloud auth application-default login.\n" +
+				"credential-file: a service account JSON key file.\n" +
+				"credential-json: the content of a service account JSON key.\n" +
+				"impersonate: impersonate a service account using the credential file or application default credentials.",
+			DefaultValue: AuthModeCredentialFile,
+			Options:      authModes,
+		},
 		"Credential File": provider.ProviderTargetProperty{
 			Type: provider.ProviderTargetPropertyTypeFilePath,
-			Description: "Full path to the GCP service account JSON key file.\nLeave blank if you've set the GCP_CREDENTIAL_FILE" +
-				"environment variable.\nEnsure that the file is secure and accessible only to authorized users.",
+			Description: "Full path to the GCP service account JSON key file.\nLeave blank if you've set the GCP_CREDENTIAL_FILE " +
+				"environment variable or if the auth mode doesn't use a key file.\nEnsure that the file is secure and accessible only to authorized users.",
 			DefaultValue: "~/.config/gcloud",
 		},
+		"Credential JSON": provider.ProviderTargetProperty{
+			Type:        provider.ProviderTargetPropertyTypeString,
+			InputMasked: true,
+			Description: "The content of the GCP service account JSON key.\nOnly used with the credential-json auth mode.",
+		},
+		"Impersonate Service Account": provider.ProviderTargetProperty{
+			Type: provider.ProviderTargetPropertyTypeString,
+			Description: "Email of the service account to impersonate.\nOnly used with the impersonate auth mode.\n" +
+				"The source credentials need the roles/iam.serviceAccountTokenCreator role on this account.",
+		},
 		"Project Id": provider.ProviderTargetProperty{
 			Type:        provider.ProviderTargetPropertyTypeString,
 			InputMasked: true,
@@ -38,7 +323,7 @@ func GetTargetManifest() *provider.ProviderTargetManifest {
 				"https://cloud.google.com/compute/docs/regions-zones\n" +
 				"List of available zones can be retrieved using the command:\ngcloud compute zones list",
 			DefaultValue: "us-central1-a",
-			Suggestions:  zones,
+			Suggestions:  suggestions.Zones,
 		},
 		"Machine Type": provider.ProviderTargetProperty{
 			Type: provider.ProviderTargetPropertyTypeString,
@@ -46,7 +331,7 @@ func GetTargetManifest() *provider.ProviderTargetManifest {
 				"https://cloud.google.com/compute/docs/general-purpose-machines\n" +
 				"List of available machine types can be retrieved using the command:\ngcloud compute machine-types list",
 			DefaultValue: "n1-standard-1",
-			Suggestions:  machineTypes,
+			Suggestions:  suggestions.MachineTypes,
 		},
 		"Disk Type": provider.ProviderTargetProperty{
 			Type: provider.ProviderTargetPropertyTypeString,
@@ -54,7 +339,7 @@ func GetTargetManifest() *provider.ProviderTargetManifest {
 				"https://cloud.google.com/compute/docs/disks\n" +
 				"List of available disk types can be retrieved using the command:\ngcloud compute disk-types list",
 			DefaultValue: "pd-standard",
-			Suggestions:  diskTypes,
+			Suggestions:  suggestions.DiskTypes,
 		},
 		"Disk Size": provider.ProviderTargetProperty{
 			Type:         provider.ProviderTargetPropertyTypeInt,
@@ -67,7 +352,194 @@ func GetTargetManifest() *provider.ProviderTargetManifest {
 				"https://cloud.google.com/compute/docs/images\n" +
 				"List of available images can be retrieved using the command:\ngcloud compute images list",
 			DefaultValue: "projects/ubuntu-os-cloud/global/images/family/ubuntu-2204-lts",
-			Suggestions:  vmImages,
+			Suggestions:  suggestions.VMImages,
+		},
+		"Network": provider.ProviderTargetProperty{
+			Type: provider.ProviderTargetPropertyTypeString,
+			Description: "The VPC network of the VM. Default is default.\n" +
+				"Leave blank to use the network of the subnetwork.",
+			DefaultValue: "default",
+		},
+		"Subnetwork": provider.ProviderTargetProperty{
+			Type: provider.ProviderTargetPropertyTypeString,
+			Description: "The subnetwork of the VM, either a name in the region of the zone or a full path like\n" +
+				"projects/<host-project>/regions/<region>/subnetworks/<subnetwork>.\n" +
+				"Required for custom mode networks.",
+		},
+		"Network Project Id": provider.ProviderTargetProperty{
+			Type: provider.ProviderTargetPropertyTypeString,
+			Description: "The Shared VPC host project that owns the network and subnetwork.\n" +
+				"Leave blank if the network is in the project of the VM.",
+		},
+		"Assign External IP": provider.ProviderTargetProperty{
+			Type: provider.ProviderTargetPropertyTypeBoolean,
+			Description: "Whether the VM gets an external IP. Default is true.\n" +
+				"VMs without an external IP need Cloud NAT or another route to the internet to install Docker and the Daytona agent.",
+			DefaultValue: "true",
+		},
+		"External IP Address": provider.ProviderTargetProperty{
+			Type: provider.ProviderTargetPropertyTypeString,
+			Description: "A static external IP address reserved in the region of the zone, either the address or its name.\n" +
+				"Leave blank to use an ephemeral address.",
+		},
+		"Network Tags": provider.ProviderTargetProperty{
+			Type: provider.ProviderTargetPropertyTypeString,
+			Description: "Comma separated network tags of the VM, e.g. to apply existing firewall rules.\n" +
+				"The daytona-workspace tag is always added.",
+		},
+		"Labels": provider.ProviderTargetProperty{
+			Type: provider.ProviderTargetPropertyTypeString,
+			Description: "Comma separated key=value labels of the VM and its disks, e.g. team=platform,cost-center=1234.\n" +
+				"Keys and values are converted to lowercase letters, digits, underscores and dashes.\n" +
+				"The daytona-workspace-id, daytona-workspace-name, daytona-provider-version and managed-by labels are always added.",
+		},
+		"Managed Firewall": provider.ProviderTargetProperty{
+			Type: provider.ProviderTargetPropertyTypeBoolean,
+			Description: "Whether the provider manages a firewall rule that denies all ingress to workspace VMs. Default is false.\n" +
+				"The rule targets the daytona-workspace tag, is shared by the workspaces in the network and is deleted with the last\n" +
+				"workspace VM in the network. Its priority is 65000, so rules that allow ingress with the default priority still apply.\n" +
+				"The workspace agent connects over tailscale, so workspaces keep working without ingress.",
+			DefaultValue: "false",
+		},
+		"Provisioning Model": provider.ProviderTargetProperty{
+			Type: provider.ProviderTargetPropertyTypeOption,
+			Description: "The provisioning model of the VM. Default is standard.\n" +
+				"spot and preemptible VMs are much cheaper but can be preempted by GCP at any time. Preempted workspaces are restarted on start.\n" +
+				"https://cloud.google.com/compute/docs/instances/spot",
+			DefaultValue: ProvisioningModelStandard,
+			Options:      provisioningModels,
+		},
+		"Termination Action": provider.ProviderTargetProperty{
+			Type: provider.ProviderTargetPropertyTypeOption,
+			Description: "What GCP does with a spot VM when it is preempted. Only used with the spot provisioning model. Default is STOP.\n" +
+				"DELETE also deletes the boot disk, so the workspace has to be recreated after a preemption.",
+			DefaultValue: TerminationActionStop,
+			Options:      terminationActions,
+		},
+		"Accelerator Type": provider.ProviderTargetProperty{
+			Type: provider.ProviderTargetPropertyTypeString,
+			Description: "The GPU attached to the VM. Only supported with N1 machine types.\n" +
+				"Leave blank for no GPU or for machine types with built-in GPUs, e.g. a2, a3 and g2.\n" +
+				"https://cloud.google.com/compute/docs/gpus\n" +
+				"List of available accelerator types can be retrieved using the command:\ngcloud compute accelerator-types list",
+			Suggestions: acceleratorTypes,
+		},
+		"Accelerator Count": provider.ProviderTargetProperty{
+			Type:         provider.ProviderTargetPropertyTypeInt,
+			Description:  "The number of GPUs of the accelerator type attached to the VM. Default is 1.",
+			DefaultValue: "1",
+		},
+		"Install GPU Drivers": provider.ProviderTargetProperty{
+			Type: provider.ProviderTargetPropertyTypeBoolean,
+			Description: "Whether the startup script installs the NVIDIA drivers and the NVIDIA Container Toolkit\n" +
+				"and makes the GPUs available to project containers. Default is false.\n" +
+				"Supported on Debian, Ubuntu and RHEL compatible images, not on Container-Optimized OS.",
+			DefaultValue: "false",
+		},
+		"Data Disk Size": provider.ProviderTargetProperty{
+			Type: provider.ProviderTargetPropertyTypeInt,
+			Description: "The size of a separate persistent disk mounted at /home/daytona, in GB.\n" +
+				"Default is 0, which keeps /home/daytona on the boot disk.",
+			DefaultValue: "0",
+		},
+		"Data Disk Type": provider.ProviderTargetProperty{
+			Type:        provider.ProviderTargetPropertyTypeString,
+			Description: "The GCP disk type of the data disk. Leave blank to use the disk type of the boot disk.",
+			Suggestions: suggestions.DiskTypes,
+		},
+		"Retain Data Disk": provider.ProviderTargetProperty{
+			Type: provider.ProviderTargetPropertyTypeBoolean,
+			Description: "Whether the data disk is kept when the workspace is destroyed. Default is false.\n" +
+				"A workspace with the same name in the same zone reattaches the retained disk.",
+			DefaultValue: "false",
+		},
+		"Source Snapshot": provider.ProviderTargetProperty{
+			Type: provider.ProviderTargetPropertyTypeString,
+			Description: "A snapshot to restore the boot disk from instead of the VM image, either a name or a\n" +
+				"projects/<project>/global/snapshots/<snapshot> path. Leave blank to create the boot disk from the VM image.",
+		},
+		"Source Data Snapshot": provider.ProviderTargetProperty{
+			Type: provider.ProviderTargetPropertyTypeString,
+			Description: "A snapshot to restore a new data disk from, either a name or a projects/<project>/global/snapshots/<snapshot> path.\n" +
+				"Only used with a Data Disk Size.",
+		},
+		"Image Family": provider.ProviderTargetProperty{
+			Type: provider.ProviderTargetPropertyTypeString,
+			Description: "The family of a prebaked image in the project, with Docker and the workspace VM setup already installed.\n" +
+				"The image is baked from the VM image when the family doesn't exist yet. Leave blank to set up every VM from the VM image.",
+		},
+		"Service Account": provider.ProviderTargetProperty{
+			Type: provider.ProviderTargetPropertyTypeString,
+			Description: "Email of the service account attached to the VM, e.g. one that can only pull from Artifact Registry.\n" +
+				"Leave blank or set to default for the default compute service account of the project, which often has the Editor role.\n" +
+				"Set to none to create the VM without a service account. The provider credentials need the\n" +
+				"roles/iam.serviceAccountUser role on the service account.",
+			Suggestions: []string{ServiceAccountDefault, ServiceAccountNone},
+		},
+		"Service Account Scopes": provider.ProviderTargetProperty{
+			Type: provider.ProviderTargetPropertyTypeString,
+			Description: "Comma separated OAuth scopes of the VM service account, either full URLs or names like devstorage.read_only.\n" +
+				"Default is cloud-platform, which leaves access control to the IAM roles of the service account.\n" +
+				"Not used without a service account.",
+			DefaultValue: DefaultServiceAccountScopes,
+		},
+		"Secure Boot": provider.ProviderTargetProperty{
+			Type: provider.ProviderTargetPropertyTypeBoolean,
+			Description: "Whether the Shielded VM only boots software signed by trusted keys. Default is false.\n" +
+				"Requires a UEFI compatible VM image and can't be combined with Install GPU Drivers.\n" +
+				"https://cloud.google.com/compute/shielded-vm/docs/shielded-vm",
+			DefaultValue: "false",
+		},
+		"vTPM": provider.ProviderTargetProperty{
+			Type: provider.ProviderTargetPropertyTypeBoolean,
+			Description: "Whether the Shielded VM gets a virtual Trusted Platform Module, e.g. for measured boot. Default is false.\n" +
+				"Requires a UEFI compatible VM image.",
+			DefaultValue: "false",
+		},
+		"Integrity Monitoring": provider.ProviderTargetProperty{
+			Type: provider.ProviderTargetPropertyTypeBoolean,
+			Description: "Whether the boot integrity of the Shielded VM is checked against a baseline in Cloud Monitoring. Default is false.\n" +
+				"Requires vTPM.",
+			DefaultValue: "false",
+		},
+		"Confidential VM": provider.ProviderTargetProperty{
+			Type: provider.ProviderTargetPropertyTypeBoolean,
+			Description: "Whether the VM memory is encrypted with AMD SEV. Default is false.\n" +
+				"Requires an N2D or C2D machine type and a SEV capable VM image. Confidential VMs are terminated on host maintenance.\n" +
+				"https://cloud.google.com/confidential-computing/confidential-vm/docs/supported-configurations",
+			DefaultValue: "false",
+		},
+		"Idle Timeout": provider.ProviderTargetProperty{
+			Type: provider.ProviderTargetPropertyTypeInt,
+			Description: "The time, in minutes, without terminal sessions, SSH connections, Docker events or CPU usage " +
+				"after which the VM is stopped. Default is 0, which never stops the VM.",
+			DefaultValue: "0",
+		},
+		"Start Schedule": provider.ProviderTargetProperty{
+			Type: provider.ProviderTargetPropertyTypeString,
+			Description: "The cron schedule the VM is started on, e.g. 0 8 * * 1-5 to start it at 08:00 on weekdays.\n" +
+				"The schedule is a GCE instance schedule, which requires the Compute Engine Service Agent to be allowed to start and stop VMs.\n" +
+				"https://cloud.google.com/compute/docs/instances/schedule-instance-start-stop",
+		},
+		"Stop Schedule": provider.ProviderTargetProperty{
+			Type:        provider.ProviderTargetPropertyTypeString,
+			Description: "The cron schedule the VM is stopped on, e.g. 0 20 * * 1-5 to stop it at 20:00 on weekdays.",
+		},
+		"Time Zone": provider.ProviderTargetProperty{
+			Type:        provider.ProviderTargetPropertyTypeString,
+			Description: "The time zone of the start and stop schedules from the tz database, e.g. Europe/Berlin. Default is UTC.",
+		},
+		"Keep On Failure": provider.ProviderTargetProperty{
+			Type: provider.ProviderTargetPropertyTypeBoolean,
+			Description: "Whether the VM and disks of a workspace whose creation failed are kept to debug the failure. Default is false.\n" +
+				"By default, the resources created for the workspace are deleted when the creation fails.",
+			DefaultValue: "false",
+		},
+		"Operation Timeout": provider.ProviderTargetProperty{
+			Type: provider.ProviderTargetPropertyTypeInt,
+			Description: "The maximum time, in minutes, a single VM operation (create, start, stop, delete) can take " +
+				"before it fails. The agent gets as long to start after the VM is created or started. Default is 10 minutes.",
+			DefaultValue: "10",
 		},
 	}
 }
@@ -94,9 +566,32 @@ func ParseTargetOptions(optionsJson string) (*TargetOptions, error) {
 		}
 	}
 
-	if targetOptions.CredentialFile == "" {
-		return nil, fmt.Errorf("credential file not set in env/target options")
+	switch targetOptions.GetAuthMode() {
+	case AuthModeApplicationDefault:
+	case AuthModeCredentialFile:
+		if targetOptions.CredentialFile == "" {
+			return nil, fmt.Errorf("credential file not set in env/target options")
+		}
+	case AuthModeCredentialJson:
+		if targetOptions.CredentialJson == "" {
+			return nil, fmt.Errorf("credential JSON not set in target options")
+		}
+	case AuthModeImpersonate:
+		if targetOptions.ImpersonateServiceAccount == "" {
+			return nil, fmt.Errorf("impersonate service account not set in target options")
+		}
+	default:
+		return nil, fmt.Errorf("invalid auth mode %q", targetOptions.AuthMode)
 	}
+
+	if !slices.Contains(provisioningModels, targetOptions.GetProvisioningModel()) {
+		return nil, fmt.Errorf("invalid provisioning model %q", targetOptions.ProvisioningModel)
+	}
+
+	if !slices.Contains(terminationActions, targetOptions.GetTerminationAction()) {
+		return nil, fmt.Errorf("invalid termination action %q", targetOptions.TerminationAction)
+	}
+
 	if targetOptions.ProjectID == "" {
 		return nil, fmt.Errorf("project id not set in env/target options")
 	}
@@ -11,7 +11,13 @@ func TestGetTargetManifest(t *testing.T) {
 		t.Fatalf("Expected target manifest but got nil")
 	}
 
-	fields := [7]string{"Credential File", "Project Id", "Zone", "Machine Type", "Disk Type", "Disk Size", "VM Image"}
+	fields := []string{"Auth Mode", "Credential File", "Credential JSON", "Impersonate Service Account", "Project Id", "Zone", "Machine Type", "Disk Type", "Disk Size", "VM Image", "Operation Timeout",
+		"Network", "Subnetwork", "Network Project Id", "Assign External IP", "External IP Address",
+		"Network Tags", "Managed Firewall", "Provisioning Model", "Termination Action",
+		"Accelerator Type", "Accelerator Count", "Install GPU Drivers",
+		"Data Disk Size", "Data Disk Type", "Retain Data Disk", "Source Snapshot", "Source Data Snapshot", "Image Family",
+		"Service Account", "Service Account Scopes", "Secure Boot", "vTPM", "Integrity Monitoring", "Confidential VM",
+		"Labels", "Keep On Failure", "Idle Timeout", "Start Schedule", "Stop Schedule", "Time Zone"}
 	for _, field := range fields {
 		if _, ok := (*targetManifest)[field]; !ok {
 			t.Errorf("Expected field %s in target manifest but it was not found", field)
@@ -135,6 +141,111 @@ func TestParseTargetOptions(t *testing.T) {
 			},
 			wantErr: false,
 		},
+		{
+			name: "Application default credentials without a credential file",
+			optionsJson: `{
+				"Auth Mode": "application-default",
+				"Project Id": "my-project"
+			}`,
+			want: &TargetOptions{
+				AuthMode:  AuthModeApplicationDefault,
+				ProjectID: "my-project",
+			},
+			wantErr: false,
+		},
+		{
+			name: "No auth mode and no credential file defaults to application default credentials",
+			optionsJson: `{
+				"Project Id": "my-project"
+			}`,
+			want: &TargetOptions{
+				ProjectID: "my-project",
+			},
+			wantErr: false,
+		},
+		{
+			name: "Credential JSON auth mode without JSON",
+			optionsJson: `{
+				"Auth Mode": "credential-json",
+				"Project Id": "my-project"
+			}`,
+			wantErr: true,
+		},
+		{
+			name: "Impersonate auth mode",
+			optionsJson: `{
+				"Auth Mode": "impersonate",
+				"Impersonate Service Account": "daytona@my-project.iam.gserviceaccount.com",
+				"Project Id": "my-project"
+			}`,
+			want: &TargetOptions{
+				AuthMode:                  AuthModeImpersonate,
+				ImpersonateServiceAccount: "daytona@my-project.iam.gserviceaccount.com",
+				ProjectID:                 "my-project",
+			},
+			wantErr: false,
+		},
+		{
+			name: "Impersonate auth mode without a service account",
+			optionsJson: `{
+				"Auth Mode": "impersonate",
+				"Project Id": "my-project"
+			}`,
+			wantErr: true,
+		},
+		{
+			name: "Network tags and managed firewall",
+			optionsJson: `{
+				"Project Id": "my-project",
+				"Network Tags": "dev, allow-iap",
+				"Managed Firewall": true
+			}`,
+			want: &TargetOptions{
+				ProjectID:       "my-project",
+				NetworkTags:     "dev, allow-iap",
+				ManagedFirewall: true,
+			},
+			wantErr: false,
+		},
+		{
+			name: "Spot VM deleted on preemption",
+			optionsJson: `{
+				"Project Id": "my-project",
+				"Provisioning Model": "spot",
+				"Termination Action": "DELETE"
+			}`,
+			want: &TargetOptions{
+				ProjectID:         "my-project",
+				ProvisioningModel: ProvisioningModelSpot,
+				TerminationAction: TerminationActionDelete,
+			},
+			wantErr: false,
+		},
+		{
+			name: "Invalid provisioning model",
+			optionsJson: `{
+				"Project Id": "my-project",
+				"Provisioning Model": "reserved"
+			}`,
+			wantErr: true,
+		},
+		{
+			name: "Invalid termination action",
+			optionsJson: `{
+				"Project Id": "my-project",
+				"Provisioning Model": "spot",
+				"Termination Action": "SUSPEND"
+			}`,
+			wantErr: true,
+		},
+		{
+			name: "Invalid auth mode",
+			optionsJson: `{
+				"Auth Mode": "password",
+				"Project Id": "my-project"
+			}`,
+			wantErr: true,
+		},
 	}
 
 	for _, tt := range tests {
@@ -154,3 +265,44 @@ func TestParseTargetOptions(t *testing.T) {
 		})
 	}
 }
+
+func TestGetNetworkTags(t *testing.T) {
+	opts := &TargetOptions{NetworkTags: " dev,allow-iap ,, "}
+
+	tags := opts.GetNetworkTags()
+	if !reflect.DeepEqual(tags, []string{"dev", "allow-iap"}) {
+		t.Errorf("Expected tags [dev allow-iap], got %v", tags)
+	}
+}
+
+func TestGetServiceAccountScopes(t *testing.T) {
+	tests := []struct {
+		scopes string
+		want   []string
+	}{
+		{scopes: "", want: []string{DefaultServiceAccountScopes}},
+		{
+			scopes: " devstorage.read_only, https://www.googleapis.com/auth/logging.write ,",
+			want:   []string{"https://www.googleapis.com/auth/devstorage.read_only", "https://www.googleapis.com/auth/logging.write"},
+		},
+	}
+
+	for _, tt := range tests {
+		opts := &TargetOptions{ServiceAccountScopes: tt.scopes}
+
+		scopes := opts.GetServiceAccountScopes()
+		if !reflect.DeepEqual(scopes, tt.want) {
+			t.Errorf("Expected scopes %v for %q, got %v", tt.want, tt.scopes, scopes)
+		}
+	}
+}
+
+func TestGetLabels(t *testing.T) {
+	opts := &TargetOptions{Labels: " team = platform,cost-center=1234,, spot ,=orphan"}
+
+	labels := opts.GetLabels()
+	want := map[string]string{"team": "platform", "cost-center": "1234", "spot": ""}
+	if !reflect.DeepEqual(labels, want) {
+		t.Errorf("Expected labels %v, got %v", want, labels)
+	}
+}
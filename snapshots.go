@@ -0,0 +1,109 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+
+	"github.com/daytonaio/daytona/pkg/provider"
+	"github.com/daytonaio/daytona/pkg/workspace"
+
+	p "github.com/daytonaio/daytona-provider-gcp/pkg/provider"
+)
+
+// The snapshot commands run the snapshot operations of the provider outside of the Daytona server, which has no
+// snapshot calls in the plugin interface.
+const (
+	snapshotWorkspaceCommand = "snapshot-workspace"
+	listSnapshotsCommand     = "list-snapshots"
+	deleteSnapshotCommand    = "delete-snapshot"
+)
+
+func snapshotWorkspace(args []string) error {
+	flags := flag.NewFlagSet(snapshotWorkspaceCommand, flag.ContinueOnError)
+	targetOptions := flags.String("target-options", "{}", "The target options JSON of the target of the workspace")
+	workspaceId := flags.String("workspace-id", "", "The ID of the workspace to snapshot")
+	workspaceName := flags.String("workspace-name", "", "The name of the workspace, used for the snapshot labels")
+
+	err := parseSnapshotFlags(flags, args, "workspace-id")
+	if errors.Is(err, flag.ErrHelp) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	return withProvider(func(gcpProvider *p.GCPProvider) error {
+		snapshots, err := gcpProvider.SnapshotWorkspace(&provider.WorkspaceRequest{
+			TargetOptions: *targetOptions,
+			Workspace:     &workspace.Workspace{Id: *workspaceId, Name: *workspaceName},
+		})
+		for _, snapshot := range snapshots {
+			fmt.Println(snapshot)
+		}
+
+		return err
+	})
+}
+
+func listSnapshots(args []string) error {
+	flags := flag.NewFlagSet(listSnapshotsCommand, flag.ContinueOnError)
+	targetOptions := flags.String("target-options", "{}", "The target options JSON of the target of the workspace")
+	workspaceId := flags.String("workspace-id", "", "The ID of the workspace whose snapshots are listed")
+
+	err := parseSnapshotFlags(flags, args, "workspace-id")
+	if errors.Is(err, flag.ErrHelp) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	return withProvider(func(gcpProvider *p.GCPProvider) error {
+		snapshots, err := gcpProvider.ListWorkspaceSnapshots(&provider.WorkspaceRequest{
+			TargetOptions: *targetOptions,
+			Workspace:     &workspace.Workspace{Id: *workspaceId},
+		})
+		if err != nil {
+			return err
+		}
+
+		for _, snapshot := range snapshots {
+			fmt.Printf("%s\t%s\t%s\n", snapshot.GetName(), snapshot.GetCreationTimestamp(), snapshot.GetStatus())
+		}
+
+		return nil
+	})
+}
+
+func deleteSnapshot(args []string) error {
+	flags := flag.NewFlagSet(deleteSnapshotCommand, flag.ContinueOnError)
+	targetOptions := flags.String("target-options", "{}", "The target options JSON of the target whose project holds the snapshot")
+	name := flags.String("snapshot", "", "The name of the snapshot to delete")
+
+	err := parseSnapshotFlags(flags, args, "snapshot")
+	if errors.Is(err, flag.ErrHelp) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	return withProvider(func(gcpProvider *p.GCPProvider) error {
+		return gcpProvider.DeleteSnapshot(*targetOptions, *name)
+	})
+}
+
+// parseSnapshotFlags parses the flags of a snapshot command and checks that the required flag is set.
+func parseSnapshotFlags(flags *flag.FlagSet, args []string, required string) error {
+	err := flags.Parse(args)
+	if err != nil {
+		return err
+	}
+
+	if flags.Lookup(required).Value.String() == "" {
+		return fmt.Errorf("-%s is required", required)
+	}
+
+	return nil
+}